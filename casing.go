@@ -0,0 +1,290 @@
+package jsonapi
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// MemberCase is a member naming convention.
+type MemberCase string
+
+const (
+	SnakeCase  MemberCase = "snake"  // Lowercase words joined by underscores, e.g. "first_name".
+	CamelCase  MemberCase = "camel"  // Capitalized words with a lowercase first word, e.g. "firstName".
+	KebabCase  MemberCase = "kebab"  // Lowercase words joined by hyphens, e.g. "first-name".
+	PascalCase MemberCase = "pascal" // Capitalized words, e.g. "FirstName".
+)
+
+// IsMemberName returns true if the name is a legal member name: a non-empty string of
+// letters a-z and A-Z, digits, and non-ASCII characters, which may also contain hyphens,
+// underscores and spaces, except as its first or last character.
+func IsMemberName(name string) bool {
+	runes := []rune(name)
+	if len(runes) == 0 {
+		return false
+	}
+	for i, r := range runes {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r >= 0x80:
+		case r == '-' || r == '_' || r == ' ':
+			if i == 0 || i == len(runes)-1 {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// Format converts the name into the naming convention. Names in any of the supported
+// conventions are accepted. Extension members ("ns:member"), @-members, and names
+// formatted with unknown conventions are returned as is.
+func (c MemberCase) Format(name string) string {
+	if strings.HasPrefix(name, "@") || strings.Contains(name, ":") {
+		return name
+	}
+
+	words := splitWords(name)
+	if len(words) == 0 {
+		return name
+	}
+
+	switch c {
+	case SnakeCase:
+		return strings.ToLower(strings.Join(words, "_"))
+	case KebabCase:
+		return strings.ToLower(strings.Join(words, "-"))
+	case CamelCase:
+		return strings.ToLower(words[0]) + capitalize(words[1:])
+	case PascalCase:
+		return capitalize(words)
+	}
+
+	return name
+}
+
+// splitWords splits the name into words at separators and case boundaries.
+// Acronyms are kept together: "HTTPServer" is split into "HTTP" and "Server".
+func splitWords(name string) []string {
+	words := make([]string, 0)
+	runes := []rune(name)
+	start := 0
+
+	for i, r := range runes {
+		switch {
+		case r == '_' || r == '-' || r == ' ':
+			if i > start {
+				words = append(words, string(runes[start:i]))
+			}
+			start = i + 1
+		case i > start && unicode.IsUpper(r):
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if !unicode.IsUpper(prev) || nextLower {
+				words = append(words, string(runes[start:i]))
+				start = i
+			}
+		}
+	}
+
+	if start < len(runes) {
+		words = append(words, string(runes[start:]))
+	}
+
+	return words
+}
+
+func capitalize(words []string) string {
+	var sb strings.Builder
+	for _, word := range words {
+		runes := []rune(strings.ToLower(word))
+		runes[0] = unicode.ToUpper(runes[0])
+		sb.WriteString(string(runes))
+	}
+	return sb.String()
+}
+
+// MemberNames converts member names between the canonical convention used by struct
+// tags and the convention used by clients on the wire. For example, models tagged
+// with snake_case names can be served in camelCase:
+//
+//	names := jsonapi.MemberNames{Canonical: jsonapi.SnakeCase, Wire: jsonapi.CamelCase}
+//	doc, _ := jsonapi.Marshal(model, jsonapi.WithMemberNames(names))
+//
+// Attribute and relationship names are converted, as are the attribute and
+// relationship references in error source pointers. The names of nested attribute
+// objects and of resource meta members are converted as well, when they are held as
+// map[string]any values -- as they are when decoded from JSON; values of other types,
+// such as structs, are marshaled with the names of their json tags.
+type MemberNames struct {
+	Canonical MemberCase // The naming convention of struct tags.
+	Wire      MemberCase // The naming convention of documents exchanged with clients.
+}
+
+// Encode converts a canonical name into its wire form.
+func (m MemberNames) Encode(name string) string {
+	return m.Wire.Format(name)
+}
+
+// Decode converts a wire name into its canonical form.
+func (m MemberNames) Decode(name string) string {
+	return m.Canonical.Format(name)
+}
+
+// DecodePath converts the names of a dot-separated relationship path into their
+// canonical form, e.g. "blogPosts.author" into "blog_posts.author".
+func (m MemberNames) DecodePath(path string) string {
+	return convertPath(path, m.Decode)
+}
+
+// EncodeDocument converts the member names of the document into their wire form. The
+// document's resources and errors are replaced with converted copies; the originals,
+// which may be shared with other documents, are left intact.
+func (m MemberNames) EncodeDocument(d *Document) {
+	*d = convertedDocument(*d, m.Encode)
+}
+
+// DecodeDocument converts the member names of the document into their canonical form. The
+// document's resources and errors are replaced with converted copies; the originals,
+// which may be shared with other documents, are left intact.
+func (m MemberNames) DecodeDocument(d *Document) {
+	*d = convertedDocument(*d, m.Decode)
+}
+
+func convertPath(path string, convert func(string) string) string {
+	names := strings.Split(path, ".")
+	for i, name := range names {
+		names[i] = convert(name)
+	}
+	return strings.Join(names, ".")
+}
+
+// convertedDocument returns a copy of the document with converted member names. Resources
+// and errors are copied before conversion, leaving those of the original document intact.
+func convertedDocument(d Document, convert func(string) string) Document {
+	if d.Data != nil {
+		items := copyResources(d.Data.Items())
+		if d.Data.IsMany() {
+			d.Data = Many{Value: items}
+		} else if len(items) > 0 {
+			d.Data = One{Value: items[0]}
+		}
+	}
+	d.Included = copyResources(d.Included)
+
+	if d.Errors != nil {
+		errs := make([]*Error, len(d.Errors))
+		for i, e := range d.Errors {
+			if e != nil && e.Source != nil {
+				copied, source := *e, *e.Source
+				copied.Source = &source
+				e = &copied
+			}
+			errs[i] = e
+		}
+		d.Errors = errs
+	}
+
+	convertDocument(&d, convert)
+	return d
+}
+
+func copyResources(resources []*Resource) []*Resource {
+	if resources == nil {
+		return nil
+	}
+	copies := make([]*Resource, len(resources))
+	for i, r := range resources {
+		if r != nil {
+			copied := *r
+			copies[i] = &copied
+		}
+	}
+	return copies
+}
+
+func convertDocument(d *Document, convert func(string) string) {
+	if d.Data != nil {
+		for _, item := range d.Data.Items() {
+			convertResource(item, convert)
+		}
+	}
+	for _, item := range d.Included {
+		convertResource(item, convert)
+	}
+	for _, e := range d.Errors {
+		if e != nil && e.Source != nil {
+			e.Source.Pointer = convertPointer(e.Source.Pointer, convert)
+		}
+	}
+}
+
+func convertResource(r *Resource, convert func(string) string) {
+	if r == nil {
+		return
+	}
+	if r.Attributes != nil {
+		r.Attributes = convertObject(r.Attributes, convert)
+	}
+	if r.Meta != nil {
+		r.Meta = convertObject(r.Meta, convert)
+	}
+	if r.Relationships != nil {
+		relationships := make(RelationshipsNode, len(r.Relationships))
+		for name, rel := range r.Relationships {
+			relationships[convert(name)] = rel
+		}
+		r.Relationships = relationships
+	}
+}
+
+// convertObject returns a copy of the object with converted member names, converting
+// the names of nested objects, including those within arrays, as well.
+func convertObject(object map[string]any, convert func(string) string) map[string]any {
+	converted := make(map[string]any, len(object))
+	for name, value := range object {
+		converted[convert(name)] = convertValue(value, convert)
+	}
+	return converted
+}
+
+func convertValue(value any, convert func(string) string) any {
+	switch value := value.(type) {
+	case map[string]any:
+		return convertObject(value, convert)
+	case []any:
+		converted := make([]any, len(value))
+		for i, item := range value {
+			converted[i] = convertValue(item, convert)
+		}
+		return converted
+	}
+	return value
+}
+
+// convertPointer converts the member names that follow "attributes" and
+// "relationships" tokens in a JSON pointer, including the names of nested
+// attribute objects.
+func convertPointer(pointer string, convert func(string) string) string {
+	tokens := strings.Split(pointer, "/")
+	nested := false
+	for i := 1; i < len(tokens); i++ {
+		switch {
+		case tokens[i-1] == "attributes":
+			tokens[i] = convert(tokens[i])
+			nested = true
+		case tokens[i-1] == "relationships":
+			tokens[i] = convert(tokens[i])
+		case nested && !isArrayIndex(tokens[i]):
+			tokens[i] = convert(tokens[i])
+		}
+	}
+	return strings.Join(tokens, "/")
+}
+
+func isArrayIndex(token string) bool {
+	_, err := strconv.Atoi(token)
+	return err == nil
+}
@@ -0,0 +1,176 @@
+package jsonapi_test
+
+import (
+	"testing"
+
+	"github.com/gonobo/jsonapi/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsMemberName(t *testing.T) {
+	for _, name := range []string{"a", "firstName", "first_name", "first-name", "first name", "x9", "café"} {
+		assert.True(t, jsonapi.IsMemberName(name), name)
+	}
+	for _, name := range []string{"", "-first", "first_", " first", "first!", "a.b", "a[b]"} {
+		assert.False(t, jsonapi.IsMemberName(name), name)
+	}
+}
+
+func TestMemberCase(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		want map[jsonapi.MemberCase]string
+	}{
+		{
+			name: "first_name",
+			want: map[jsonapi.MemberCase]string{
+				jsonapi.SnakeCase:  "first_name",
+				jsonapi.CamelCase:  "firstName",
+				jsonapi.KebabCase:  "first-name",
+				jsonapi.PascalCase: "FirstName",
+			},
+		},
+		{
+			name: "HTTPServerURL",
+			want: map[jsonapi.MemberCase]string{
+				jsonapi.SnakeCase: "http_server_url",
+				jsonapi.CamelCase: "httpServerUrl",
+			},
+		},
+		{
+			name: "blog-posts2",
+			want: map[jsonapi.MemberCase]string{
+				jsonapi.SnakeCase: "blog_posts2",
+				jsonapi.CamelCase: "blogPosts2",
+			},
+		},
+		{
+			name: "version:id",
+			want: map[jsonapi.MemberCase]string{jsonapi.SnakeCase: "version:id"},
+		},
+		{
+			name: "@context_value",
+			want: map[jsonapi.MemberCase]string{jsonapi.CamelCase: "@context_value"},
+		},
+		{
+			name: "first_name",
+			want: map[jsonapi.MemberCase]string{jsonapi.MemberCase("unknown"): "first_name"},
+		},
+	} {
+		for c, want := range tc.want {
+			assert.Equal(t, want, c.Format(tc.name), "%s in %s case", tc.name, c)
+		}
+	}
+}
+
+func TestMemberNames(t *testing.T) {
+	names := jsonapi.MemberNames{Canonical: jsonapi.SnakeCase, Wire: jsonapi.CamelCase}
+
+	type author struct {
+		ID string `jsonapi:"primary,people"`
+	}
+	type article struct {
+		ID        string  `jsonapi:"primary,articles"`
+		FirstLine string  `jsonapi:"attr,first_line"`
+		CoAuthor  *author `jsonapi:"relation,co_author"`
+	}
+
+	doc, err := jsonapi.Marshal(article{ID: "1", FirstLine: "a", CoAuthor: &author{ID: "9"}})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	names.EncodeDocument(&doc)
+	resource := doc.Data.First()
+	assert.Contains(t, resource.Attributes, "firstLine")
+	assert.Contains(t, resource.Relationships, "coAuthor")
+
+	names.DecodeDocument(&doc)
+	out := article{}
+	if assert.NoError(t, jsonapi.Unmarshal(&doc, &out)) {
+		assert.Equal(t, "a", out.FirstLine)
+		assert.Equal(t, "9", out.CoAuthor.ID)
+	}
+
+	t.Run("error pointers", func(t *testing.T) {
+		doc := jsonapi.Document{Errors: []*jsonapi.Error{
+			{Source: &jsonapi.ErrorSource{Pointer: "/data/attributes/first_line"}},
+			{Source: &jsonapi.ErrorSource{Pointer: "/data/relationships/co_author/data/type"}},
+			{Source: &jsonapi.ErrorSource{Pointer: "/data/attributes/past_jobs/0/start_date"}},
+		}}
+		names.EncodeDocument(&doc)
+		assert.Equal(t, "/data/attributes/firstLine", doc.Errors[0].Source.Pointer)
+		assert.Equal(t, "/data/relationships/coAuthor/data/type", doc.Errors[1].Source.Pointer)
+		assert.Equal(t, "/data/attributes/pastJobs/0/startDate", doc.Errors[2].Source.Pointer)
+	})
+
+	t.Run("nested names", func(t *testing.T) {
+		type address struct {
+			PostCode string `json:"post_code"`
+		}
+		doc := jsonapi.NewSingleDocument(&jsonapi.Resource{
+			Type: "people",
+			ID:   "1",
+			Attributes: map[string]any{
+				"home_address": map[string]any{"post_code": "12345"},
+				"past_jobs":    []any{map[string]any{"start_date": "2020"}, "intern"},
+				"work_address": address{PostCode: "67890"},
+			},
+			Meta: jsonapi.Meta{"last_seen": map[string]any{"time_zone": "UTC"}},
+		})
+
+		names.EncodeDocument(doc)
+		resource := doc.Data.First()
+		assert.Equal(t, map[string]any{
+			"homeAddress": map[string]any{"postCode": "12345"},
+			"pastJobs":    []any{map[string]any{"startDate": "2020"}, "intern"},
+			// struct values keep the names of their json tags.
+			"workAddress": address{PostCode: "67890"},
+		}, resource.Attributes)
+		assert.Equal(t, jsonapi.Meta{"lastSeen": map[string]any{"timeZone": "UTC"}}, resource.Meta)
+
+		names.DecodeDocument(doc)
+		assert.Equal(t, map[string]any{"post_code": "12345"}, doc.Data.First().Attributes["home_address"])
+	})
+
+	t.Run("paths", func(t *testing.T) {
+		assert.Equal(t, "blog_posts.co_author", names.DecodePath("blogPosts.coAuthor"))
+	})
+
+	t.Run("marshal option", func(t *testing.T) {
+		doc, err := jsonapi.Marshal(article{ID: "1", FirstLine: "a", CoAuthor: &author{ID: "9"}},
+			jsonapi.WithMemberNames(names))
+		if assert.NoError(t, err) {
+			resource := doc.Data.First()
+			assert.Equal(t, map[string]any{"firstLine": "a"}, resource.Attributes)
+			assert.Contains(t, resource.Relationships, "coAuthor")
+		}
+	})
+
+	t.Run("marshal option copies documents", func(t *testing.T) {
+		resource := &jsonapi.Resource{Type: "articles", ID: "1", Attributes: map[string]any{"first_line": "a"}}
+		doc, err := jsonapi.Marshal(jsonapi.NewMultiDocument(resource), jsonapi.WithMemberNames(names))
+		if assert.NoError(t, err) {
+			assert.Equal(t, map[string]any{"firstLine": "a"}, doc.Data.First().Attributes)
+			assert.Equal(t, map[string]any{"first_line": "a"}, resource.Attributes)
+		}
+	})
+
+	t.Run("unmarshal option", func(t *testing.T) {
+		resource := &jsonapi.Resource{
+			Type:       "articles",
+			ID:         "1",
+			Attributes: map[string]any{"firstLine": "a"},
+			Relationships: jsonapi.RelationshipsNode{
+				"coAuthor": &jsonapi.Relationship{Data: jsonapi.One{Value: &jsonapi.Resource{Type: "people", ID: "9"}}},
+			},
+		}
+		doc := jsonapi.NewSingleDocument(resource)
+		out := article{}
+		if assert.NoError(t, jsonapi.Unmarshal(doc, &out, jsonapi.WithMemberNames(names))) {
+			assert.Equal(t, "a", out.FirstLine)
+			assert.Equal(t, "9", out.CoAuthor.ID)
+		}
+		assert.Contains(t, resource.Attributes, "firstLine")
+	})
+}
@@ -2,6 +2,7 @@ package jsonapi
 
 import (
 	"context"
+	"maps"
 
 	"github.com/gonobo/jsonapi/v2/query"
 )
@@ -23,9 +24,18 @@ type RequestContext struct {
 	Filter       query.FilterExpression // The filter expression that was evaluated from the request query.
 	Sort         []query.Sort           // The sort criteria that was evaluated from the request query.
 	Pagination   query.Page             // The pagination criteria that was evaluated from the request query.
+	Scope        []ParentResource       // The parent resources scoping the request, outermost first, e.g. "organizations/1".
+	Query        QueryValues            // The values of implementation-specific query parameter families, e.g. "search".
 	parent       *RequestContext
 }
 
+// ParentResource identifies a parent resource that scopes the resources of a nested route,
+// e.g. the organization of "/organizations/1/projects".
+type ParentResource struct {
+	Type string // The parent resource type, e.g. "organizations".
+	ID   string // The parent resource ID, e.g. "1".
+}
+
 type contextkey string
 
 const jsonapiContextKey contextkey = "jsonapi_context"
@@ -47,6 +57,7 @@ func (c *RequestContext) EmptyChild() *RequestContext {
 
 // Clone returns a new Context that is a clone of the current Context.
 func (c RequestContext) Clone() *RequestContext {
+	c.Query = maps.Clone(c.Query)
 	return &c
 }
 
@@ -80,6 +91,12 @@ func FromContext(parent context.Context) *RequestContext {
 	return ctx
 }
 
+// LookupContext returns the JSON:API context from the parent context, if it was set.
+func LookupContext(parent context.Context) (*RequestContext, bool) {
+	ctx, ok := parent.Value(jsonapiContextKey).(*RequestContext)
+	return ctx, ok && ctx != nil
+}
+
 // WithContext sets the JSON:API Context in the parent context.
 func WithContext(ctx context.Context, value *RequestContext) context.Context {
 	return context.WithValue(ctx, jsonapiContextKey, value)
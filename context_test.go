@@ -50,3 +50,18 @@ func TestContext(t *testing.T) {
 		assert.NotNil(t, jsonapictx)
 	})
 }
+
+func TestContextQueryValues(t *testing.T) {
+	ctx := jsonapi.RequestContext{Query: jsonapi.QueryValues{"search": "foo"}}
+
+	child := ctx.Child()
+	assert.Equal(t, "foo", child.Query["search"])
+	child.Query["search"] = "bar"
+	assert.Equal(t, "foo", ctx.Query["search"])
+
+	clone := ctx.Clone()
+	clone.Query["stats"] = true
+	assert.NotContains(t, ctx.Query, "stats")
+
+	assert.Nil(t, ctx.EmptyChild().Query)
+}
@@ -322,6 +322,7 @@ func (r *Resource) UnmarshalJSON(data []byte) error {
 	r.Relationships = node.value.Relationships
 	r.Links = node.value.Links
 	r.Meta = node.value.Meta
+	r.Extensions = node.ext
 
 	return err
 }
@@ -0,0 +1,298 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrExtension is returned when an extension cannot be registered.
+	ErrExtension = errors.New("invalid extension")
+)
+
+// ExtensionScope identifies the objects in which an extension member may appear.
+type ExtensionScope int
+
+const (
+	ScopeDocument ExtensionScope = 1 << iota // The member may appear at the top level of a document.
+	ScopeResource                            // The member may appear in resource objects.
+)
+
+// ExtensionMember declares a member defined by an extension. Use [NewExtensionMember]
+// to create new instances.
+type ExtensionMember struct {
+	Name   string         // The member name, without the namespace.
+	Scope  ExtensionScope // The objects in which the member may appear.
+	decode func(*json.RawMessage) (any, error)
+}
+
+// NewExtensionMember declares an extension member whose values are decoded into type T.
+func NewExtensionMember[T any](name string, scope ExtensionScope) ExtensionMember {
+	return ExtensionMember{
+		Name:  name,
+		Scope: scope,
+		decode: func(raw *json.RawMessage) (any, error) {
+			var value T
+			if raw == nil {
+				return value, nil
+			}
+			err := json.Unmarshal(*raw, &value)
+			return value, err
+		},
+	}
+}
+
+// Extension describes a JSON:API extension. Extension members are serialized with
+// the namespace as a prefix, e.g. "version:id".
+// See https://jsonapi.org/format/#extensions for details.
+type Extension struct {
+	URI       string            // The URI that identifies the extension.
+	Namespace string            // The namespace of the extension's members.
+	Members   []ExtensionMember // The members defined by the extension.
+}
+
+func (e Extension) member(name string) (ExtensionMember, bool) {
+	for _, member := range e.Members {
+		if member.Name == name {
+			return member, true
+		}
+	}
+	return ExtensionMember{}, false
+}
+
+// ExtensionValues contains the typed values of extension members, keyed by their
+// namespaced name, e.g. "version:id".
+type ExtensionValues map[string]any
+
+// ExtensionValue returns the typed value of the extension member.
+func ExtensionValue[T any](values ExtensionValues, name string) (T, bool) {
+	value, ok := values[name].(T)
+	return value, ok
+}
+
+// ExtensionRegistry contains the extensions supported by a server or client. The zero
+// value is an empty registry ready to use.
+type ExtensionRegistry struct {
+	extensions []Extension
+}
+
+// NewExtensionRegistry creates a registry containing the provided extensions.
+func NewExtensionRegistry(extensions ...Extension) (*ExtensionRegistry, error) {
+	registry := &ExtensionRegistry{}
+	for _, ext := range extensions {
+		if err := registry.Register(ext); err != nil {
+			return nil, err
+		}
+	}
+	return registry, nil
+}
+
+// Register adds the extension to the registry. Extensions must have a unique URI and
+// namespace; namespaces must contain only alphanumeric characters.
+func (r *ExtensionRegistry) Register(ext Extension) error {
+	if ext.URI == "" {
+		return fmt.Errorf("%w: missing uri", ErrExtension)
+	}
+	if !isExtensionNamespace(ext.Namespace) {
+		return fmt.Errorf("%w: invalid namespace %q", ErrExtension, ext.Namespace)
+	}
+	for _, other := range r.extensions {
+		if other.URI == ext.URI || other.Namespace == ext.Namespace {
+			return fmt.Errorf("%w: %s (%s) is already registered", ErrExtension, ext.URI, ext.Namespace)
+		}
+	}
+	r.extensions = append(r.extensions, ext)
+	return nil
+}
+
+// Lookup returns the extension identified by the URI.
+func (r *ExtensionRegistry) Lookup(uri string) (Extension, bool) {
+	for _, ext := range r.extensions {
+		if ext.URI == uri {
+			return ext, true
+		}
+	}
+	return Extension{}, false
+}
+
+// URIs returns the URIs of all registered extensions.
+func (r *ExtensionRegistry) URIs() []string {
+	uris := make([]string, 0, len(r.extensions))
+	for _, ext := range r.extensions {
+		uris = append(uris, ext.URI)
+	}
+	return uris
+}
+
+// Check validates the extension members of the document -- at the top level and in its
+// primary data and included resources -- against the applied extensions, identified by
+// URI. Members whose namespace does not belong to an applied extension, or that are not
+// declared by their extension in the object's scope, are reported as errors whose
+// source pointer references the member. Null resources are skipped.
+func (r *ExtensionRegistry) Check(doc *Document, applied []string) []*Error {
+	errs := make([]*Error, 0)
+	errs = append(errs, r.check("", doc.Extensions, ScopeDocument, applied)...)
+
+	if doc.Data != nil {
+		for i, item := range doc.Data.Items() {
+			if item == nil {
+				continue
+			}
+			pointer := "/data"
+			if doc.Data.IsMany() {
+				pointer += "/" + strconv.Itoa(i)
+			}
+			errs = append(errs, r.check(pointer, item.Extensions, ScopeResource, applied)...)
+		}
+	}
+
+	for i, item := range doc.Included {
+		if item == nil {
+			continue
+		}
+		errs = append(errs, r.check("/included/"+strconv.Itoa(i), item.Extensions, ScopeResource, applied)...)
+	}
+
+	return errs
+}
+
+func (r *ExtensionRegistry) check(pointer string, node ExtensionsNode, scope ExtensionScope, applied []string) []*Error {
+	errs := make([]*Error, 0)
+	for _, name := range sortedNames(node) {
+		namespace, member, _ := strings.Cut(name, ":")
+		ext, ok := r.namespace(namespace)
+
+		var detail string
+		switch {
+		case !ok:
+			detail = fmt.Sprintf("unknown extension namespace %q", namespace)
+		case !slices.Contains(applied, ext.URI):
+			detail = fmt.Sprintf("extension %s is not applied", ext.URI)
+		default:
+			if declared, ok := ext.member(member); !ok || declared.Scope&scope == 0 {
+				detail = fmt.Sprintf("extension member %q is not declared here", name)
+			}
+		}
+
+		if detail != "" {
+			errs = append(errs, &Error{
+				Status: strconv.Itoa(http.StatusBadRequest),
+				Title:  "Invalid Extension Member",
+				Detail: detail,
+				Source: &ErrorSource{Pointer: pointer + "/" + name},
+			})
+		}
+	}
+	return errs
+}
+
+// DecodeDocument decodes the top-level extension members of the document into typed values.
+// Members that are not declared by a registered extension are ignored.
+func (r *ExtensionRegistry) DecodeDocument(doc *Document) (ExtensionValues, error) {
+	return r.decode(doc.Extensions, ScopeDocument)
+}
+
+// DecodeResource decodes the extension members of the resource into typed values.
+// Members that are not declared by a registered extension are ignored.
+func (r *ExtensionRegistry) DecodeResource(resource *Resource) (ExtensionValues, error) {
+	return r.decode(resource.Extensions, ScopeResource)
+}
+
+func (r *ExtensionRegistry) decode(node ExtensionsNode, scope ExtensionScope) (ExtensionValues, error) {
+	values := make(ExtensionValues)
+	errs := make([]error, 0)
+
+	for name, raw := range node {
+		namespace, member, _ := strings.Cut(name, ":")
+		ext, ok := r.namespace(namespace)
+		if !ok {
+			continue
+		}
+		declared, ok := ext.member(member)
+		if !ok || declared.Scope&scope == 0 {
+			continue
+		}
+		value, err := declared.decode(raw)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("decode extension member %s: %w", name, err))
+			continue
+		}
+		values[name] = value
+	}
+
+	return values, errors.Join(errs...)
+}
+
+// ApplyExtensions lists the URIs of the registered extensions whose members appear in the
+// document in the document's "jsonapi" member, and returns them.
+func (r *ExtensionRegistry) ApplyExtensions(doc *Document) []string {
+	used := make(map[string]bool)
+	mark := func(node ExtensionsNode) {
+		for name := range node {
+			namespace, _, _ := strings.Cut(name, ":")
+			used[namespace] = true
+		}
+	}
+
+	mark(doc.Extensions)
+	if doc.Data != nil {
+		for _, item := range doc.Data.Items() {
+			if item != nil {
+				mark(item.Extensions)
+			}
+		}
+	}
+	for _, item := range doc.Included {
+		if item != nil {
+			mark(item.Extensions)
+		}
+	}
+
+	uris := make([]string, 0)
+	for _, ext := range r.extensions {
+		if used[ext.Namespace] {
+			uris = append(uris, ext.URI)
+			if !slices.Contains(doc.Jsonapi.Ext, ext.URI) {
+				doc.Jsonapi.Ext = append(doc.Jsonapi.Ext, ext.URI)
+			}
+		}
+	}
+
+	return uris
+}
+
+func (r *ExtensionRegistry) namespace(namespace string) (Extension, bool) {
+	for _, ext := range r.extensions {
+		if ext.Namespace == namespace {
+			return ext, true
+		}
+	}
+	return Extension{}, false
+}
+
+func isExtensionNamespace(namespace string) bool {
+	if namespace == "" {
+		return false
+	}
+	for _, r := range namespace {
+		if !(r >= 'a' && r <= 'z') && !(r >= 'A' && r <= 'Z') && !(r >= '0' && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// sortedNames returns the member names of the node in lexical order.
+func sortedNames(node ExtensionsNode) []string {
+	names := make([]string, 0, len(node))
+	for name := range node {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
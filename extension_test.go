@@ -0,0 +1,152 @@
+package jsonapi_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/gonobo/jsonapi/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+type versionInfo struct {
+	ID     string `json:"id"`
+	Latest bool   `json:"latest"`
+}
+
+var versionExtension = jsonapi.Extension{
+	URI:       "https://example.com/ext/version",
+	Namespace: "version",
+	Members: []jsonapi.ExtensionMember{
+		jsonapi.NewExtensionMember[string]("id", jsonapi.ScopeDocument|jsonapi.ScopeResource),
+		jsonapi.NewExtensionMember[versionInfo]("info", jsonapi.ScopeResource),
+	},
+}
+
+func TestExtensionRegistryRegister(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		ext     jsonapi.Extension
+		wantErr bool
+	}{
+		{name: "valid extension", ext: jsonapi.Extension{URI: "https://example.com/ext/other", Namespace: "other"}},
+		{name: "missing uri", ext: jsonapi.Extension{Namespace: "other"}, wantErr: true},
+		{name: "invalid namespace", ext: jsonapi.Extension{URI: "https://example.com/ext/other", Namespace: "other-ns"}, wantErr: true},
+		{name: "duplicate uri", ext: jsonapi.Extension{URI: versionExtension.URI, Namespace: "other"}, wantErr: true},
+		{name: "duplicate namespace", ext: jsonapi.Extension{URI: "https://example.com/ext/other", Namespace: "version"}, wantErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			registry, err := jsonapi.NewExtensionRegistry(versionExtension)
+			assert.NoError(t, err)
+
+			err = registry.Register(tc.ext)
+			if tc.wantErr {
+				assert.ErrorIs(t, err, jsonapi.ErrExtension)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, []string{versionExtension.URI, tc.ext.URI}, registry.URIs())
+		})
+	}
+}
+
+func TestExtensionRegistryCheck(t *testing.T) {
+	registry, _ := jsonapi.NewExtensionRegistry(versionExtension)
+	applied := []string{versionExtension.URI}
+
+	for _, tc := range []struct {
+		name    string
+		body    string
+		applied []string
+		want    []string
+	}{
+		{
+			name:    "declared members",
+			body:    `{"version:id":"v1","data":{"type":"articles","id":"1","version:info":{"id":"v1"}}}`,
+			applied: applied,
+			want:    []string{},
+		},
+		{
+			name:    "extension not applied",
+			body:    `{"version:id":"v1","data":null}`,
+			applied: nil,
+			want:    []string{"/version:id"},
+		},
+		{
+			name:    "unknown namespace",
+			body:    `{"atomic:operations":[],"data":[{"type":"articles","id":"1","atomic:ref":{}}]}`,
+			applied: applied,
+			want:    []string{"/atomic:operations", "/data/0/atomic:ref"},
+		},
+		{
+			name:    "undeclared member and scope",
+			body:    `{"version:info":{},"data":null,"included":[{"type":"people","id":"1","version:other":1}]}`,
+			applied: applied,
+			want:    []string{"/version:info", "/included/0/version:other"},
+		},
+		{
+			name:    "null resources",
+			body:    `{"data":[null,{"type":"articles","id":"1","atomic:ref":{}}],"included":[null]}`,
+			applied: applied,
+			want:    []string{"/data/1/atomic:ref"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			doc := jsonapi.Document{}
+			assert.NoError(t, jsonapi.Decode(strings.NewReader(tc.body), &doc))
+
+			pointers := make([]string, 0)
+			for _, err := range registry.Check(&doc, tc.applied) {
+				assert.Equal(t, "400", err.Status)
+				pointers = append(pointers, err.Source.Pointer)
+			}
+			assert.Equal(t, tc.want, pointers)
+		})
+	}
+}
+
+func TestExtensionRegistryDecode(t *testing.T) {
+	registry, _ := jsonapi.NewExtensionRegistry(versionExtension)
+
+	body := `{"version:id":"v2","data":{"type":"articles","id":"1","version:info":{"id":"v1","latest":true}}}`
+	doc := jsonapi.Document{}
+	assert.NoError(t, jsonapi.Decode(strings.NewReader(body), &doc))
+
+	values, err := registry.DecodeDocument(&doc)
+	assert.NoError(t, err)
+	id, ok := jsonapi.ExtensionValue[string](values, "version:id")
+	assert.True(t, ok)
+	assert.Equal(t, "v2", id)
+
+	values, err = registry.DecodeResource(doc.Data.First())
+	assert.NoError(t, err)
+	info, ok := jsonapi.ExtensionValue[versionInfo](values, "version:info")
+	assert.True(t, ok)
+	assert.Equal(t, versionInfo{ID: "v1", Latest: true}, info)
+
+	raw := json.RawMessage(`42`)
+	doc.Extensions["version:id"] = &raw
+	_, err = registry.DecodeDocument(&doc)
+	assert.Error(t, err)
+}
+
+func TestExtensionRegistryApplyExtensions(t *testing.T) {
+	registry, _ := jsonapi.NewExtensionRegistry(versionExtension,
+		jsonapi.Extension{URI: "https://example.com/ext/other", Namespace: "other"})
+
+	raw := json.RawMessage(`"v1"`)
+	doc := jsonapi.NewSingleDocument(&jsonapi.Resource{
+		Type:       "articles",
+		ID:         "1",
+		Extensions: jsonapi.ExtensionsNode{"version:id": &raw},
+	})
+
+	assert.Equal(t, []string{versionExtension.URI}, registry.ApplyExtensions(doc))
+	assert.Equal(t, []string{versionExtension.URI}, doc.Jsonapi.Ext)
+
+	registry.ApplyExtensions(doc)
+	assert.Equal(t, []string{versionExtension.URI}, doc.Jsonapi.Ext, "uris are not duplicated")
+
+	nulls := &jsonapi.Document{Data: jsonapi.Many{Value: []*jsonapi.Resource{nil}}, Included: []*jsonapi.Resource{nil}}
+	assert.Empty(t, registry.ApplyExtensions(nulls))
+}
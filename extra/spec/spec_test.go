@@ -1,10 +1,13 @@
 package spec_test
 
 import (
+	"encoding/json"
+	"sort"
 	"testing"
 
 	"github.com/gonobo/jsonapi/v2"
 	"github.com/gonobo/jsonapi/v2/extra/spec"
+	"github.com/gonobo/validator"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -17,3 +20,142 @@ func TestValidation(t *testing.T) {
 		assert.NoError(t, doc.Validate(spec.Validator{}), "unknown version has no validation")
 	})
 }
+
+func TestValidator(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		validator    spec.Validator
+		doc          string
+		wantPointers []string
+	}{
+		{
+			name: "valid compound document",
+			doc: `{
+				"data": {"type": "articles", "id": "1", "attributes": {"title": "a", "first-name": "b"},
+					"relationships": {"author": {"data": {"type": "people", "id": "9"}, "links": {"related": "/articles/1/author"}}}},
+				"included": [
+					{"type": "people", "id": "9", "relationships": {"photo": {"data": {"type": "photos", "id": "2"}}}},
+					{"type": "photos", "id": "2"}
+				],
+				"links": {"self": {"href": "/articles/1", "title": "self"}}
+			}`,
+		},
+		{
+			name:         "data and errors",
+			doc:          `{"data": null, "errors": [{"title": "oops"}]}`,
+			wantPointers: []string{""},
+		},
+		{
+			name:         "included without data",
+			doc:          `{"meta": {"a": 1}, "included": [{"type": "people", "id": "9"}]}`,
+			wantPointers: []string{"/included", "/included/0"},
+		},
+		{
+			name: "included without full linkage",
+			doc: `{
+				"data": [{"type": "articles", "id": "1"}],
+				"included": [{"type": "people", "id": "9"}, {"type": "people", "id": "9"}]
+			}`,
+			wantPointers: []string{"/included/0", "/included/1", "/included/1"},
+		},
+		{
+			name: "resource identifier rules",
+			doc: `{"data": [
+				{"type": "articles"},
+				{"id": "2", "relationships": {"tags": {"data": [{"type": "tags"}, {"type": "tags", "id": "1", "attributes": {"a": 1}}]}}}
+			]}`,
+			wantPointers: []string{
+				"/data/1/relationships/tags/data/0/id",
+				"/data/1/relationships/tags/data/1/attributes",
+				"/data/1/type",
+			},
+		},
+		{
+			name: "member names",
+			doc: `{"data": {"type": "articles", "id": "1", "attributes": {
+				"-title": 1, "a+b": 2, "id": 3, "nested": {"links": 1, "ok_name": [{"bad!": 2}]}
+			}}}`,
+			wantPointers: []string{
+				"/data/attributes/-title",
+				"/data/attributes/a+b",
+				"/data/attributes/id",
+				"/data/attributes/nested/links",
+				"/data/attributes/nested/ok_name/0/bad!",
+			},
+		},
+		{
+			name: "field conflicts",
+			doc: `{"data": {"type": "articles", "id": "1", "attributes": {"author": "a"},
+				"relationships": {"author": {"meta": {}}, "empty": {}}}}`,
+			wantPointers: []string{"/data/relationships/author", "/data/relationships/empty"},
+		},
+		{
+			name: "link shape",
+			doc: `{"meta": {"a": 1}, "links": {"self": {"title": "missing href"}, "next": null},
+				"errors": [{"source": {"pointer": "data"}}]}`,
+			wantPointers: []string{"/errors/0/source/pointer", "/links/self/href"},
+		},
+		{
+			name: "relationship links",
+			doc: `{"data": {"type": "articles", "id": "1",
+				"relationships": {"author": {"links": {"about": "/about"}}}}}`,
+			wantPointers: []string{"/data/relationships/author/links"},
+		},
+		{
+			name:         "version 1.0 restrictions",
+			doc:          `{"jsonapi": {"version": "1.0", "ext": ["https://example.com/ext"]}, "data": {"type": "articles", "lid": "x"}, "links": {"self": {"href": "/a", "rel": "self"}}, "errors": [{"source": {"header": "Accept"}}]}`,
+			wantPointers: []string{"", "/data/lid", "/errors/0/source/header", "/jsonapi/ext", "/links/self/rel"},
+		},
+		{
+			name:         "extension members",
+			doc:          `{"meta": {"a": 1}, "atomic:operations": [], "bad-ns:x": 1, "version:": 2}`,
+			validator:    spec.Validator{Namespaces: []string{"atomic", "version"}},
+			wantPointers: []string{"/bad-ns:x", "/bad-ns:x", "/version:"},
+		},
+		{
+			name:         "extension members in 1.0",
+			doc:          `{"jsonapi": {"version": "1.0"}, "meta": {"a": 1}, "atomic:operations": []}`,
+			wantPointers: []string{"/atomic:operations"},
+		},
+		{
+			name:         "null resource linkage",
+			doc:          `{"data":{"type":"a","id":"1","relationships":{"b":{"data":[null]}}}}`,
+			wantPointers: []string{"/data/relationships/b/data/0"},
+		},
+		{
+			name:         "null primary data item with included",
+			doc:          `{"data":[null],"included":[{"type":"b","id":"1"}]}`,
+			wantPointers: []string{"/data/0", "/included/0"},
+		},
+		{
+			name:         "null included resource",
+			doc:          `{"data":{"type":"a","id":"1"},"included":[null]}`,
+			wantPointers: []string{"/included/0"},
+		},
+		{
+			name: "at-members",
+			doc:  `{"meta": {"@context": "https://schema.org"}}`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			doc := jsonapi.Document{}
+			if err := json.Unmarshal([]byte(tc.doc), &doc); err != nil {
+				t.Fatal(err)
+			}
+
+			err := tc.validator.ValidateDocument(&doc)
+			if len(tc.wantPointers) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+
+			assert.ErrorIs(t, err, validator.ErrInvalid)
+			pointers := make([]string, 0)
+			for _, violation := range spec.Violations(err) {
+				pointers = append(pointers, violation.Pointer)
+			}
+			sort.Strings(pointers)
+			assert.Equal(t, tc.wantPointers, pointers, "violations: %v", err)
+		})
+	}
+}
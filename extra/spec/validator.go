@@ -3,27 +3,73 @@ package spec
 import (
 	"errors"
 	"fmt"
+	"slices"
+	"strconv"
+	"strings"
 
 	"github.com/gonobo/jsonapi/v2"
 	"github.com/gonobo/validator"
 )
 
 const (
+	Version10 = "1.0"
 	Version11 = "1.1"
 )
 
-type Validator struct{}
+// Validator validates documents against the JSON:API specification. The version is read
+// from the document's "jsonapi" member; documents of unknown versions are not validated.
+//
+// The returned error wraps [validator.ErrInvalid] and every [Violation] found in the
+// document; use [Violations] to retrieve them.
+type Validator struct {
+	// Namespaces lists the namespaces of the extensions applied to validated documents.
+	// If nil, extension members are only checked for well-formedness.
+	Namespaces []string
+}
 
+// ValidateDocument validates the document against its specification version.
 func (v Validator) ValidateDocument(doc *jsonapi.Document) error {
 	var validator documentValidator = baseSpec{}
 	version := doc.Jsonapi.Version.Value()
 	switch version {
-	case Version11:
-		validator = spec11{}
+	case Version10, Version11:
+		validator = spec{version: version, namespaces: v.Namespaces}
 	}
 	return validator.validate(doc)
 }
 
+// Violation describes a specification rule violated by a document.
+type Violation struct {
+	Pointer string // A JSON pointer [RFC6901] to the offending value; empty for the whole document.
+	Message string // A description of the violated rule.
+}
+
+// Error returns the pointer and message of the violation.
+func (v Violation) Error() string {
+	return fmt.Sprintf("%q: %s", v.Pointer, v.Message)
+}
+
+// Violations returns the violations reported by the error.
+func Violations(err error) []Violation {
+	violations := make([]Violation, 0)
+	var walk func(error)
+	walk = func(err error) {
+		switch e := err.(type) {
+		case nil:
+		case Violation:
+			violations = append(violations, e)
+		case interface{ Unwrap() []error }:
+			for _, inner := range e.Unwrap() {
+				walk(inner)
+			}
+		case interface{ Unwrap() error }:
+			walk(e.Unwrap())
+		}
+	}
+	walk(err)
+	return violations
+}
+
 type documentValidator interface {
 	validate(*jsonapi.Document) error
 }
@@ -34,26 +80,337 @@ func (baseSpec) validate(*jsonapi.Document) error {
 	return nil
 }
 
-type spec11 struct{}
+// spec validates documents against versions 1.0 and 1.1 of the specification.
+type spec struct {
+	version    string
+	namespaces []string
+	errs       []error
+}
+
+func (s spec) validate(d *jsonapi.Document) error {
+	s.checkTopLevelMembers(d)
+	s.checkJSONAPI(d.Jsonapi)
+	s.checkPrimaryData(d)
+	s.checkIncluded(d)
+	s.checkLinks("/links", d.Links)
+	s.checkMeta("/meta", d.Meta)
+	s.checkErrors(d.Errors)
+	s.checkExtensions("", d.Extensions)
 
-func (s spec11) validate(d *jsonapi.Document) error {
-	err := errors.Join(
-		baseSpec{}.validate(d),
-		s.checkMissingTopLevelMembers(d),
-	)
-	if err != nil {
-		err = fmt.Errorf("spec 1.1: %w", err)
+	if len(s.errs) == 0 {
+		return nil
 	}
-	return err
+
+	return fmt.Errorf("spec %s: %w: %w", s.version, validator.ErrInvalid, errors.Join(s.errs...))
 }
 
-func (spec11) checkMissingTopLevelMembers(d *jsonapi.Document) error {
+func (s *spec) v11() bool {
+	return s.version == Version11
+}
+
+// report records a violation if the test fails.
+func (s *spec) report(test bool, pointer string, format string, args ...any) bool {
+	if !test {
+		s.errs = append(s.errs, Violation{Pointer: pointer, Message: fmt.Sprintf(format, args...)})
+	}
+	return test
+}
+
+func (s *spec) checkTopLevelMembers(d *jsonapi.Document) {
 	// A document must contain at least the following top-level members:
 	//	- data
 	//	- errors
 	//	- meta
 	valid := d.Data != nil || len(d.Errors) > 0 || len(d.Meta) > 0
-	return validator.Validate(
-		validator.Rule(valid, "document must contain at least 'data', 'errors', or 'meta' members"),
-	)
+	s.report(valid, "", "document must contain at least 'data', 'errors', or 'meta' members")
+	s.report(d.Data == nil || len(d.Errors) == 0, "", "document must not contain both 'data' and 'errors' members")
+	s.report(d.Data != nil || len(d.Included) == 0, "/included", "document must not contain 'included' without 'data'")
+}
+
+func (s *spec) checkJSONAPI(j jsonapi.JSONAPI) {
+	if !s.v11() {
+		s.report(len(j.Ext) == 0, "/jsonapi/ext", "'ext' member requires version 1.1")
+		s.report(len(j.Profile) == 0, "/jsonapi/profile", "'profile' member requires version 1.1")
+	}
+	s.checkMeta("/jsonapi/meta", j.Meta)
+}
+
+func (s *spec) checkPrimaryData(d *jsonapi.Document) {
+	if d.Data == nil {
+		return
+	}
+	for i, item := range d.Data.Items() {
+		pointer := "/data"
+		if d.Data.IsMany() {
+			pointer = "/data/" + strconv.Itoa(i)
+			s.report(item != nil, pointer, "primary data array must not contain null")
+		}
+		s.checkResource(pointer, item, true)
+	}
+}
+
+func (s *spec) checkIncluded(d *jsonapi.Document) {
+	if len(d.Included) == 0 {
+		return
+	}
+
+	// every included resource must be linked from primary data, directly or through
+	// other included resources (full linkage).
+	primary := make(map[string]bool)
+	linked := make(map[string]bool)
+	queue := make([]*jsonapi.Resource, 0)
+
+	if d.Data != nil {
+		for _, item := range d.Data.Items() {
+			if item == nil {
+				continue
+			}
+			primary[resourceKey(item)] = true
+			queue = append(queue, item)
+		}
+	}
+
+	included := make(map[string]*jsonapi.Resource)
+	for i, item := range d.Included {
+		pointer := "/included/" + strconv.Itoa(i)
+		if !s.report(item != nil, pointer, "included resources must not be null") {
+			continue
+		}
+		s.checkResource(pointer, item, false)
+		key := resourceKey(item)
+		s.report(!primary[key] && included[key] == nil, pointer,
+			"compound document must not contain more than one resource object for %s", key)
+		if included[key] == nil {
+			included[key] = item
+		}
+	}
+
+	for len(queue) > 0 {
+		item := queue[0]
+		queue = queue[1:]
+		for _, rel := range item.Relationships {
+			if rel == nil || rel.Data == nil {
+				continue
+			}
+			for _, ref := range rel.Data.Items() {
+				if ref == nil {
+					continue
+				}
+				key := resourceKey(ref)
+				if linked[key] || included[key] == nil {
+					continue
+				}
+				linked[key] = true
+				queue = append(queue, included[key])
+			}
+		}
+	}
+
+	for i, item := range d.Included {
+		if item == nil {
+			continue
+		}
+		key := resourceKey(item)
+		s.report(linked[key] || primary[key], "/included/"+strconv.Itoa(i),
+			"included resource %s is not linked from primary data or other included resources", key)
+	}
+}
+
+// checkResource validates a resource object. Primary data resources may omit an id,
+// since clients create resources without one.
+func (s *spec) checkResource(pointer string, r *jsonapi.Resource, primary bool) {
+	if r == nil {
+		return
+	}
+
+	if s.report(r.Type != "", pointer+"/type", "resource must contain a 'type' member") {
+		s.checkMemberName(pointer+"/type", r.Type, "resource type")
+	}
+	s.checkLocalID(pointer, r)
+	s.report(primary || r.ID != "" || (s.v11() && r.LocalID != ""), pointer+"/id",
+		"resource must contain an 'id' member")
+
+	for name, value := range r.Attributes {
+		attrPointer := pointer + "/attributes/" + escape(name)
+		s.checkMemberName(attrPointer, name, "attribute")
+		s.report(name != "id" && name != "type", attrPointer, "attribute must not be named %q", name)
+		s.checkValue(attrPointer, value, true)
+	}
+
+	for name, rel := range r.Relationships {
+		relPointer := pointer + "/relationships/" + escape(name)
+		s.checkMemberName(relPointer, name, "relationship")
+		s.report(name != "id" && name != "type", relPointer, "relationship must not be named %q", name)
+		_, conflict := r.Attributes[name]
+		s.report(!conflict, relPointer, "relationship %q conflicts with attribute of the same name", name)
+		s.checkRelationship(relPointer, rel)
+	}
+
+	s.checkLinks(pointer+"/links", r.Links)
+	s.checkMeta(pointer+"/meta", r.Meta)
+	s.checkExtensions(pointer, r.Extensions)
+}
+
+func (s *spec) checkRelationship(pointer string, r *jsonapi.Relationship) {
+	if r == nil {
+		return
+	}
+
+	s.report(r.Data != nil || r.Links != nil || r.Meta != nil, pointer,
+		"relationship must contain at least 'links', 'data', or 'meta' members")
+
+	if r.Links != nil {
+		_, self := r.Links["self"]
+		_, related := r.Links["related"]
+		s.report(self || related, pointer+"/links", "relationship links must contain 'self' or 'related' members")
+		s.checkLinks(pointer+"/links", r.Links)
+	}
+
+	s.checkMeta(pointer+"/meta", r.Meta)
+
+	if r.Data == nil {
+		return
+	}
+
+	for i, ref := range r.Data.Items() {
+		refPointer := pointer + "/data"
+		if r.Data.IsMany() {
+			refPointer += "/" + strconv.Itoa(i)
+		}
+		s.checkIdentifier(refPointer, ref)
+	}
+}
+
+// checkIdentifier validates a resource identifier object.
+func (s *spec) checkIdentifier(pointer string, r *jsonapi.Resource) {
+	if !s.report(r != nil, pointer, "resource linkage must not contain null") {
+		return
+	}
+	s.report(r.Type != "", pointer+"/type", "resource identifier must contain a 'type' member")
+	s.report(r.ID != "" || (s.v11() && r.LocalID != ""), pointer+"/id",
+		"resource identifier must contain an 'id' member")
+	s.checkLocalID(pointer, r)
+	s.report(r.Attributes == nil, pointer+"/attributes", "resource identifier must not contain attributes")
+	s.report(r.Relationships == nil, pointer+"/relationships", "resource identifier must not contain relationships")
+	s.report(r.Links == nil, pointer+"/links", "resource identifier must not contain links")
+	s.checkMeta(pointer+"/meta", r.Meta)
+}
+
+func (s *spec) checkLocalID(pointer string, r *jsonapi.Resource) {
+	if r.LocalID != "" {
+		s.report(s.v11(), pointer+"/lid", "'lid' member requires version 1.1")
+	}
+}
+
+func (s *spec) checkLinks(pointer string, links jsonapi.Links) {
+	for name, link := range links {
+		linkPointer := pointer + "/" + escape(name)
+		s.checkMemberName(linkPointer, name, "link")
+		if link == nil {
+			continue
+		}
+		s.report(link.Href != "", linkPointer+"/href", "link object must contain an 'href' member")
+		if !s.v11() {
+			s.report(link.Rel == "", linkPointer+"/rel", "'rel' member requires version 1.1")
+			s.report(link.Type == "", linkPointer+"/type", "'type' member requires version 1.1")
+			s.report(link.Title == "", linkPointer+"/title", "'title' member requires version 1.1")
+			s.report(len(link.HrefLang) == 0, linkPointer+"/hreflang", "'hreflang' member requires version 1.1")
+		}
+		s.checkMeta(linkPointer+"/meta", link.Meta)
+	}
+}
+
+func (s *spec) checkMeta(pointer string, meta jsonapi.Meta) {
+	for name, value := range meta {
+		metaPointer := pointer + "/" + escape(name)
+		s.checkMemberName(metaPointer, name, "meta")
+		s.checkValue(metaPointer, value, false)
+	}
+}
+
+func (s *spec) checkErrors(errs []*jsonapi.Error) {
+	for i, e := range errs {
+		if e == nil {
+			continue
+		}
+		pointer := "/errors/" + strconv.Itoa(i)
+		s.checkLinks(pointer+"/links", e.Links)
+		s.checkMeta(pointer+"/meta", e.Meta)
+		if e.Source == nil {
+			continue
+		}
+		s.report(e.Source.Pointer == "" || strings.HasPrefix(e.Source.Pointer, "/"),
+			pointer+"/source/pointer", "source pointer must be a JSON pointer")
+		if !s.v11() {
+			s.report(e.Source.Header == "", pointer+"/source/header", "'header' member requires version 1.1")
+		}
+	}
+}
+
+// checkExtensions validates the extension members of an object.
+func (s *spec) checkExtensions(pointer string, ext jsonapi.ExtensionsNode) {
+	for name := range ext {
+		extPointer := pointer + "/" + escape(name)
+		if !s.report(s.v11(), extPointer, "extension members require version 1.1") {
+			continue
+		}
+
+		namespace, member, _ := strings.Cut(name, ":")
+		valid := namespace != "" && strings.IndexFunc(namespace, func(r rune) bool { return !isAlphanumeric(r) }) < 0
+		s.report(valid, extPointer, "extension namespace %q must contain only alphanumeric characters", namespace)
+		s.checkMemberName(extPointer, member, "extension")
+		if s.namespaces != nil {
+			s.report(slices.Contains(s.namespaces, namespace), extPointer, "extension namespace %q is not applied", namespace)
+		}
+	}
+}
+
+// checkValue validates the member names of the objects nested in the value. Objects
+// within attribute values must not contain 'relationships' or 'links' members.
+func (s *spec) checkValue(pointer string, value any, attribute bool) {
+	switch v := value.(type) {
+	case map[string]any:
+		for name, item := range v {
+			itemPointer := pointer + "/" + escape(name)
+			s.checkMemberName(itemPointer, name, "member")
+			if attribute {
+				s.report(name != "relationships" && name != "links", itemPointer,
+					"member name %q is reserved within attribute values", name)
+			}
+			s.checkValue(itemPointer, item, attribute)
+		}
+	case []any:
+		for i, item := range v {
+			s.checkValue(pointer+"/"+strconv.Itoa(i), item, attribute)
+		}
+	}
+}
+
+// checkMemberName validates a member name against the specification's naming rules.
+func (s *spec) checkMemberName(pointer string, name string, kind string) {
+	if s.v11() && strings.HasPrefix(name, "@") {
+		// @-members are ignored by the specification.
+		name = strings.TrimPrefix(name, "@")
+	}
+
+	if !s.report(name != "", pointer, "%s name must contain at least one character", kind) {
+		return
+	}
+	s.report(jsonapi.IsMemberName(name), pointer, "%s name %q is not a legal member name", kind, name)
+}
+
+func isAlphanumeric(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
+}
+
+func resourceKey(r *jsonapi.Resource) string {
+	if r.ID == "" && r.LocalID != "" {
+		return r.Type + ":lid:" + r.LocalID
+	}
+	return r.Type + ":" + r.ID
+}
+
+// escape escapes a member name for use as a JSON pointer reference token.
+func escape(name string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(name)
 }
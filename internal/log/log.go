@@ -1,31 +1,26 @@
 package log
 
 import (
-	"io"
-	"log"
+	"context"
+	"log/slog"
 	"os"
 	"strconv"
 )
 
-var (
-	writer   = log.Default().Writer()
-	flags    = log.Default().Flags()
-	instance = log.New(writer, "gonobo/jsonapi", flags)
-)
-
-func init() {
-	debug := os.Getenv("JSONAPI_DEBUG")
-	if enabled, err := strconv.ParseBool(debug); err != nil {
-		instance.SetOutput(io.Discard)
-	} else if !enabled {
-		instance.SetOutput(io.Discard)
+// Default returns the logger used when none is configured. Records are discarded,
+// unless the JSONAPI_DEBUG environment variable is true; in that case, they are
+// passed to [slog.Default].
+func Default() *slog.Logger {
+	if enabled, err := strconv.ParseBool(os.Getenv("JSONAPI_DEBUG")); err == nil && enabled {
+		return slog.Default()
 	}
+	return slog.New(discardHandler{})
 }
 
-func Printf(format string, args ...any) {
-	instance.Printf(format, args...)
-}
+// discardHandler discards all log records.
+type discardHandler struct{}
 
-func Println(args ...any) {
-	instance.Println(args...)
-}
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (d discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return d }
+func (d discardHandler) WithGroup(string) slog.Handler           { return d }
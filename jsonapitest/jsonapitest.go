@@ -1,8 +1,11 @@
 package jsonapitest
 
 import (
+	"bytes"
 	"encoding/json"
+	"io"
 	"testing"
+	"testing/iotest"
 
 	"github.com/gonobo/jsonapi/v2"
 	"github.com/stretchr/testify/assert"
@@ -17,18 +20,35 @@ func MarshalRaw(t *testing.T, value any) *json.RawMessage {
 	return raw
 }
 
-// Body is a wrapper around jsonapi.Document that implements the io.Reader
-// interface. Use as a payload in httptest.NewRequest() calls.
+// Body is a wrapper around jsonapi.Document. Use its Reader() as a payload
+// in httptest.NewRequest() calls.
 type Body jsonapi.Document
 
-// Read implements the io.Reader interface.
+// Reader returns an io.Reader over the JSON encoding of the document.
+func (p Body) Reader() io.Reader {
+	data, err := json.Marshal(jsonapi.Document(p))
+	if err != nil {
+		return iotest.ErrReader(err)
+	}
+	return bytes.NewReader(data)
+}
+
+// Read implements the io.Reader interface. Since Body is a value, it cannot track how much
+// of the document was read: each call copies the JSON encoding of the document into b, and
+// reports io.EOF if it fits, or io.ErrShortBuffer if it does not.
+//
+// Deprecated: Read cannot read documents larger than b. Use Reader instead.
 func (p Body) Read(b []byte) (int, error) {
-	data, err := json.Marshal(p)
+	data, err := json.Marshal(jsonapi.Document(p))
 	if err != nil {
 		return 0, err
 	}
 
-	return copy(b, data), nil
+	n := copy(b, data)
+	if n < len(data) {
+		return n, io.ErrShortBuffer
+	}
+	return n, io.EOF
 }
 
 func AssertJSONAPIEq(t *testing.T, want string, got string, msgAndArgs ...any) bool {
@@ -19,13 +19,41 @@ const (
 	tagDelimiter = ","
 )
 
+// MarshalConfig configures [Marshal] and [Unmarshal].
+type MarshalConfig struct {
+	names *MemberNames
+}
+
+// WithMemberNames converts member names between the canonical naming convention of struct
+// tags and the wire convention: [Marshal] returns documents with wire member names, and
+// [Unmarshal] accepts them. The resources of the input document are not modified.
+func WithMemberNames(names MemberNames) func(*MarshalConfig) {
+	return func(c *MarshalConfig) {
+		c.names = &names
+	}
+}
+
 // Marshal generates a JSON:API document from the specified value. If the value
 // is a struct, then a single document is returned, using the value as primary data.
 // If the value is a slice or array, then a many document is returned, using the
 // value as primary data.
 //
 // Marshaling Document structs simply returns a copy of the instance.
-func Marshal(in any) (Document, error) {
+func Marshal(in any, options ...func(*MarshalConfig)) (Document, error) {
+	cfg := MarshalConfig{}
+	for _, option := range options {
+		option(&cfg)
+	}
+
+	doc, err := marshal(in)
+	if err != nil || cfg.names == nil {
+		return doc, err
+	}
+
+	return convertedDocument(doc, cfg.names.Encode), nil
+}
+
+func marshal(in any) (Document, error) {
 	// if the input is already a document, return it.
 	if doc, ok := in.(Document); ok {
 		return doc, nil
@@ -191,7 +219,17 @@ func MarshalRaw(value any) (*json.RawMessage, error) {
 // stored inside the provided document. Struct fields must either be properly
 // tagged with "jsonapi:" or the struct must implement the
 // UnmarshalResourceJSONAPI() method.
-func Unmarshal(doc *Document, out any) error {
+func Unmarshal(doc *Document, out any, options ...func(*MarshalConfig)) error {
+	cfg := MarshalConfig{}
+	for _, option := range options {
+		option(&cfg)
+	}
+
+	if cfg.names != nil && doc != nil {
+		decoded := convertedDocument(*doc, cfg.names.Decode)
+		doc = &decoded
+	}
+
 	// use reflection to determine if the document has single or multiple primary data.
 	rtype := reflect.TypeOf(out)
 
@@ -0,0 +1,138 @@
+package jsonapi
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+var (
+	// ErrProfile is returned when a profile cannot be registered.
+	ErrProfile = errors.New("invalid profile")
+)
+
+// LinkProfile is the name of the top-level link that references the profile applied
+// to a document.
+const LinkProfile = "profile"
+
+// Profile describes a JSON:API profile: a set of additional semantics applied to
+// documents, identified by a URI. Profiles may define hooks that are invoked with
+// the documents they are applied to.
+// See https://jsonapi.org/format/#profiles for details.
+type Profile struct {
+	URI string // The URI that identifies the profile.
+	// Decode, if set, is invoked with request documents the profile is applied to.
+	// Errors indicate that the document does not follow the profile.
+	Decode func(*Document) error
+	// Encode, if set, is invoked with response documents before the profile is listed
+	// in their "jsonapi" member.
+	Encode func(*Document) error
+}
+
+// ProfileRegistry contains the profiles supported by a server or client. The zero
+// value is an empty registry ready to use.
+type ProfileRegistry struct {
+	profiles []Profile
+}
+
+// NewProfileRegistry creates a registry containing the provided profiles.
+func NewProfileRegistry(profiles ...Profile) (*ProfileRegistry, error) {
+	registry := &ProfileRegistry{}
+	for _, profile := range profiles {
+		if err := registry.Register(profile); err != nil {
+			return nil, err
+		}
+	}
+	return registry, nil
+}
+
+// Register adds the profile to the registry. Profiles must have a unique URI.
+func (r *ProfileRegistry) Register(profile Profile) error {
+	if profile.URI == "" {
+		return fmt.Errorf("%w: missing uri", ErrProfile)
+	}
+	if _, ok := r.Lookup(profile.URI); ok {
+		return fmt.Errorf("%w: %s is already registered", ErrProfile, profile.URI)
+	}
+	r.profiles = append(r.profiles, profile)
+	return nil
+}
+
+// Lookup returns the profile identified by the URI.
+func (r *ProfileRegistry) Lookup(uri string) (Profile, bool) {
+	for _, profile := range r.profiles {
+		if profile.URI == uri {
+			return profile, true
+		}
+	}
+	return Profile{}, false
+}
+
+// URIs returns the URIs of all registered profiles.
+func (r *ProfileRegistry) URIs() []string {
+	uris := make([]string, 0, len(r.profiles))
+	for _, profile := range r.profiles {
+		uris = append(uris, profile.URI)
+	}
+	return uris
+}
+
+// Supported returns the URIs of the registered profiles, in the order requested.
+// As required by the specification, unknown profiles are ignored.
+func (r *ProfileRegistry) Supported(uris []string) []string {
+	supported := make([]string, 0, len(uris))
+	for _, uri := range uris {
+		if _, ok := r.Lookup(uri); ok && !slices.Contains(supported, uri) {
+			supported = append(supported, uri)
+		}
+	}
+	return supported
+}
+
+// DecodeDocument invokes the Decode hooks of the applied profiles, identified by URI,
+// with the document. Unknown profiles are ignored.
+func (r *ProfileRegistry) DecodeDocument(doc *Document, applied []string) error {
+	errs := make([]error, 0)
+	for _, uri := range r.Supported(applied) {
+		profile, _ := r.Lookup(uri)
+		if profile.Decode != nil {
+			if err := profile.Decode(doc); err != nil {
+				errs = append(errs, fmt.Errorf("profile %s: %w", uri, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ApplyProfiles invokes the Encode hooks of the applied profiles, identified by URI, with
+// the document, and lists the profiles in the document's "jsonapi" member. The first
+// applied profile is also referenced by the document's "profile" link, unless the link is
+// already set. Unknown profiles are ignored. The URIs of the profiles applied to the
+// document are returned.
+func (r *ProfileRegistry) ApplyProfiles(doc *Document, applied []string) ([]string, error) {
+	uris := make([]string, 0)
+	errs := make([]error, 0)
+
+	for _, uri := range r.Supported(applied) {
+		profile, _ := r.Lookup(uri)
+		if profile.Encode != nil {
+			if err := profile.Encode(doc); err != nil {
+				errs = append(errs, fmt.Errorf("profile %s: %w", uri, err))
+				continue
+			}
+		}
+		uris = append(uris, uri)
+		if !slices.Contains(doc.Jsonapi.Profile, uri) {
+			doc.Jsonapi.Profile = append(doc.Jsonapi.Profile, uri)
+		}
+	}
+
+	if len(doc.Jsonapi.Profile) > 0 && doc.Links[LinkProfile] == nil {
+		if doc.Links == nil {
+			doc.Links = Links{}
+		}
+		doc.Links[LinkProfile] = &Link{Href: doc.Jsonapi.Profile[0]}
+	}
+
+	return uris, errors.Join(errs...)
+}
@@ -0,0 +1,73 @@
+package jsonapi_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/gonobo/jsonapi/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+const timestampsProfile = "https://example.com/profiles/timestamps"
+
+func TestProfileRegistryRegister(t *testing.T) {
+	registry, err := jsonapi.NewProfileRegistry(jsonapi.Profile{URI: timestampsProfile})
+	assert.NoError(t, err)
+
+	assert.ErrorIs(t, registry.Register(jsonapi.Profile{}), jsonapi.ErrProfile)
+	assert.ErrorIs(t, registry.Register(jsonapi.Profile{URI: timestampsProfile}), jsonapi.ErrProfile)
+	assert.NoError(t, registry.Register(jsonapi.Profile{URI: "https://example.com/profiles/other"}))
+	assert.Equal(t, []string{timestampsProfile, "https://example.com/profiles/other"}, registry.URIs())
+}
+
+func TestProfileRegistrySupported(t *testing.T) {
+	registry, _ := jsonapi.NewProfileRegistry(jsonapi.Profile{URI: timestampsProfile})
+	got := registry.Supported([]string{"https://example.com/unknown", timestampsProfile, timestampsProfile})
+	assert.Equal(t, []string{timestampsProfile}, got)
+}
+
+func TestProfileRegistryDecodeDocument(t *testing.T) {
+	registry, _ := jsonapi.NewProfileRegistry(jsonapi.Profile{
+		URI: timestampsProfile,
+		Decode: func(d *jsonapi.Document) error {
+			return errors.New("timestamps are read-only")
+		},
+	})
+
+	doc := jsonapi.NewSingleDocument(&jsonapi.Resource{Type: "articles", ID: "1"})
+	assert.NoError(t, registry.DecodeDocument(doc, nil))
+	assert.ErrorContains(t, registry.DecodeDocument(doc, []string{timestampsProfile}), "timestamps are read-only")
+}
+
+func TestProfileRegistryApplyProfiles(t *testing.T) {
+	registry, _ := jsonapi.NewProfileRegistry(
+		jsonapi.Profile{
+			URI: timestampsProfile,
+			Encode: func(d *jsonapi.Document) error {
+				d.Data.First().Meta = jsonapi.Meta{"timestamps": map[string]any{"created": "2024-01-01"}}
+				return nil
+			},
+		},
+		jsonapi.Profile{
+			URI: "https://example.com/profiles/broken",
+			Encode: func(d *jsonapi.Document) error {
+				return errors.New("broken")
+			},
+		},
+	)
+
+	doc := jsonapi.NewSingleDocument(&jsonapi.Resource{Type: "articles", ID: "1"})
+	uris, err := registry.ApplyProfiles(doc, []string{"https://example.com/unknown", timestampsProfile})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{timestampsProfile}, uris)
+	assert.Equal(t, []string{timestampsProfile}, doc.Jsonapi.Profile)
+	assert.Equal(t, &jsonapi.Link{Href: timestampsProfile}, doc.Links[jsonapi.LinkProfile])
+	assert.Contains(t, doc.Data.First().Meta, "timestamps")
+
+	doc = jsonapi.NewSingleDocument(&jsonapi.Resource{Type: "articles", ID: "1"})
+	uris, err = registry.ApplyProfiles(doc, []string{"https://example.com/profiles/broken"})
+	assert.ErrorContains(t, err, "broken")
+	assert.Empty(t, uris)
+	assert.Empty(t, doc.Jsonapi.Profile)
+	assert.Nil(t, doc.Links)
+}
@@ -177,3 +177,40 @@ func (b FilterBuilder) Not() FilterBuilder {
 func (b FilterBuilder) ApplyFilterEvaluator(e FilterEvaluator) error {
 	return b.expr.ApplyFilterEvaluator(e)
 }
+
+// RenameFilterFields returns a copy of the expression in which the field name of every
+// [Filter] is replaced by the result of rename. Custom expressions are returned as is.
+func RenameFilterFields(expr FilterExpression, rename func(string) string) FilterExpression {
+	switch e := expr.(type) {
+	case Filter:
+		e.Name = rename(e.Name)
+		return &e
+	case *Filter:
+		f := *e
+		f.Name = rename(f.Name)
+		return &f
+	case *AndFilter:
+		return &AndFilter{Left: RenameFilterFields(e.Left, rename), Right: RenameFilterFields(e.Right, rename)}
+	case *OrFilter:
+		return &OrFilter{Left: RenameFilterFields(e.Left, rename), Right: RenameFilterFields(e.Right, rename)}
+	case *NotFilter:
+		return &NotFilter{Expression: RenameFilterFields(e.Expression, rename)}
+	}
+	return expr
+}
+
+// CountFilterNodes returns the number of nodes in the expression: its filters, logical
+// operators and custom expressions.
+func CountFilterNodes(expr FilterExpression) int {
+	switch e := expr.(type) {
+	case nil:
+		return 0
+	case *AndFilter:
+		return 1 + CountFilterNodes(e.Left) + CountFilterNodes(e.Right)
+	case *OrFilter:
+		return 1 + CountFilterNodes(e.Left) + CountFilterNodes(e.Right)
+	case *NotFilter:
+		return 1 + CountFilterNodes(e.Expression)
+	}
+	return 1
+}
@@ -37,6 +37,12 @@ func (Parser) ParseFilterQuery(r *http.Request) (query.FilterExpression, error)
 	return ParseWithTransform(r.URL.Query(), PassthroughTransformer)
 }
 
+// QueryFamilies returns the query parameter families read by the parser: the "filter"
+// criteria, and the filter expression.
+func (Parser) QueryFamilies() []string {
+	return []string{"filter", QueryKeyFilterExpression}
+}
+
 func Parse(query url.Values) (query.FilterExpression, error) {
 	return ParseWithTransform(query, PassthroughTransformer)
 }
@@ -3,6 +3,7 @@ package query_test
 import (
 	"errors"
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/gonobo/jsonapi/v2/query"
@@ -111,3 +112,32 @@ func TestEvaluate(t *testing.T) {
 		})
 	})
 }
+
+func TestRenameFilterFields(t *testing.T) {
+	expr := &query.OrFilter{
+		Left: &query.AndFilter{
+			Left:  &query.Filter{Name: "firstName", Condition: "eq", Value: "a"},
+			Right: query.Filter{Name: "lastName", Condition: "eq", Value: "b"},
+		},
+		Right: &query.NotFilter{Expression: &query.Filter{Name: "age", Condition: "lt", Value: "3"}},
+	}
+
+	got := query.RenameFilterFields(expr, strings.ToUpper)
+	assert.Equal(t, "(([FIRSTNAME eq 'a'] && [LASTNAME eq 'b']) || ![AGE lt '3'])", got.String())
+	assert.Equal(t, "(([firstName eq 'a'] && [lastName eq 'b']) || ![age lt '3'])", expr.String(), "original is unchanged")
+	assert.Equal(t, query.IdentityFilter{}, query.RenameFilterFields(query.IdentityFilter{}, strings.ToUpper))
+}
+
+func TestCountFilterNodes(t *testing.T) {
+	a := query.Filter{Name: "a", Condition: string(query.Equal), Value: "1"}
+	b := query.Filter{Name: "b", Condition: string(query.Equal), Value: "2"}
+
+	assert.Equal(t, 0, query.CountFilterNodes(nil))
+	assert.Equal(t, 1, query.CountFilterNodes(&a))
+	assert.Equal(t, 1, query.CountFilterNodes(query.IdentityFilter{}))
+	assert.Equal(t, 4, query.CountFilterNodes(&query.NotFilter{Expression: &query.AndFilter{Left: &a, Right: &b}}))
+	assert.Equal(t, 5, query.CountFilterNodes(&query.OrFilter{
+		Left:  &query.AndFilter{Left: &a, Right: &b},
+		Right: &b,
+	}))
+}
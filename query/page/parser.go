@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"strconv"
 
+	"github.com/gonobo/jsonapi/v2"
 	"github.com/gonobo/jsonapi/v2/query"
 )
 
@@ -15,6 +16,17 @@ var (
 
 type Criteria = query.Page
 
+// NewParameterError returns a JSON:API error reporting an invalid pagination query
+// parameter. The parameter is referenced in the error's source.
+func NewParameterError(param string, cause error) error {
+	return jsonapi.Error{
+		Status: strconv.Itoa(http.StatusBadRequest),
+		Title:  "Invalid Query Parameter",
+		Detail: fmt.Sprintf("parse %s: %s", param, cause),
+		Source: &jsonapi.ErrorSource{Parameter: param},
+	}
+}
+
 type Params map[string]string
 
 func (p Params) Cursor() string {
@@ -30,6 +42,15 @@ func (p Params) Limit() (int, error) {
 	return limit, err
 }
 
+func (p Params) Size() (int, error) {
+	value := p[query.ParamPageSize]
+	if value == "" {
+		return 0, nil
+	}
+	size, err := strconv.Atoi(value)
+	return size, err
+}
+
 func (p Params) PageNumber() (int, error) {
 	value := p[query.ParamPageNumber]
 	if value == "" {
@@ -39,6 +60,23 @@ func (p Params) PageNumber() (int, error) {
 	return pageNumber, err
 }
 
+func (p Params) Offset() (int, error) {
+	value := p[query.ParamPageOffset]
+	if value == "" {
+		return 0, nil
+	}
+	offset, err := strconv.Atoi(value)
+	return offset, err
+}
+
+func (p Params) Before() string {
+	return p[query.ParamPageBefore]
+}
+
+func (p Params) After() string {
+	return p[query.ParamPageAfter]
+}
+
 type PageNavigationParser struct{}
 
 func (p PageNavigationParser) ParsePageQuery(r *http.Request) (Criteria, error) {
@@ -49,13 +87,13 @@ func (p PageNavigationParser) ParsePageQuery(r *http.Request) (Criteria, error)
 	}
 
 	if limit, err := params.Limit(); err != nil {
-		return criteria, fmt.Errorf("parse page limit: %s", err)
+		return criteria, NewParameterError(query.ParamPageLimit, err)
 	} else {
 		criteria.Limit = limit
 	}
 
 	if pageNumber, err := params.PageNumber(); err != nil {
-		return criteria, fmt.Errorf("parse page number: %s", err)
+		return criteria, NewParameterError(query.ParamPageNumber, err)
 	} else {
 		criteria.PageNumber = pageNumber
 	}
@@ -73,7 +111,7 @@ func (c CursorNavigationParser) ParsePageQuery(r *http.Request) (Criteria, error
 	}
 
 	if limit, err := params.Limit(); err != nil {
-		return criteria, fmt.Errorf("parse page limit: %s", err)
+		return criteria, NewParameterError(query.ParamPageLimit, err)
 	} else {
 		criteria.Limit = limit
 	}
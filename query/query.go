@@ -7,6 +7,10 @@ const (
 	ParamPageCursor           = "page[cursor]"
 	ParamPageNumber           = "page[number]"
 	ParamPageLimit            = "page[limit]"
+	ParamPageOffset           = "page[offset]"
+	ParamPageBefore           = "page[before]"
+	ParamPageAfter            = "page[after]"
+	ParamPageSize             = "page[size]"
 	ParamInclude              = "include"
 )
 
@@ -19,7 +23,9 @@ type Sort struct {
 // Page defines a pagination request made by JSON:API clients.
 type Page struct {
 	PageNumber int    // The requested page number.
-	Cursor     string // The start page cursor.
+	Offset     int    // The number of resources to skip.
+	Cursor     string // The start page cursor; resources after the cursor are returned.
+	Before     string // The end page cursor; resources before the cursor are returned.
 	Limit      int    // The maximum number of resources to return.
 }
 
@@ -0,0 +1,154 @@
+package jsonapi
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+var (
+	// ErrQueryFamily is returned when a query parameter family cannot be registered, or its
+	// values cannot be encoded.
+	ErrQueryFamily = errors.New("invalid query parameter family")
+)
+
+// QueryFamily declares an implementation-specific query parameter family, e.g. "fullText"
+// for the parameters "fullText[q]" and "fullText[fuzzy]". Use [NewQueryFamily] to create new
+// instances.
+type QueryFamily struct {
+	Name   string // The family name.
+	parse  func(url.Values) (any, error)
+	encode func(any, url.Values) error
+}
+
+// NewQueryFamily declares a query parameter family whose parameters are parsed into values
+// of type T. The parse function receives the request's parameters of the family only; the
+// encode function adds the parameters of the value to the query of client requests.
+func NewQueryFamily[T any](name string, parse func(url.Values) (T, error), encode func(T, url.Values)) QueryFamily {
+	return QueryFamily{
+		Name: name,
+		parse: func(params url.Values) (any, error) {
+			return parse(params)
+		},
+		encode: func(value any, params url.Values) error {
+			typed, ok := value.(T)
+			if !ok {
+				return fmt.Errorf("%w: %s: unexpected value type %T", ErrQueryFamily, name, value)
+			}
+			encode(typed, params)
+			return nil
+		},
+	}
+}
+
+// Params returns the parameters of the family contained in the query.
+func (f QueryFamily) Params(query url.Values) url.Values {
+	params := make(url.Values)
+	for key, values := range query {
+		if key == f.Name || strings.HasPrefix(key, f.Name+"[") {
+			params[key] = values
+		}
+	}
+	return params
+}
+
+// Parse parses the parameters of the family contained in the query.
+func (f QueryFamily) Parse(query url.Values) (any, error) {
+	return f.parse(f.Params(query))
+}
+
+// IsReservedQueryFamily returns true if the query parameter family name only contains the
+// lowercase letters a-z: the specification reserves such names for its own families, such as
+// "include" and "page". Implementation-specific families must contain at least one other
+// character, e.g. "fullText" or "x-stats".
+func IsReservedQueryFamily(name string) bool {
+	for _, r := range name {
+		if r < 'a' || r > 'z' {
+			return false
+		}
+	}
+	return name != ""
+}
+
+// QueryValues contains the typed values of query parameter families, keyed by family name.
+type QueryValues map[string]any
+
+// QueryValue returns the typed value of the query parameter family.
+func QueryValue[T any](values QueryValues, family string) (T, bool) {
+	value, ok := values[family].(T)
+	return value, ok
+}
+
+// QueryFamilyRegistry contains the query parameter families supported by a server or client.
+// The zero value is an empty registry ready to use.
+type QueryFamilyRegistry struct {
+	families []QueryFamily
+}
+
+// NewQueryFamilyRegistry creates a registry containing the provided families.
+func NewQueryFamilyRegistry(families ...QueryFamily) (*QueryFamilyRegistry, error) {
+	registry := &QueryFamilyRegistry{}
+	for _, family := range families {
+		if err := registry.Register(family); err != nil {
+			return nil, err
+		}
+	}
+	return registry, nil
+}
+
+// Register adds the family to the registry. Families must have a unique name that is a legal
+// member name, and must not be reserved by the specification (see [IsReservedQueryFamily]).
+func (r *QueryFamilyRegistry) Register(family QueryFamily) error {
+	if !IsMemberName(family.Name) {
+		return fmt.Errorf("%w: invalid name %q", ErrQueryFamily, family.Name)
+	}
+	if family.parse == nil || family.encode == nil {
+		return fmt.Errorf("%w: %s was not created with NewQueryFamily", ErrQueryFamily, family.Name)
+	}
+	if IsReservedQueryFamily(family.Name) {
+		return fmt.Errorf("%w: %s is reserved by the specification", ErrQueryFamily, family.Name)
+	}
+	if _, ok := r.Lookup(family.Name); ok {
+		return fmt.Errorf("%w: %s is already registered", ErrQueryFamily, family.Name)
+	}
+	r.families = append(r.families, family)
+	return nil
+}
+
+// Lookup returns the family with the provided name.
+func (r *QueryFamilyRegistry) Lookup(name string) (QueryFamily, bool) {
+	for _, family := range r.families {
+		if family.Name == name {
+			return family, true
+		}
+	}
+	return QueryFamily{}, false
+}
+
+// Families returns all registered families, in registration order.
+func (r *QueryFamilyRegistry) Families() []QueryFamily {
+	return append([]QueryFamily{}, r.families...)
+}
+
+// Encode adds the parameters of the values to the query. Values of unregistered families
+// are reported as errors.
+func (r *QueryFamilyRegistry) Encode(values QueryValues, query url.Values) error {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	errs := make([]error, 0)
+	for _, name := range names {
+		family, ok := r.Lookup(name)
+		if !ok {
+			errs = append(errs, fmt.Errorf("%w: %s is not registered", ErrQueryFamily, name))
+			continue
+		}
+		errs = append(errs, family.encode(values[name], query))
+	}
+	return errors.Join(errs...)
+}
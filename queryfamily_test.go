@@ -0,0 +1,150 @@
+package jsonapi_test
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/gonobo/jsonapi/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+type search struct {
+	Q     string
+	Fuzzy bool
+}
+
+var searchFamily = jsonapi.NewQueryFamily("fullText",
+	func(params url.Values) (search, error) {
+		value := search{Q: params.Get("fullText[q]")}
+		if fuzzy := params.Get("fullText[fuzzy]"); fuzzy != "" {
+			parsed, err := strconv.ParseBool(fuzzy)
+			if err != nil {
+				return value, err
+			}
+			value.Fuzzy = parsed
+		}
+		return value, nil
+	},
+	func(value search, params url.Values) {
+		params.Set("fullText[q]", value.Q)
+		if value.Fuzzy {
+			params.Set("fullText[fuzzy]", "true")
+		}
+	},
+)
+
+func TestQueryFamilyRegistryRegister(t *testing.T) {
+	noop := func(string, url.Values) {}
+	parse := func(url.Values) (string, error) { return "", nil }
+
+	for _, tc := range []struct {
+		name    string
+		family  jsonapi.QueryFamily
+		wantErr bool
+	}{
+		{name: "valid family", family: jsonapi.NewQueryFamily("itemStats", parse, noop)},
+		{name: "implementation-specific family", family: jsonapi.NewQueryFamily("x-stats", parse, noop)},
+		{name: "duplicate family", family: searchFamily, wantErr: true},
+		{name: "specification family", family: jsonapi.NewQueryFamily("page", parse, noop), wantErr: true},
+		{name: "reserved family", family: jsonapi.NewQueryFamily("stats", parse, noop), wantErr: true},
+		{name: "illegal name", family: jsonapi.NewQueryFamily("-stats", parse, noop), wantErr: true},
+		{name: "empty name", family: jsonapi.NewQueryFamily("", parse, noop), wantErr: true},
+		{name: "undeclared family", family: jsonapi.QueryFamily{Name: "itemStats"}, wantErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			registry, err := jsonapi.NewQueryFamilyRegistry(searchFamily)
+			assert.NoError(t, err)
+
+			err = registry.Register(tc.family)
+			if tc.wantErr {
+				assert.ErrorIs(t, err, jsonapi.ErrQueryFamily)
+				return
+			}
+			assert.NoError(t, err)
+			_, ok := registry.Lookup(tc.family.Name)
+			assert.True(t, ok)
+		})
+	}
+}
+
+func TestQueryFamilyParse(t *testing.T) {
+	query := url.Values{
+		"fullText[q]":     {"foo"},
+		"fullText[fuzzy]": {"true"},
+		"fullTexting":     {"bar"},
+		"sort":            {"name"},
+	}
+
+	assert.Equal(t, url.Values{"fullText[q]": {"foo"}, "fullText[fuzzy]": {"true"}}, searchFamily.Params(query))
+
+	value, err := searchFamily.Parse(query)
+	assert.NoError(t, err)
+	assert.Equal(t, search{Q: "foo", Fuzzy: true}, value)
+
+	_, err = searchFamily.Parse(url.Values{"fullText[fuzzy]": {"maybe"}})
+	assert.Error(t, err)
+}
+
+func TestQueryFamilyRegistryEncode(t *testing.T) {
+	registry, err := jsonapi.NewQueryFamilyRegistry(searchFamily)
+	assert.NoError(t, err)
+
+	query := url.Values{}
+	err = registry.Encode(jsonapi.QueryValues{"fullText": search{Q: "foo"}}, query)
+	assert.NoError(t, err)
+	assert.Equal(t, url.Values{"fullText[q]": {"foo"}}, query)
+
+	err = registry.Encode(jsonapi.QueryValues{"fullText": "foo", "itemStats": true}, url.Values{})
+	assert.ErrorIs(t, err, jsonapi.ErrQueryFamily)
+	assert.Len(t, err.(interface{ Unwrap() []error }).Unwrap(), 2)
+}
+
+func TestQueryValue(t *testing.T) {
+	values := jsonapi.QueryValues{"fullText": search{Q: "foo"}}
+
+	value, ok := jsonapi.QueryValue[search](values, "fullText")
+	assert.True(t, ok)
+	assert.Equal(t, "foo", value.Q)
+
+	_, ok = jsonapi.QueryValue[string](values, "fullText")
+	assert.False(t, ok)
+
+	_, ok = jsonapi.QueryValue[search](nil, "fullText")
+	assert.False(t, ok)
+}
+
+func TestClientQueryValues(t *testing.T) {
+	registry, err := jsonapi.NewQueryFamilyRegistry(searchFamily)
+	assert.NoError(t, err)
+
+	var got *url.URL
+	client := jsonapi.NewRequest("http://api.foo.com", func(c *jsonapi.Client) {
+		c.QueryFamilies = registry
+		c.Query = jsonapi.QueryValues{"fullText": search{Q: "foo bar", Fuzzy: true}}
+		c.Doer = doer(func(req *http.Request) (*http.Response, error) {
+			got = req.URL
+			return &http.Response{StatusCode: http.StatusOK}, nil
+		})
+	})
+
+	_, err = client.List("items")
+	assert.NoError(t, err)
+	assert.Equal(t, "/items", got.Path)
+	assert.Equal(t, "fullText%5Bfuzzy%5D=true&fullText%5Bq%5D=foo+bar", got.RawQuery)
+
+	// values of the request context take precedence.
+	client.Method = http.MethodGet
+	client.Context = jsonapi.RequestContext{
+		ResourceType: "items",
+		Query:        jsonapi.QueryValues{"fullText": search{Q: "baz"}},
+	}
+	_, err = jsonapi.Do(client)
+	assert.NoError(t, err)
+	assert.Equal(t, "fullText%5Bq%5D=baz", got.RawQuery)
+
+	client.Query = jsonapi.QueryValues{"itemStats": true}
+	_, err = client.List("items")
+	assert.Error(t, err)
+}
@@ -5,7 +5,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"maps"
 	"net/http"
+	"net/url"
 )
 
 const (
@@ -24,13 +26,19 @@ const (
 //
 // This behavior can be modified by updating the URLResolver field of a
 // Client instance.
+//
+// The values of the Query field are encoded in the query of request urls by the
+// families registered in the QueryFamilies field, together with the values of the
+// request context's Query field, which take precedence.
 type Client struct {
-	JSONEncoder                // The JSON encoder.
-	URLResolver                // The URL resolver.
-	Doer                       // The underlying http client.
-	BaseURL     string         // The base url of the JSON:API server.
-	Context     RequestContext // The JSON:API request context.
-	Method      string         // The http method to use.
+	JSONEncoder                        // The JSON encoder.
+	URLResolver                        // The URL resolver.
+	Doer                               // The underlying http client.
+	BaseURL       string               // The base url of the JSON:API server.
+	Context       RequestContext       // The JSON:API request context.
+	Method        string               // The http method to use.
+	QueryFamilies *QueryFamilyRegistry // The implementation-specific query parameter families.
+	Query         QueryValues          // The values of query parameter families sent with each request.
 }
 
 // NewRequest creates a new JSON:API request instance.
@@ -51,13 +59,16 @@ func NewRequest(baseURL string, options ...func(*Client)) Client {
 
 // httpRequest generates a http.Request from a Request instance.
 func (c Client) httpRequest() (*http.Request, error) {
-	url := c.ResolveURL(c.Context, c.BaseURL)
+	target, err := c.requestURL()
+	if err != nil {
+		return nil, err
+	}
+
 	var req *http.Request = nil
 	var body *bytes.Buffer = nil
-	var err error = nil
 
 	if c.Context.Document == nil {
-		return http.NewRequest(c.Method, url, nil)
+		return http.NewRequest(c.Method, target, nil)
 	}
 
 	body = &bytes.Buffer{}
@@ -68,12 +79,44 @@ func (c Client) httpRequest() (*http.Request, error) {
 	}
 
 	if err == nil {
-		req, err = http.NewRequest(c.Method, url, body)
+		req, err = http.NewRequest(c.Method, target, body)
 	}
 
 	return req, err
 }
 
+// requestURL resolves the request url, encoding the query parameter family values of the
+// client and the request context.
+func (c Client) requestURL() (string, error) {
+	resolved := c.ResolveURL(c.Context, c.BaseURL)
+	if len(c.Query) == 0 && len(c.Context.Query) == 0 {
+		return resolved, nil
+	}
+
+	values := maps.Clone(c.Query)
+	if values == nil {
+		values = make(QueryValues, len(c.Context.Query))
+	}
+	maps.Copy(values, c.Context.Query)
+
+	u, err := url.Parse(resolved)
+	if err != nil {
+		return "", err
+	}
+
+	registry := c.QueryFamilies
+	if registry == nil {
+		registry = &QueryFamilyRegistry{}
+	}
+
+	query := u.Query()
+	if err := registry.Encode(values, query); err != nil {
+		return "", err
+	}
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
 // Get retrieves a single resource from the server.
 func (c Client) Get(resourceType, id string, options ...func(*http.Request)) (*http.Response, error) {
 	c.Method = http.MethodGet
@@ -1,10 +1,7 @@
 package jsonapi
 
 import (
-	"fmt"
 	"net/http"
-
-	"github.com/gonobo/jsonapi/v2/internal/null"
 )
 
 // URLResolver resolves urls based on the JSON:API request context.
@@ -28,33 +25,10 @@ func (fn URLResolverFunc) ResolveURL(ctx RequestContext, baseURL string) string
 //	":base/:type/:id" for fetch, update, delete
 //	":base/:type/:id/relationships/:ref" for fetchRef
 //	":base/:type/:id/:ref" for fetchRelated
+//
+// The urls match the routes of [DefaultContextResolver].
 func DefaultURLResolver() URLResolverFunc {
-	return func(ctx RequestContext, baseURL string) string {
-		if ctx.Relationship != "" && ctx.Related {
-			return fmt.Sprintf("%s/%s/%s/%s",
-				baseURL,
-				ctx.ResourceType,
-				ctx.ResourceID,
-				ctx.Relationship,
-			)
-		}
-		if ctx.Relationship != "" {
-			return fmt.Sprintf("%s/%s/%s/relationships/%s",
-				baseURL,
-				ctx.ResourceType,
-				ctx.ResourceID,
-				ctx.Relationship,
-			)
-		}
-		if ctx.ResourceID != "" {
-			return fmt.Sprintf("%s/%s/%s",
-				baseURL,
-				ctx.ResourceType,
-				ctx.ResourceID,
-			)
-		}
-		return fmt.Sprintf("%s/%s", baseURL, ctx.ResourceType)
-	}
+	return defaultRouter.ResolveURL
 }
 
 // ContextResolver resolves JSON:API context information from an incoming http request.
@@ -85,9 +59,12 @@ func (fn ContextResolverFunc) ResolveContext(r *http.Request) (*RequestContext,
 //	"/:type/:id/relationships/:ref"  // ResourceType, ResourceID, Relationship
 //	"/:type/:id/:ref"                // ResourceType, ResourceID, Relationship, Related
 func DefaultContextResolver() ContextResolverFunc {
-	return ContextResolverWithPrefix("")
+	return defaultRouter.ResolveContext
 }
 
+// defaultRouter resolves contexts and urls with the default routes.
+var defaultRouter = MustCompileRoutes(DefaultRoutes(""))
+
 // ContextResolverWithPrefix returns a resolver that populates a JSON:API context
 // based on the URL path examples given by the JSON:API specification:
 //
@@ -96,37 +73,15 @@ func DefaultContextResolver() ContextResolverFunc {
 //	"/prefix/:type/:id/relationships/:ref"  // ResourceType, ResourceID, Relationship
 //	"/prefix/:type/:id/:ref"                // ResourceType, ResourceID, Relationship, Related
 //
-// The specified prefix should start -- but not end -- with a forward slash.
+// The specified prefix should start -- but not end -- with a forward slash. The routes
+// are compiled once, when the resolver is created; see [CompileRoutes] to resolve
+// contexts from custom route templates.
 func ContextResolverWithPrefix(prefix string) ContextResolverFunc {
-	return func(r *http.Request) (*RequestContext, error) {
-		var (
-			mux = http.NewServeMux()
-			w   = null.Writer{}
-			h   = null.Handler{}
-			ctx = RequestContext{}
-		)
-
-		// use the serve mux path parser to extract path values from the request url.
-		mux.Handle(fmt.Sprintf("%s/{jsonapi_type}", prefix), h)
-		mux.Handle(fmt.Sprintf("%s/{jsonapi_type}/{jsonapi_id}", prefix), h)
-		mux.Handle(fmt.Sprintf("%s/{jsonapi_type}/{jsonapi_id}/relationships/{jsonapi_relationship}", prefix), h)
-		mux.Handle(fmt.Sprintf("%s/{jsonapi_type}/{jsonapi_id}/{jsonapi_related}", prefix), h)
-		mux.ServeHTTP(w, r)
-
-		ctx.ResourceType = r.PathValue("jsonapi_type")
-		if ctx.ResourceType == "" {
-			return nil, jsonapiError("unspecified resource type")
-		}
-
-		ctx.ResourceID = r.PathValue("jsonapi_id")
-		ctx.Relationship = r.PathValue("jsonapi_relationship")
-
-		related := r.PathValue("jsonapi_related")
-		if related != "" {
-			ctx.Related = true
-			ctx.Relationship = related
+	router, err := CompileRoutes(DefaultRoutes(prefix))
+	if err != nil {
+		return func(*http.Request) (*RequestContext, error) {
+			return nil, err
 		}
-
-		return &ctx, nil
 	}
+	return router.ResolveContext
 }
@@ -0,0 +1,442 @@
+package jsonapi
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+)
+
+var (
+	// ErrInvalidRoute is returned when a route template cannot be compiled.
+	ErrInvalidRoute = errors.New("invalid route template")
+)
+
+// Route template parameters. Parameters must span a whole path segment.
+const (
+	RouteParamType         = "{type}"         // The resource type.
+	RouteParamID           = "{id}"           // The resource id.
+	RouteParamRelationship = "{relationship}" // The relationship name.
+)
+
+// Routes contains the URL path templates of the JSON:API endpoints. Templates start with a
+// forward slash, and contain literal segments and the [RouteParamType], [RouteParamID] and
+// [RouteParamRelationship] parameters. Empty templates disable their endpoint.
+//
+// Nested contains the templates of resource collections scoped by parent resources, such as
+// "/organizations/{id}/projects". Each parent is a literal resource type followed by the
+// [RouteParamID] parameter; the last segment is the resource type of the collection, and
+// literal segments may precede the first parent. The endpoints of nested resources follow the
+// collection path: "/organizations/{id}/projects/{id}", ".../relationships/{relationship}" and
+// ".../{relationship}". Requests to nested endpoints are resolved with the parents in
+// [RequestContext.Scope]; nested routes take precedence over the flat routes they overlap.
+type Routes struct {
+	Collection   string   // The path of resource collections, e.g. "/{type}".
+	Resource     string   // The path of individual resources, e.g. "/{type}/{id}".
+	Relationship string   // The path of relationships, e.g. "/{type}/{id}/relationships/{relationship}".
+	Related      string   // The path of related resources, e.g. "/{type}/{id}/{relationship}".
+	Nested       []string // The paths of nested resource collections, e.g. "/organizations/{id}/projects".
+}
+
+// maxScopeDepth is the maximum number of parents of nested routes.
+const maxScopeDepth = 8
+
+// DefaultRoutes returns the routes given by the JSON:API specification examples,
+// below the provided prefix:
+//
+//	"/prefix/{type}"
+//	"/prefix/{type}/{id}"
+//	"/prefix/{type}/{id}/relationships/{relationship}"
+//	"/prefix/{type}/{id}/{relationship}"
+//
+// The specified prefix should start -- but not end -- with a forward slash.
+func DefaultRoutes(prefix string) Routes {
+	return Routes{
+		Collection:   prefix + "/{type}",
+		Resource:     prefix + "/{type}/{id}",
+		Relationship: prefix + "/{type}/{id}/relationships/{relationship}",
+		Related:      prefix + "/{type}/{id}/{relationship}",
+	}
+}
+
+type routeKind int
+
+const (
+	routeCollection routeKind = iota
+	routeResource
+	routeRelationship
+	routeRelated
+)
+
+type routeParam int
+
+const (
+	paramNone routeParam = iota
+	paramType
+	paramID
+	paramRelationship
+	paramParent
+)
+
+type routeSegment struct {
+	literal string
+	param   routeParam
+	index   int // the position of the parent in the scope, for parent parameters.
+}
+
+type compiledRoute struct {
+	kind         routeKind
+	template     string
+	segments     []routeSegment
+	literals     int
+	resourceType string   // the resource type of nested routes.
+	parents      []string // the parent resource types of nested routes.
+}
+
+// Router resolves JSON:API request contexts from request paths, and the URLs of request
+// contexts, with a route table compiled once from [Routes]. Router implements both
+// [ContextResolver] and [URLResolver], so that routing and link generation share the
+// same templates: for every endpoint, the context resolved from the URL of a context
+// is the context itself. Use [CompileRoutes] to create new instances.
+type Router struct {
+	routes []compiledRoute // ordered by specificity
+	byKind map[routeKind]compiledRoute
+	nested map[string]compiledRoute // keyed by kind, parent types and resource type
+}
+
+// CompileRoutes compiles the route templates into a [Router].
+func CompileRoutes(routes Routes) (*Router, error) {
+	router := &Router{
+		byKind: make(map[routeKind]compiledRoute),
+		nested: make(map[string]compiledRoute),
+	}
+
+	for _, def := range []struct {
+		kind     routeKind
+		template string
+		required []routeParam
+	}{
+		{routeRelationship, routes.Relationship, []routeParam{paramType, paramID, paramRelationship}},
+		{routeRelated, routes.Related, []routeParam{paramType, paramID, paramRelationship}},
+		{routeResource, routes.Resource, []routeParam{paramType, paramID}},
+		{routeCollection, routes.Collection, []routeParam{paramType}},
+	} {
+		if def.template == "" {
+			continue
+		}
+		route, err := compileRoute(def.kind, def.template, def.required)
+		if err != nil {
+			return nil, err
+		}
+		router.routes = append(router.routes, route)
+		router.byKind[def.kind] = route
+	}
+
+	for _, template := range routes.Nested {
+		nested, err := compileNestedRoutes(template)
+		if err != nil {
+			return nil, err
+		}
+		for _, route := range nested {
+			key := nestedRouteKey(route.kind, route.parents, route.resourceType)
+			if _, ok := router.nested[key]; ok {
+				return nil, fmt.Errorf("%w: %q is defined more than once", ErrInvalidRoute, template)
+			}
+			router.routes = append(router.routes, route)
+			router.nested[key] = route
+		}
+	}
+
+	if len(router.routes) == 0 {
+		return nil, fmt.Errorf("%w: no routes defined", ErrInvalidRoute)
+	}
+
+	// literal segments take precedence over parameters.
+	sort.SliceStable(router.routes, func(i, j int) bool {
+		return router.routes[i].literals > router.routes[j].literals
+	})
+
+	return router, nil
+}
+
+// MustCompileRoutes is like [CompileRoutes], but panics if the routes cannot be compiled.
+// It simplifies the initialization of global variables holding routers.
+func MustCompileRoutes(routes Routes) *Router {
+	router, err := CompileRoutes(routes)
+	if err != nil {
+		panic(err)
+	}
+	return router
+}
+
+func compileRoute(kind routeKind, template string, required []routeParam) (compiledRoute, error) {
+	route := compiledRoute{kind: kind, template: template}
+
+	if !strings.HasPrefix(template, "/") {
+		return route, fmt.Errorf("%w: %q must start with a forward slash", ErrInvalidRoute, template)
+	}
+
+	seen := make(map[routeParam]bool)
+	for _, part := range strings.Split(template[1:], "/") {
+		segment := routeSegment{literal: part}
+		switch part {
+		case "":
+			return route, fmt.Errorf("%w: %q contains an empty segment", ErrInvalidRoute, template)
+		case RouteParamType:
+			segment = routeSegment{param: paramType}
+		case RouteParamID:
+			segment = routeSegment{param: paramID}
+		case RouteParamRelationship:
+			segment = routeSegment{param: paramRelationship}
+		default:
+			if strings.ContainsAny(part, "{}") {
+				return route, fmt.Errorf("%w: %q contains unknown parameter %s", ErrInvalidRoute, template, part)
+			}
+			route.literals++
+		}
+		if segment.param != paramNone {
+			if seen[segment.param] {
+				return route, fmt.Errorf("%w: %q repeats parameter %s", ErrInvalidRoute, template, part)
+			}
+			seen[segment.param] = true
+		}
+		route.segments = append(route.segments, segment)
+	}
+
+	for _, param := range required {
+		if !seen[param] {
+			return route, fmt.Errorf("%w: %q is missing a required parameter", ErrInvalidRoute, template)
+		}
+	}
+	if len(seen) != len(required) {
+		return route, fmt.Errorf("%w: %q contains an unexpected parameter", ErrInvalidRoute, template)
+	}
+
+	return route, nil
+}
+
+// compileNestedRoutes compiles the endpoints of the nested collection template.
+func compileNestedRoutes(template string) ([]compiledRoute, error) {
+	if !strings.HasPrefix(template, "/") {
+		return nil, fmt.Errorf("%w: %q must start with a forward slash", ErrInvalidRoute, template)
+	}
+
+	parts := strings.Split(template[1:], "/")
+	collection := compiledRoute{kind: routeCollection, template: template}
+
+	for i := 0; i < len(parts); i++ {
+		part := parts[i]
+		last := i == len(parts)-1
+		if part == "" || strings.ContainsAny(part, "{}") {
+			return nil, fmt.Errorf("%w: %q must alternate resource types and %s parameters",
+				ErrInvalidRoute, template, RouteParamID)
+		}
+
+		collection.literals++
+		switch {
+		case last:
+			collection.resourceType = part
+			collection.segments = append(collection.segments, routeSegment{literal: part})
+		case parts[i+1] == RouteParamID:
+			collection.segments = append(collection.segments,
+				routeSegment{literal: part},
+				routeSegment{param: paramParent, index: len(collection.parents)},
+			)
+			collection.parents = append(collection.parents, part)
+			i++
+		case len(collection.parents) > 0:
+			return nil, fmt.Errorf("%w: %q must alternate resource types and %s parameters",
+				ErrInvalidRoute, template, RouteParamID)
+		default:
+			// a literal prefix segment.
+			collection.segments = append(collection.segments, routeSegment{literal: part})
+		}
+	}
+
+	if collection.resourceType == "" || len(collection.parents) == 0 {
+		return nil, fmt.Errorf("%w: %q must end with the resource type of a scoped collection",
+			ErrInvalidRoute, template)
+	} else if len(collection.parents) > maxScopeDepth {
+		return nil, fmt.Errorf("%w: %q exceeds the maximum of %d parents", ErrInvalidRoute, template, maxScopeDepth)
+	}
+
+	extend := func(kind routeKind, suffix ...routeSegment) compiledRoute {
+		route := collection
+		route.kind = kind
+		route.segments = append(slices.Clip(collection.segments), suffix...)
+		for _, segment := range suffix {
+			if segment.param == paramNone {
+				route.literals++
+			}
+		}
+		return route
+	}
+
+	return []compiledRoute{
+		collection,
+		extend(routeResource, routeSegment{param: paramID}),
+		extend(routeRelationship, routeSegment{param: paramID},
+			routeSegment{literal: "relationships"}, routeSegment{param: paramRelationship}),
+		extend(routeRelated, routeSegment{param: paramID}, routeSegment{param: paramRelationship}),
+	}, nil
+}
+
+func nestedRouteKey(kind routeKind, parents []string, resourceType string) string {
+	return fmt.Sprintf("%d:%s/%s", kind, strings.Join(parents, "/"), resourceType)
+}
+
+// ResolveContext resolves the JSON:API context from the request's URL path.
+func (rt *Router) ResolveContext(r *http.Request) (*RequestContext, error) {
+	path := r.URL.RawPath
+	if path == "" {
+		path = r.URL.Path
+	}
+
+	ctx := RequestContext{}
+	for _, route := range rt.routes {
+		if route.match(path, &ctx) {
+			return &ctx, nil
+		}
+	}
+
+	return nil, jsonapiError("unspecified resource type")
+}
+
+// match reports whether the path matches the route, and populates the context with
+// the path's parameter values. The path is scanned in place.
+func (route compiledRoute) match(path string, ctx *RequestContext) bool {
+	var values [paramRelationship + 1]string
+	var parents [maxScopeDepth]string
+
+	rest := path
+	for _, segment := range route.segments {
+		if len(rest) < 2 || rest[0] != '/' {
+			return false
+		}
+		rest = rest[1:]
+
+		end := strings.IndexByte(rest, '/')
+		if end < 0 {
+			end = len(rest)
+		}
+		part := rest[:end]
+		rest = rest[end:]
+
+		if part == "" {
+			return false
+		} else if segment.param == paramNone {
+			if part != segment.literal {
+				return false
+			}
+			continue
+		}
+
+		if strings.IndexByte(part, '%') >= 0 {
+			unescaped, err := url.PathUnescape(part)
+			if err != nil {
+				return false
+			}
+			part = unescaped
+		}
+		if segment.param == paramParent {
+			parents[segment.index] = part
+		} else {
+			values[segment.param] = part
+		}
+	}
+
+	if rest != "" {
+		return false
+	}
+
+	*ctx = RequestContext{
+		ResourceType: values[paramType],
+		ResourceID:   values[paramID],
+		Relationship: values[paramRelationship],
+		Related:      route.kind == routeRelated,
+	}
+
+	if route.resourceType != "" {
+		ctx.ResourceType = route.resourceType
+		ctx.Scope = make([]ParentResource, len(route.parents))
+		for i, parentType := range route.parents {
+			ctx.Scope[i] = ParentResource{Type: parentType, ID: parents[i]}
+		}
+	}
+
+	return true
+}
+
+// ResolveURL creates the URL of the request context's endpoint, by appending the path of
+// the matching route to the base URL. The base URL should not contain the routes' prefix.
+// If the context is scoped by parent resources, the nested route of the scope is used.
+// If the endpoint's route is not defined, the collection URL is returned.
+func (rt *Router) ResolveURL(ctx RequestContext, baseURL string) string {
+	kind := routeCollection
+	switch {
+	case ctx.Relationship != "" && ctx.Related:
+		kind = routeRelated
+	case ctx.Relationship != "":
+		kind = routeRelationship
+	case ctx.ResourceID != "":
+		kind = routeResource
+	}
+
+	route, ok := rt.nestedRoute(kind, ctx)
+	if !ok {
+		route, ok = rt.byKind[kind]
+	}
+	if !ok {
+		route = rt.byKind[routeCollection]
+	}
+
+	var sb strings.Builder
+	sb.WriteString(baseURL)
+	for _, segment := range route.segments {
+		sb.WriteByte('/')
+		switch segment.param {
+		case paramType:
+			sb.WriteString(url.PathEscape(ctx.ResourceType))
+		case paramID:
+			sb.WriteString(url.PathEscape(ctx.ResourceID))
+		case paramRelationship:
+			sb.WriteString(url.PathEscape(ctx.Relationship))
+		case paramParent:
+			sb.WriteString(url.PathEscape(ctx.Scope[segment.index].ID))
+		default:
+			sb.WriteString(segment.literal)
+		}
+	}
+	return sb.String()
+}
+
+// nestedRoute returns the nested route of the endpoint within the context's scope, if any.
+func (rt *Router) nestedRoute(kind routeKind, ctx RequestContext) (compiledRoute, bool) {
+	if len(ctx.Scope) == 0 {
+		return compiledRoute{}, false
+	}
+
+	parents := make([]string, len(ctx.Scope))
+	for i, parent := range ctx.Scope {
+		parents[i] = parent.Type
+	}
+
+	route, ok := rt.nested[nestedRouteKey(kind, parents, ctx.ResourceType)]
+	if !ok {
+		route, ok = rt.nested[nestedRouteKey(routeCollection, parents, ctx.ResourceType)]
+	}
+	return route, ok
+}
+
+// URLResolver returns a [URLResolver] that generates URLs matching the router's routes.
+func (rt *Router) URLResolver() URLResolverFunc {
+	return rt.ResolveURL
+}
+
+// ContextResolver returns a [ContextResolver] that resolves contexts with the router's routes.
+func (rt *Router) ContextResolver() ContextResolverFunc {
+	return rt.ResolveContext
+}
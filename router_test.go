@@ -0,0 +1,241 @@
+package jsonapi_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gonobo/jsonapi/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompileRoutes(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		routes jsonapi.Routes
+	}{
+		{name: "no routes", routes: jsonapi.Routes{}},
+		{name: "relative template", routes: jsonapi.Routes{Collection: "{type}"}},
+		{name: "empty segment", routes: jsonapi.Routes{Collection: "/api//{type}"}},
+		{name: "unknown parameter", routes: jsonapi.Routes{Collection: "/{kind}"}},
+		{name: "partial parameter", routes: jsonapi.Routes{Collection: "/v{type}"}},
+		{name: "missing parameter", routes: jsonapi.Routes{Resource: "/{type}"}},
+		{name: "unexpected parameter", routes: jsonapi.Routes{Collection: "/{type}/{id}"}},
+		{name: "repeated parameter", routes: jsonapi.Routes{Collection: "/{type}/{type}"}},
+		{name: "nested without parent", routes: jsonapi.Routes{Nested: []string{"/projects"}}},
+		{name: "nested with type parameter", routes: jsonapi.Routes{Nested: []string{"/orgs/{id}/{type}"}}},
+		{name: "nested ending with parent", routes: jsonapi.Routes{Nested: []string{"/orgs/{id}"}}},
+		{name: "nested literal between parents", routes: jsonapi.Routes{Nested: []string{"/orgs/{id}/x/teams/{id}/projects"}}},
+		{name: "nested duplicate", routes: jsonapi.Routes{Nested: []string{"/orgs/{id}/projects", "/v2/orgs/{id}/projects"}}},
+		{name: "nested too deep", routes: jsonapi.Routes{Nested: []string{
+			"/a/{id}/b/{id}/c/{id}/d/{id}/e/{id}/f/{id}/g/{id}/h/{id}/i/{id}/projects",
+		}}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := jsonapi.CompileRoutes(tc.routes)
+			assert.ErrorIs(t, err, jsonapi.ErrInvalidRoute)
+		})
+	}
+}
+
+func TestRouter(t *testing.T) {
+	router, err := jsonapi.CompileRoutes(jsonapi.Routes{
+		Collection:   "/api/v2/{type}",
+		Resource:     "/api/v2/{type}/{id}",
+		Relationship: "/api/v2/{type}/{id}/rels/{relationship}",
+		Related:      "/api/v2/{type}/{id}/{relationship}",
+	})
+	assert.NoError(t, err)
+
+	for _, tc := range []struct {
+		name    string
+		url     string
+		want    jsonapi.RequestContext
+		wantErr bool
+	}{
+		{
+			name: "collection",
+			url:  "https://example.com/api/v2/items",
+			want: jsonapi.RequestContext{ResourceType: "items"},
+		},
+		{
+			name: "resource",
+			url:  "/api/v2/items/1",
+			want: jsonapi.RequestContext{ResourceType: "items", ResourceID: "1"},
+		},
+		{
+			name: "relationship",
+			url:  "/api/v2/items/1/rels/owner",
+			want: jsonapi.RequestContext{ResourceType: "items", ResourceID: "1", Relationship: "owner"},
+		},
+		{
+			name: "related",
+			url:  "/api/v2/items/1/owner",
+			want: jsonapi.RequestContext{ResourceType: "items", ResourceID: "1", Relationship: "owner", Related: true},
+		},
+		{
+			name: "escaped id",
+			url:  "/api/v2/items/a%2Fb",
+			want: jsonapi.RequestContext{ResourceType: "items", ResourceID: "a/b"},
+		},
+		{name: "unknown prefix", url: "/api/v1/items", wantErr: true},
+		{name: "trailing slash", url: "/api/v2/items/", wantErr: true},
+		{name: "too many segments", url: "/api/v2/items/1/rels/owner/extra", wantErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", tc.url, nil)
+			got, err := router.ResolveContext(req)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, &tc.want, got)
+		})
+	}
+
+	t.Run("resolves urls", func(t *testing.T) {
+		resolver := router.URLResolver()
+		base := "https://example.com"
+		assert.Equal(t, base+"/api/v2/items",
+			resolver.ResolveURL(jsonapi.RequestContext{ResourceType: "items"}, base))
+		assert.Equal(t, base+"/api/v2/items/a%2Fb",
+			resolver.ResolveURL(jsonapi.RequestContext{ResourceType: "items", ResourceID: "a/b"}, base))
+		assert.Equal(t, base+"/api/v2/items/1/rels/owner",
+			resolver.ResolveURL(jsonapi.RequestContext{ResourceType: "items", ResourceID: "1", Relationship: "owner"}, base))
+		assert.Equal(t, base+"/api/v2/items/1/owner",
+			resolver.ResolveURL(jsonapi.RequestContext{ResourceType: "items", ResourceID: "1", Relationship: "owner", Related: true}, base))
+	})
+
+	t.Run("allocates only the context", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/api/v2/items/1/rels/owner", nil)
+		allocs := testing.AllocsPerRun(100, func() {
+			_, _ = router.ResolveContext(req)
+		})
+		assert.LessOrEqual(t, allocs, float64(1))
+	})
+}
+
+func BenchmarkContextResolver(b *testing.B) {
+	resolver := jsonapi.DefaultContextResolver()
+	req, _ := http.NewRequest("GET", "/items/1/relationships/owner", nil)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_, _ = resolver.ResolveContext(req)
+	}
+}
+
+func TestRoutesRoundTrip(t *testing.T) {
+	const baseURL = "https://example.com"
+
+	contexts := map[string]jsonapi.RequestContext{
+		"collection":   {ResourceType: "items"},
+		"resource":     {ResourceType: "items", ResourceID: "1"},
+		"escaped":      {ResourceType: "items", ResourceID: "a/b c"},
+		"relationship": {ResourceType: "items", ResourceID: "1", Relationship: "owner"},
+		"related":      {ResourceType: "items", ResourceID: "1", Relationship: "owner", Related: true},
+	}
+
+	type resolvers struct {
+		context jsonapi.ContextResolver
+		url     jsonapi.URLResolver
+	}
+
+	custom := jsonapi.MustCompileRoutes(jsonapi.Routes{
+		Collection:   "/api/v2/{type}",
+		Resource:     "/api/v2/{type}/{id}",
+		Relationship: "/api/v2/{type}/{id}/rels/{relationship}",
+		Related:      "/api/v2/{type}/{id}/{relationship}",
+	})
+	prefixed := jsonapi.MustCompileRoutes(jsonapi.DefaultRoutes("/v2"))
+
+	for name, pair := range map[string]resolvers{
+		"defaults": {jsonapi.DefaultContextResolver(), jsonapi.DefaultURLResolver()},
+		"prefixed": {prefixed, prefixed},
+		"custom":   {custom.ContextResolver(), custom.URLResolver()},
+	} {
+		for kind, want := range contexts {
+			t.Run(name+" "+kind, func(t *testing.T) {
+				req, err := http.NewRequest("GET", pair.url.ResolveURL(want, baseURL), nil)
+				assert.NoError(t, err)
+
+				got, err := pair.context.ResolveContext(req)
+				assert.NoError(t, err)
+				assert.Equal(t, &want, got)
+			})
+		}
+	}
+}
+
+func TestNestedRoutes(t *testing.T) {
+	routes := jsonapi.DefaultRoutes("/api")
+	routes.Nested = []string{
+		"/api/organizations/{id}/projects",
+		"/api/organizations/{id}/teams/{id}/members",
+	}
+	router := jsonapi.MustCompileRoutes(routes)
+
+	org := jsonapi.ParentResource{Type: "organizations", ID: "1"}
+	team := jsonapi.ParentResource{Type: "teams", ID: "a/b"}
+
+	for _, tc := range []struct {
+		name string
+		url  string
+		want jsonapi.RequestContext
+	}{
+		{
+			name: "collection",
+			url:  "/api/organizations/1/projects",
+			want: jsonapi.RequestContext{ResourceType: "projects", Scope: []jsonapi.ParentResource{org}},
+		},
+		{
+			name: "resource",
+			url:  "/api/organizations/1/projects/2",
+			want: jsonapi.RequestContext{ResourceType: "projects", ResourceID: "2", Scope: []jsonapi.ParentResource{org}},
+		},
+		{
+			name: "relationship",
+			url:  "/api/organizations/1/projects/2/relationships/owner",
+			want: jsonapi.RequestContext{ResourceType: "projects", ResourceID: "2", Relationship: "owner",
+				Scope: []jsonapi.ParentResource{org}},
+		},
+		{
+			name: "related",
+			url:  "/api/organizations/1/projects/2/owner",
+			want: jsonapi.RequestContext{ResourceType: "projects", ResourceID: "2", Relationship: "owner", Related: true,
+				Scope: []jsonapi.ParentResource{org}},
+		},
+		{
+			name: "deeply nested",
+			url:  "/api/organizations/1/teams/a%2Fb/members/3",
+			want: jsonapi.RequestContext{ResourceType: "members", ResourceID: "3",
+				Scope: []jsonapi.ParentResource{org, team}},
+		},
+		{
+			name: "flat route",
+			url:  "/api/organizations/1/owner",
+			want: jsonapi.RequestContext{ResourceType: "organizations", ResourceID: "1", Relationship: "owner", Related: true},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", tc.url, nil)
+			got, err := router.ResolveContext(req)
+			assert.NoError(t, err)
+			assert.Equal(t, &tc.want, got)
+
+			url := router.ResolveURL(tc.want, "https://example.com")
+			assert.Equal(t, "https://example.com"+tc.url, url)
+		})
+	}
+
+	t.Run("resolves unknown scopes to flat routes", func(t *testing.T) {
+		ctx := jsonapi.RequestContext{ResourceType: "projects", ResourceID: "2",
+			Scope: []jsonapi.ParentResource{{Type: "users", ID: "1"}}}
+		assert.Equal(t, "https://example.com/api/projects/2", router.ResolveURL(ctx, "https://example.com"))
+	})
+}
+
+func TestMustCompileRoutes(t *testing.T) {
+	assert.Panics(t, func() { jsonapi.MustCompileRoutes(jsonapi.Routes{}) })
+	assert.NotPanics(t, func() { jsonapi.MustCompileRoutes(jsonapi.DefaultRoutes("/v2")) })
+}
@@ -0,0 +1,427 @@
+// Package async processes JSON:API requests asynchronously, as described by the JSON:API
+// recommendations: the server responds to the request with 202 Accepted and a
+// "Content-Location" header pointing at a job resource, runs the work in the background,
+// and redirects clients polling the job to the created or updated resource with
+// 303 See Other once the work completes.
+//
+// A [Runner] wraps the handlers that accept asynchronous requests, and serves the job
+// endpoints:
+//
+//	runner := async.NewRunner(async.NewMemoryStore())
+//	mux := server.ResourceMux{
+//		"photos": server.Resource{Create: runner.Accept(createPhoto)},
+//		async.ResourceType: runner.Resource(),
+//	}
+//
+// Handlers validate the request synchronously and return the work to run in the
+// background:
+//
+//	func createPhoto(w http.ResponseWriter, r *http.Request) async.Work {
+//		photo := Photo{}
+//		if err := jsonapi.Unmarshal(jsonapi.FromContext(r.Context()).Document, &photo); err != nil {
+//			server.Error(w, err, http.StatusBadRequest)
+//			return nil
+//		}
+//		return func(ctx context.Context) (*jsonapi.Resource, error) {
+//			id, err := processPhoto(ctx, photo)
+//			return &jsonapi.Resource{Type: "photos", ID: id}, err
+//		}
+//	}
+package async
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gonobo/jsonapi/v2"
+	"github.com/gonobo/jsonapi/v2/server"
+)
+
+const (
+	// HeaderKeyContentLocation is the header that points at the job of an accepted request.
+	HeaderKeyContentLocation = "Content-Location"
+	// HeaderKeyRetryAfter is the header that suggests when clients should poll a running job again.
+	HeaderKeyRetryAfter = "Retry-After"
+)
+
+var (
+	// ErrShutdown is returned when the runner no longer accepts jobs.
+	ErrShutdown = errors.New("async runner is shut down")
+)
+
+// jobFailed is the error detail of failed jobs reported to clients. The cause of the
+// failure is logged, rather than exposed.
+const jobFailed = "the job failed"
+
+// Work is the background processing of an accepted request. Work should stop when ctx is
+// canceled. On success, it returns the identifier of the created or updated resource, if
+// any; clients polling the job are redirected to it.
+type Work func(ctx context.Context) (*jsonapi.Resource, error)
+
+// PrepareFunc validates a request and returns the work to run in the background. If the
+// request cannot be accepted, PrepareFunc writes the response itself and returns nil.
+type PrepareFunc func(w http.ResponseWriter, r *http.Request) Work
+
+// Runner runs the work of accepted requests in the background, tracking its progress
+// with jobs. Runner is safe for concurrent use.
+type Runner struct {
+	store      Store
+	baseURL    string
+	retryAfter time.Duration
+	owner      func(*http.Request) string
+	mu         sync.Mutex
+	active     map[string]activeJob
+	shutdown   bool
+	wg         sync.WaitGroup
+}
+
+type activeJob struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewRunner creates a new runner that stores jobs in the provided store.
+func NewRunner(store Store, options ...func(*Runner)) *Runner {
+	runner := &Runner{store: store, active: make(map[string]activeJob)}
+	for _, option := range options {
+		option(runner)
+	}
+	return runner
+}
+
+// WithBaseURL sets the base URL of the job and resource URLs written by the runner.
+// By default, URLs are relative to the server root.
+func WithBaseURL(baseURL string) func(*Runner) {
+	return func(r *Runner) {
+		r.baseURL = baseURL
+	}
+}
+
+// WithRetryAfter sets the "Retry-After" header of responses to polls of unfinished jobs.
+func WithRetryAfter(d time.Duration) func(*Runner) {
+	return func(r *Runner) {
+		r.retryAfter = d
+	}
+}
+
+// WithOwner restricts the jobs to the principal that submitted the request, as identified
+// by the provided function -- typically from a value stored in the request's context by an
+// authentication middleware. Requests for the jobs of other principals are answered with
+// 404 Not Found. Without an owner, any client that knows the id of a job can poll and
+// cancel it.
+func WithOwner(owner func(*http.Request) string) func(*Runner) {
+	return func(r *Runner) {
+		r.owner = owner
+	}
+}
+
+// Accept returns a handler that processes requests asynchronously. The work returned by
+// prepare runs in the background with a context that retains the request's values, but
+// is only canceled by [Runner.Cancel], or when [Runner.Shutdown] times out. The request
+// is answered with 202 Accepted, the job resource, and its URL in the "Content-Location"
+// header.
+func (rn *Runner) Accept(prepare PrepareFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		work := prepare(w, r)
+		if work == nil {
+			return
+		}
+
+		job, err := rn.start(r.Context(), rn.ownerOf(r), work)
+		if errors.Is(err, ErrShutdown) {
+			server.Error(w, err, http.StatusServiceUnavailable)
+			return
+		} else if err != nil {
+			server.LoggerFromContext(r.Context()).ErrorContext(r.Context(), "jsonapi: failed to start job",
+				slog.Any("error", err))
+			server.Error(w, errors.New("failed to start job"), http.StatusInternalServerError)
+			return
+		}
+
+		location := rn.jobURL(r, job.ID)
+		w.Header().Set(HeaderKeyContentLocation, location)
+		server.Write(w, job, http.StatusAccepted, server.WriteLink("self", location))
+	})
+}
+
+// Resource returns the handlers of the job endpoints, to be registered with a
+// [server.ResourceMux] under [ResourceType]. Fetching a job returns its status, or
+// redirects to the resulting resource with 303 See Other once the job has completed.
+// Deleting a job cancels it, and returns its final status.
+func (rn *Runner) Resource() server.Resource {
+	return server.Resource{
+		Get:    http.HandlerFunc(rn.serveJob),
+		Delete: http.HandlerFunc(rn.serveCancel),
+	}
+}
+
+// Cancel cancels the job and waits for its work to return, or for ctx to be done.
+// Canceling a finished job has no effect.
+func (rn *Runner) Cancel(ctx context.Context, id string) error {
+	rn.mu.Lock()
+	active, ok := rn.active[id]
+	rn.mu.Unlock()
+
+	if !ok {
+		_, err := rn.store.GetJob(ctx, id)
+		return err
+	}
+
+	active.cancel()
+
+	select {
+	case <-active.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Shutdown stops accepting jobs, and waits for the running jobs to finish. If ctx is done
+// first, the remaining jobs are canceled, and Shutdown returns the context's error once
+// their work has returned.
+func (rn *Runner) Shutdown(ctx context.Context) error {
+	rn.mu.Lock()
+	rn.shutdown = true
+	rn.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		rn.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+
+	rn.mu.Lock()
+	for _, active := range rn.active {
+		active.cancel()
+	}
+	rn.mu.Unlock()
+
+	<-done
+	return ctx.Err()
+}
+
+// start stores a new pending job, and runs its work in the background. The job is stored
+// without holding the runner's lock, so that accepting requests is not serialized behind
+// the store.
+func (rn *Runner) start(ctx context.Context, owner string, work Work) (Job, error) {
+	id, err := newJobID()
+	if err != nil {
+		return Job{}, err
+	}
+
+	now := time.Now().UTC()
+	job := Job{ID: id, Status: StatusPending, CreatedAt: now, UpdatedAt: now, Owner: owner}
+
+	if rn.isShutdown() {
+		return Job{}, ErrShutdown
+	} else if err := rn.store.CreateJob(ctx, job); err != nil {
+		return Job{}, err
+	}
+
+	rn.mu.Lock()
+	if rn.shutdown {
+		// the runner was shut down while the job was stored.
+		rn.mu.Unlock()
+		job.Status = StatusCanceled
+		job.UpdatedAt = time.Now().UTC()
+		rn.update(ctx, job)
+		return Job{}, ErrShutdown
+	}
+
+	jobCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	active := activeJob{cancel: cancel, done: make(chan struct{})}
+	rn.active[id] = active
+	rn.wg.Add(1)
+	rn.mu.Unlock()
+
+	go rn.run(jobCtx, job, work, active)
+	return job, nil
+}
+
+func (rn *Runner) isShutdown() bool {
+	rn.mu.Lock()
+	defer rn.mu.Unlock()
+	return rn.shutdown
+}
+
+// run performs the work of the job, recording its progress in the store.
+func (rn *Runner) run(ctx context.Context, job Job, work Work, active activeJob) {
+	defer func() {
+		active.cancel()
+		rn.mu.Lock()
+		delete(rn.active, job.ID)
+		rn.mu.Unlock()
+		close(active.done)
+		rn.wg.Done()
+	}()
+
+	if ctx.Err() == nil {
+		job.Status = StatusRunning
+		job.UpdatedAt = time.Now().UTC()
+		rn.update(ctx, job)
+	}
+
+	result, err := perform(ctx, work)
+
+	switch {
+	case err != nil && ctx.Err() != nil:
+		job.Status = StatusCanceled
+	case err != nil:
+		job.Status = StatusFailed
+		job.Error = jobFailed
+		attrs := []any{slog.String("job", job.ID), slog.Any("error", err)}
+		if panicked := (panicError{}); errors.As(err, &panicked) {
+			attrs = append(attrs, slog.String("stack", string(panicked.stack)))
+		}
+		server.LoggerFromContext(ctx).ErrorContext(ctx, "jsonapi: job failed", attrs...)
+	default:
+		job.Status = StatusCompleted
+		job.Result = result
+	}
+
+	job.UpdatedAt = time.Now().UTC()
+	rn.update(context.WithoutCancel(ctx), job)
+}
+
+// perform runs the work, unless the job was canceled before it started. Panics are
+// recovered and returned as a [panicError].
+func perform(ctx context.Context, work Work) (result *jsonapi.Resource, err error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = panicError{value: recovered, stack: debug.Stack()}
+		}
+	}()
+
+	return work(ctx)
+}
+
+// panicError is the error of jobs whose work panicked. It carries the stack trace of
+// the panic, so that it can be logged with the failure.
+type panicError struct {
+	value any
+	stack []byte
+}
+
+func (e panicError) Error() string {
+	return fmt.Sprintf("job panicked: %v", e.value)
+}
+
+func (rn *Runner) update(ctx context.Context, job Job) {
+	if err := rn.store.UpdateJob(ctx, job); err != nil {
+		server.LoggerFromContext(ctx).ErrorContext(ctx, "jsonapi: failed to update job",
+			slog.String("job", job.ID), slog.String("status", string(job.Status)), slog.Any("error", err))
+	}
+}
+
+// serveJob responds with the status of the requested job, or redirects to its result.
+func (rn *Runner) serveJob(w http.ResponseWriter, r *http.Request) {
+	job, err := rn.ownedJob(r)
+	if err != nil {
+		rn.jobError(w, r, err)
+		return
+	}
+
+	if job.Status == StatusCompleted && job.Result != nil {
+		resolver := server.URLResolverFromContext(r.Context())
+		location := resolver.ResolveURL(jsonapi.RequestContext{
+			ResourceType: job.Result.Type,
+			ResourceID:   job.Result.ID,
+		}, rn.baseURL)
+		w.Header().Set(server.HeaderKeyLocation, location)
+		server.Write(w, nil, http.StatusSeeOther)
+		return
+	}
+
+	if !job.Status.Done() && rn.retryAfter > 0 {
+		seconds := int(math.Ceil(rn.retryAfter.Seconds()))
+		w.Header().Set(HeaderKeyRetryAfter, strconv.Itoa(seconds))
+	}
+
+	server.Write(w, job, http.StatusOK, server.WriteLink("self", rn.jobURL(r, job.ID)))
+}
+
+// serveCancel cancels the requested job, and responds with its status.
+func (rn *Runner) serveCancel(w http.ResponseWriter, r *http.Request) {
+	job, err := rn.ownedJob(r)
+	if err != nil {
+		rn.jobError(w, r, err)
+		return
+	}
+
+	if err := rn.Cancel(r.Context(), job.ID); err != nil {
+		rn.jobError(w, r, err)
+		return
+	}
+
+	job, err = rn.store.GetJob(r.Context(), job.ID)
+	if err != nil {
+		rn.jobError(w, r, err)
+		return
+	}
+
+	server.Write(w, job, http.StatusOK, server.WriteLink("self", rn.jobURL(r, job.ID)))
+}
+
+// ownedJob returns the requested job. Jobs owned by other principals are not found.
+func (rn *Runner) ownedJob(r *http.Request) (Job, error) {
+	id := jsonapi.FromContext(r.Context()).ResourceID
+	job, err := rn.store.GetJob(r.Context(), id)
+	if err != nil {
+		return Job{}, err
+	}
+	if job.Owner != rn.ownerOf(r) {
+		return Job{}, fmt.Errorf("%w: %s", ErrJobNotFound, id)
+	}
+	return job, nil
+}
+
+func (rn *Runner) ownerOf(r *http.Request) string {
+	if rn.owner == nil {
+		return ""
+	}
+	return rn.owner(r)
+}
+
+func (rn *Runner) jobError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, ErrJobNotFound) {
+		server.Error(w, err, http.StatusNotFound)
+		return
+	}
+	server.LoggerFromContext(r.Context()).ErrorContext(r.Context(), "jsonapi: failed to retrieve job",
+		slog.Any("error", err))
+	server.Error(w, errors.New("failed to retrieve job"), http.StatusInternalServerError)
+}
+
+func (rn *Runner) jobURL(r *http.Request, id string) string {
+	resolver := server.URLResolverFromContext(r.Context())
+	return resolver.ResolveURL(jsonapi.RequestContext{ResourceType: ResourceType, ResourceID: id}, rn.baseURL)
+}
+
+func newJobID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("failed to generate job id: %w", err)
+	}
+	return hex.EncodeToString(id), nil
+}
@@ -0,0 +1,235 @@
+package async_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gonobo/jsonapi/v2"
+	"github.com/gonobo/jsonapi/v2/server"
+	"github.com/gonobo/jsonapi/v2/server/async"
+	"github.com/stretchr/testify/assert"
+)
+
+type fixture struct {
+	runner  *async.Runner
+	handler http.Handler
+	logs    *bytes.Buffer
+}
+
+// newFixture serves photo creation requests with the work, and the job endpoints.
+func newFixture(work async.Work, options ...func(*async.Runner)) fixture {
+	runner := async.NewRunner(async.NewMemoryStore(), options...)
+	create := runner.Accept(func(w http.ResponseWriter, r *http.Request) async.Work {
+		if r.URL.Query().Get("invalid") != "" {
+			server.Error(w, errors.New("invalid photo"), http.StatusBadRequest)
+			return nil
+		}
+		return work
+	})
+
+	logs := &bytes.Buffer{}
+	handler := server.Handle(server.ResourceMux{
+		"photos":           server.Resource{Create: create},
+		async.ResourceType: runner.Resource(),
+	}, server.WithLogger(slog.New(slog.NewJSONHandler(logs, nil))))
+	return fixture{runner: runner, handler: handler, logs: logs}
+}
+
+func (f fixture) serve(method, target string) *http.Response {
+	w := httptest.NewRecorder()
+	f.handler.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+	return w.Result()
+}
+
+// accept submits a creation request, returning the job URL.
+func (f fixture) accept(t *testing.T) string {
+	res := f.serve("POST", "/photos")
+	assert.Equal(t, http.StatusAccepted, res.StatusCode)
+
+	location := res.Header.Get(async.HeaderKeyContentLocation)
+	assert.True(t, strings.HasPrefix(location, "/jobs/"), "unexpected location %q", location)
+
+	doc := jsonapi.Document{}
+	assert.NoError(t, jsonapi.Decode(res.Body, &doc))
+	job := doc.Data.First()
+	assert.Equal(t, async.ResourceType, job.Type)
+	assert.Equal(t, "/jobs/"+job.ID, location)
+	assert.Equal(t, location, doc.Links["self"].Href)
+	return location
+}
+
+func (f fixture) status(t *testing.T, location string) async.Status {
+	res := f.serve("GET", location)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+
+	doc := jsonapi.Document{}
+	assert.NoError(t, jsonapi.Decode(res.Body, &doc))
+	status, _ := doc.Data.First().Attributes["status"].(string)
+	return async.Status(status)
+}
+
+func TestRunner(t *testing.T) {
+	t.Run("redirects to the result", func(t *testing.T) {
+		release := make(chan struct{})
+		f := newFixture(func(ctx context.Context) (*jsonapi.Resource, error) {
+			<-release
+			return &jsonapi.Resource{Type: "photos", ID: "42"}, nil
+		}, async.WithRetryAfter(1500*time.Millisecond))
+
+		location := f.accept(t)
+
+		res := f.serve("GET", location)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "2", res.Header.Get(async.HeaderKeyRetryAfter))
+
+		close(release)
+		assert.NoError(t, f.runner.Shutdown(context.Background()))
+
+		res = f.serve("GET", location)
+		assert.Equal(t, http.StatusSeeOther, res.StatusCode)
+		assert.Equal(t, "/photos/42", res.Header.Get(server.HeaderKeyLocation))
+	})
+
+	t.Run("reports failures", func(t *testing.T) {
+		f := newFixture(func(ctx context.Context) (*jsonapi.Resource, error) {
+			return nil, errors.New("processing failed")
+		})
+
+		location := f.accept(t)
+		assert.NoError(t, f.runner.Shutdown(context.Background()))
+
+		res := f.serve("GET", location)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Empty(t, res.Header.Get(async.HeaderKeyRetryAfter))
+
+		doc := jsonapi.Document{}
+		assert.NoError(t, jsonapi.Decode(res.Body, &doc))
+		job := doc.Data.First()
+		assert.Equal(t, string(async.StatusFailed), job.Attributes["status"])
+		assert.Equal(t, "the job failed", job.Attributes["error"])
+	})
+
+	t.Run("recovers panics", func(t *testing.T) {
+		f := newFixture(func(ctx context.Context) (*jsonapi.Resource, error) {
+			panic("oops")
+		})
+
+		location := f.accept(t)
+		assert.NoError(t, f.runner.Shutdown(context.Background()))
+		assert.Equal(t, async.StatusFailed, f.status(t, location))
+
+		entry := map[string]any{}
+		assert.NoError(t, json.Unmarshal(f.logs.Bytes(), &entry))
+		assert.Equal(t, "job panicked: oops", entry["error"])
+		assert.Contains(t, entry["stack"], "goroutine")
+	})
+
+	t.Run("completes without result", func(t *testing.T) {
+		f := newFixture(func(ctx context.Context) (*jsonapi.Resource, error) {
+			return nil, nil
+		})
+
+		location := f.accept(t)
+		assert.NoError(t, f.runner.Shutdown(context.Background()))
+		assert.Equal(t, async.StatusCompleted, f.status(t, location))
+	})
+
+	t.Run("cancels jobs", func(t *testing.T) {
+		started := make(chan struct{})
+		f := newFixture(func(ctx context.Context) (*jsonapi.Resource, error) {
+			close(started)
+			<-ctx.Done()
+			return nil, ctx.Err()
+		})
+
+		location := f.accept(t)
+		<-started
+		assert.Equal(t, async.StatusRunning, f.status(t, location))
+
+		res := f.serve("DELETE", location)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, async.StatusCanceled, f.status(t, location))
+
+		// canceling a finished job has no effect.
+		res = f.serve("DELETE", location)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+	})
+
+	t.Run("cancels jobs on shutdown", func(t *testing.T) {
+		f := newFixture(func(ctx context.Context) (*jsonapi.Resource, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		})
+
+		location := f.accept(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		assert.ErrorIs(t, f.runner.Shutdown(ctx), context.Canceled)
+		assert.Equal(t, async.StatusCanceled, f.status(t, location))
+
+		res := f.serve("POST", "/photos")
+		assert.Equal(t, http.StatusServiceUnavailable, res.StatusCode)
+	})
+
+	t.Run("rejects invalid requests", func(t *testing.T) {
+		f := newFixture(func(ctx context.Context) (*jsonapi.Resource, error) {
+			t.Error("unexpected work")
+			return nil, nil
+		})
+
+		res := f.serve("POST", "/photos?invalid=true")
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+	})
+
+	t.Run("restricts jobs to their owner", func(t *testing.T) {
+		f := newFixture(func(ctx context.Context) (*jsonapi.Resource, error) {
+			return nil, nil
+		}, async.WithOwner(func(r *http.Request) string { return r.Header.Get("X-Principal") }))
+
+		serve := func(method, target, principal string) *http.Response {
+			req := httptest.NewRequest(method, target, nil)
+			req.Header.Set("X-Principal", principal)
+			w := httptest.NewRecorder()
+			f.handler.ServeHTTP(w, req)
+			return w.Result()
+		}
+
+		res := serve("POST", "/photos", "alice")
+		assert.Equal(t, http.StatusAccepted, res.StatusCode)
+		location := res.Header.Get(async.HeaderKeyContentLocation)
+		assert.NoError(t, f.runner.Shutdown(context.Background()))
+
+		assert.Equal(t, http.StatusOK, serve("GET", location, "alice").StatusCode)
+		assert.Equal(t, http.StatusNotFound, serve("GET", location, "bob").StatusCode)
+		assert.Equal(t, http.StatusNotFound, serve("DELETE", location, "bob").StatusCode)
+	})
+
+	t.Run("returns 404 on unknown jobs", func(t *testing.T) {
+		f := newFixture(nil)
+		assert.Equal(t, http.StatusNotFound, f.serve("GET", "/jobs/unknown").StatusCode)
+		assert.Equal(t, http.StatusNotFound, f.serve("DELETE", "/jobs/unknown").StatusCode)
+	})
+
+	t.Run("resolves urls with the base url", func(t *testing.T) {
+		f := newFixture(func(ctx context.Context) (*jsonapi.Resource, error) {
+			return &jsonapi.Resource{Type: "photos", ID: "42"}, nil
+		}, async.WithBaseURL("https://example.com"))
+
+		res := f.serve("POST", "/photos")
+		location := res.Header.Get(async.HeaderKeyContentLocation)
+		assert.True(t, strings.HasPrefix(location, "https://example.com/jobs/"))
+		assert.NoError(t, f.runner.Shutdown(context.Background()))
+
+		res = f.serve("GET", strings.TrimPrefix(location, "https://example.com"))
+		assert.Equal(t, http.StatusSeeOther, res.StatusCode)
+		assert.Equal(t, "https://example.com/photos/42", res.Header.Get(server.HeaderKeyLocation))
+	})
+}
@@ -0,0 +1,189 @@
+package async
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gonobo/jsonapi/v2"
+)
+
+// ResourceType is the resource type of jobs.
+const ResourceType = "jobs"
+
+const (
+	// DefaultJobTTL is the default duration for which a [MemoryStore] keeps finished jobs.
+	DefaultJobTTL = 24 * time.Hour
+
+	// DefaultStaleJobTTL is the default duration for which a [MemoryStore] keeps pending
+	// and running jobs that are not updated.
+	DefaultStaleJobTTL = 24 * time.Hour
+
+	// jobSweepInterval is the minimum interval between sweeps of expired jobs.
+	jobSweepInterval = time.Minute
+)
+
+var (
+	// ErrJobNotFound is returned when a job does not exist.
+	ErrJobNotFound = errors.New("job not found")
+	// ErrJobExists is returned when creating a job whose id is already in use.
+	ErrJobExists = errors.New("job already exists")
+)
+
+// Status is the processing status of a job.
+type Status string
+
+const (
+	StatusPending   Status = "pending"   // The job is waiting to run.
+	StatusRunning   Status = "running"   // The job is running.
+	StatusCompleted Status = "completed" // The job completed successfully.
+	StatusFailed    Status = "failed"    // The job failed; see [Job.Error].
+	StatusCanceled  Status = "canceled"  // The job was canceled before it completed.
+)
+
+// Done returns true if the status is final.
+func (s Status) Done() bool {
+	return s == StatusCompleted || s == StatusFailed || s == StatusCanceled
+}
+
+// Job is the resource that tracks the processing of an asynchronous request.
+type Job struct {
+	ID        string            `jsonapi:"primary,jobs"`
+	Status    Status            `jsonapi:"attr,status"`
+	Error     string            `jsonapi:"attr,error,omitempty"`
+	CreatedAt time.Time         `jsonapi:"attr,createdAt"`
+	UpdatedAt time.Time         `jsonapi:"attr,updatedAt"`
+	Result    *jsonapi.Resource // The resource created or updated by the job, once completed.
+	Owner     string            // The principal that submitted the request; see [WithOwner].
+}
+
+// Store persists jobs. Implementations must be safe for concurrent use.
+type Store interface {
+	// CreateJob stores a new job. If a job with the same id exists, CreateJob
+	// should return an error wrapping [ErrJobExists].
+	CreateJob(ctx context.Context, job Job) error
+	// GetJob returns the job with the provided id. If the job does not exist, GetJob
+	// should return an error wrapping [ErrJobNotFound].
+	GetJob(ctx context.Context, id string) (Job, error)
+	// UpdateJob replaces the stored job. If the job does not exist, UpdateJob
+	// should return an error wrapping [ErrJobNotFound].
+	UpdateJob(ctx context.Context, job Job) error
+}
+
+// MemoryStore is a [Store] that keeps jobs in memory. Finished jobs expire once they
+// have been finished for the store's ttl, and pending or running jobs expire once they
+// have not been updated for the store's stale job ttl -- for instance, when the process
+// running them exits. Expired jobs are not found, and are removed by periodic sweeps.
+// The zero value is ready to use.
+type MemoryStore struct {
+	mu       sync.RWMutex
+	jobs     map[string]memoryJob
+	ttl      time.Duration
+	staleTTL time.Duration
+	swept    time.Time
+}
+
+type memoryJob struct {
+	Job
+	expires time.Time // The expiration time of the job.
+}
+
+// NewMemoryStore creates a new in-memory job store.
+func NewMemoryStore(options ...func(*MemoryStore)) *MemoryStore {
+	store := &MemoryStore{}
+	for _, option := range options {
+		option(store)
+	}
+	return store
+}
+
+// WithJobTTL sets the duration for which finished jobs are kept. Defaults to [DefaultJobTTL].
+func WithJobTTL(ttl time.Duration) func(*MemoryStore) {
+	return func(s *MemoryStore) {
+		s.ttl = ttl
+	}
+}
+
+// WithStaleJobTTL sets the duration for which pending and running jobs are kept after
+// their last update. Defaults to [DefaultStaleJobTTL].
+func WithStaleJobTTL(ttl time.Duration) func(*MemoryStore) {
+	return func(s *MemoryStore) {
+		s.staleTTL = ttl
+	}
+}
+
+// CreateJob stores a new job.
+func (s *MemoryStore) CreateJob(ctx context.Context, job Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.swept) >= jobSweepInterval {
+		s.sweep(now)
+	}
+
+	if stored, ok := s.jobs[job.ID]; ok && !stored.expired(now) {
+		return fmt.Errorf("%w: %s", ErrJobExists, job.ID)
+	}
+	if s.jobs == nil {
+		s.jobs = make(map[string]memoryJob)
+	}
+	s.jobs[job.ID] = s.record(job, now)
+	return nil
+}
+
+// GetJob returns the job with the provided id.
+func (s *MemoryStore) GetJob(ctx context.Context, id string) (Job, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stored, ok := s.jobs[id]
+	if !ok || stored.expired(time.Now()) {
+		return Job{}, fmt.Errorf("%w: %s", ErrJobNotFound, id)
+	}
+	return stored.Job, nil
+}
+
+// UpdateJob replaces the stored job.
+func (s *MemoryStore) UpdateJob(ctx context.Context, job Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if stored, ok := s.jobs[job.ID]; !ok || stored.expired(now) {
+		return fmt.Errorf("%w: %s", ErrJobNotFound, job.ID)
+	}
+	s.jobs[job.ID] = s.record(job, now)
+	return nil
+}
+
+// record returns the stored form of the job, setting its expiration.
+func (s *MemoryStore) record(job Job, now time.Time) memoryJob {
+	ttl := s.ttl
+	if ttl == 0 {
+		ttl = DefaultJobTTL
+	}
+	if !job.Status.Done() {
+		ttl = s.staleTTL
+		if ttl == 0 {
+			ttl = DefaultStaleJobTTL
+		}
+	}
+	return memoryJob{Job: job, expires: now.Add(ttl)}
+}
+
+// sweep removes the expired jobs.
+func (s *MemoryStore) sweep(now time.Time) {
+	for id, stored := range s.jobs {
+		if stored.expired(now) {
+			delete(s.jobs, id)
+		}
+	}
+	s.swept = now
+}
+
+func (j memoryJob) expired(now time.Time) bool {
+	return !now.Before(j.expires)
+}
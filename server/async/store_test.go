@@ -0,0 +1,78 @@
+package async_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gonobo/jsonapi/v2/server/async"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	store := async.NewMemoryStore()
+
+	_, err := store.GetJob(ctx, "1")
+	assert.ErrorIs(t, err, async.ErrJobNotFound)
+	assert.ErrorIs(t, store.UpdateJob(ctx, async.Job{ID: "1"}), async.ErrJobNotFound)
+
+	assert.NoError(t, store.CreateJob(ctx, async.Job{ID: "1", Status: async.StatusPending}))
+	assert.ErrorIs(t, store.CreateJob(ctx, async.Job{ID: "1"}), async.ErrJobExists)
+
+	assert.NoError(t, store.UpdateJob(ctx, async.Job{ID: "1", Status: async.StatusRunning}))
+	job, err := store.GetJob(ctx, "1")
+	assert.NoError(t, err)
+	assert.Equal(t, async.Job{ID: "1", Status: async.StatusRunning}, job)
+}
+
+func TestMemoryStoreExpiresFinishedJobs(t *testing.T) {
+	ctx := context.Background()
+	store := async.NewMemoryStore(async.WithJobTTL(10 * time.Millisecond))
+
+	assert.NoError(t, store.CreateJob(ctx, async.Job{ID: "running", Status: async.StatusRunning}))
+	assert.NoError(t, store.CreateJob(ctx, async.Job{ID: "done", Status: async.StatusPending}))
+	assert.NoError(t, store.UpdateJob(ctx, async.Job{ID: "done", Status: async.StatusCompleted}))
+
+	_, err := store.GetJob(ctx, "done")
+	assert.NoError(t, err)
+
+	time.Sleep(20 * time.Millisecond)
+
+	_, err = store.GetJob(ctx, "done")
+	assert.ErrorIs(t, err, async.ErrJobNotFound)
+	assert.ErrorIs(t, store.UpdateJob(ctx, async.Job{ID: "done"}), async.ErrJobNotFound)
+	_, err = store.GetJob(ctx, "running")
+	assert.NoError(t, err)
+}
+
+func TestMemoryStoreExpiresStaleJobs(t *testing.T) {
+	ctx := context.Background()
+	store := async.NewMemoryStore(async.WithStaleJobTTL(20 * time.Millisecond))
+
+	assert.NoError(t, store.CreateJob(ctx, async.Job{ID: "pending", Status: async.StatusPending}))
+	assert.NoError(t, store.CreateJob(ctx, async.Job{ID: "running", Status: async.StatusPending}))
+
+	time.Sleep(10 * time.Millisecond)
+	assert.NoError(t, store.UpdateJob(ctx, async.Job{ID: "running", Status: async.StatusRunning}))
+	time.Sleep(15 * time.Millisecond)
+
+	_, err := store.GetJob(ctx, "pending")
+	assert.ErrorIs(t, err, async.ErrJobNotFound)
+	_, err = store.GetJob(ctx, "running")
+	assert.NoError(t, err)
+
+	time.Sleep(10 * time.Millisecond)
+
+	_, err = store.GetJob(ctx, "running")
+	assert.ErrorIs(t, err, async.ErrJobNotFound)
+	assert.ErrorIs(t, store.UpdateJob(ctx, async.Job{ID: "running"}), async.ErrJobNotFound)
+}
+
+func TestStatusDone(t *testing.T) {
+	assert.False(t, async.StatusPending.Done())
+	assert.False(t, async.StatusRunning.Done())
+	assert.True(t, async.StatusCompleted.Done())
+	assert.True(t, async.StatusFailed.Done())
+	assert.True(t, async.StatusCanceled.Done())
+}
@@ -0,0 +1,365 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+
+	"github.com/gonobo/jsonapi/v2"
+)
+
+var (
+	errForbidden = errors.New("forbidden")
+)
+
+// Action is the verb of an operation on resources.
+type Action string
+
+const (
+	ActionRead   Action = "read"   // Fetch resources, relationships or related resources.
+	ActionCreate Action = "create" // Create resources.
+	ActionUpdate Action = "update" // Update resources, or add, replace or remove relationship members.
+	ActionDelete Action = "delete" // Delete resources.
+)
+
+// Operation is an operation on resources, submitted to a [Policy] for authorization.
+type Operation struct {
+	Action       Action                   // The operation's verb.
+	ResourceType string                   // The resource type, e.g. "employees".
+	ResourceID   string                   // The resource ID, if the operation targets a single resource.
+	Relationship string                   // The relationship, if the operation targets a relationship.
+	Scope        []jsonapi.ParentResource // The parent resources scoping the operation, if any.
+}
+
+// Decision is the outcome of the authorization of an operation.
+type Decision int
+
+const (
+	DecisionAllow    Decision = iota // The operation is allowed.
+	DecisionForbid                   // The operation is rejected with a 403 Forbidden error.
+	DecisionNotFound                 // The operation is rejected with a 404 Not Found error, concealing the target.
+)
+
+// Policy authorizes the operations of the principal making a request -- typically
+// identified by a value stored in the request's context by an authentication middleware.
+type Policy interface {
+	// Authorize decides whether the operation is allowed.
+	Authorize(ctx context.Context, op Operation) Decision
+	// AuthorizeField returns true if the attribute or relationship of resources of the
+	// provided type may be read.
+	AuthorizeField(ctx context.Context, resourceType string, field string) bool
+}
+
+// WithPolicy registers the authorization policy with the handler. On each request, the policy
+// is stored in the request's context, where it can be retrieved downstream via [PolicyFromContext].
+//
+// Requests dispatched by a [ResourceMux] are authorized before they are served; requests to
+// relationships whose field may not be read are rejected with a 404 Not Found error. Response
+// documents of successful responses are redacted before they are written; see [RedactDocument].
+// Responses of other media types are written as they are.
+func WithPolicy(policy Policy) Options {
+	return func(c *Config) {
+		c.policy = policy
+	}
+}
+
+const policyContextKey contextkey = "jsonapi_policy"
+
+// ContextWithPolicy stores the policy in the parent context.
+func ContextWithPolicy(parent context.Context, policy Policy) context.Context {
+	return context.WithValue(parent, policyContextKey, policy)
+}
+
+// PolicyFromContext returns the policy stored in the context, if any.
+func PolicyFromContext(ctx context.Context) (Policy, bool) {
+	policy, ok := ctx.Value(policyContextKey).(Policy)
+	return policy, ok && policy != nil
+}
+
+// RequestOperation returns the operation requested by the http request with the JSON:API context.
+func RequestOperation(r *http.Request, ctx *jsonapi.RequestContext) Operation {
+	op := Operation{
+		Action:       ActionRead,
+		ResourceType: ctx.ResourceType,
+		ResourceID:   ctx.ResourceID,
+		Relationship: ctx.Relationship,
+		Scope:        ctx.Scope,
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		op.Action = ActionCreate
+		if ctx.Relationship != "" {
+			op.Action = ActionUpdate
+		}
+	case http.MethodPatch:
+		op.Action = ActionUpdate
+	case http.MethodDelete:
+		op.Action = ActionDelete
+		if ctx.Relationship != "" {
+			op.Action = ActionUpdate
+		}
+	}
+
+	return op
+}
+
+// Authorize evaluates the request against the policy stored in the request's context, if any,
+// writing an error response and returning false if the request is not allowed. Middleware that
+// answers requests without dispatching them to a [ResourceMux] -- such as a resolver of related
+// resources -- must authorize them first.
+func Authorize(w http.ResponseWriter, r *http.Request, ctx *jsonapi.RequestContext) bool {
+	policy, ok := PolicyFromContext(r.Context())
+	return !ok || authorize(w, r, policy, ctx)
+}
+
+// authorize evaluates the request against the policy, writing an error response and
+// returning false if the request is not allowed.
+func authorize(w http.ResponseWriter, r *http.Request, policy Policy, ctx *jsonapi.RequestContext) bool {
+	if ctx.Relationship != "" && !policy.AuthorizeField(r.Context(), ctx.ResourceType, ctx.Relationship) {
+		notFound(w)
+		return false
+	}
+
+	switch policy.Authorize(r.Context(), RequestOperation(r, ctx)) {
+	case DecisionAllow:
+		return true
+	case DecisionForbid:
+		Error(w, errForbidden, http.StatusForbidden)
+	default:
+		notFound(w)
+	}
+	return false
+}
+
+// RedactDocument removes the content of the document that the policy does not allow to be read:
+// resources of the primary data and included resources that may not be read -- a single
+// primary resource is replaced with null -- and the attributes and relationships of the
+// remaining resources whose fields may not be read. Included resources that are no longer
+// referenced by the remaining resources are removed as well, preserving full linkage.
+//
+// Redacted resources are replaced with copies; the resources of the original document,
+// which may be shared with loaders or stores, are left untouched.
+func RedactDocument(ctx context.Context, policy Policy, doc *jsonapi.Document) {
+	readable := func(resource *jsonapi.Resource) bool {
+		return policy.Authorize(ctx, Operation{
+			Action:       ActionRead,
+			ResourceType: resource.Type,
+			ResourceID:   resource.ID,
+		}) == DecisionAllow
+	}
+
+	var primary []*jsonapi.Resource
+	switch data := doc.Data.(type) {
+	case jsonapi.Many:
+		primary = redactResources(ctx, policy, filterResources(data.Value, readable))
+		doc.Data = jsonapi.Many{Value: primary}
+	case jsonapi.One:
+		if data.Value != nil && readable(data.Value) {
+			primary = redactResources(ctx, policy, []*jsonapi.Resource{data.Value})
+			doc.Data = jsonapi.One{Value: primary[0]}
+		} else {
+			doc.Data = jsonapi.One{}
+		}
+	}
+
+	if doc.Included != nil {
+		included := redactResources(ctx, policy, filterResources(doc.Included, readable))
+		doc.Included = referencedResources(primary, included)
+	}
+}
+
+// RedactResponse redacts the document of the recorded successful response with the policy
+// stored in the context, if any (see [RedactDocument]). If the response has an "ETag" header,
+// the tag is recomputed from the redacted document, so that principals who are allowed to
+// read different content never share a validator.
+func RedactResponse(ctx context.Context, mem *ResponseRecorder) {
+	policy, ok := PolicyFromContext(ctx)
+	if !ok || mem.Document == nil || mem.Status >= http.StatusBadRequest {
+		return
+	}
+
+	RedactDocument(ctx, policy, mem.Document)
+
+	if mem.Header().Get(HeaderKeyETag) == "" {
+		return
+	}
+	if tag, err := ETag(mem.Document); err == nil {
+		mem.Header().Set(HeaderKeyETag, tag)
+	} else {
+		mem.Header().Del(HeaderKeyETag)
+	}
+}
+
+// redactingWriter redacts the JSON:API documents written in successful responses with the
+// policy stored in the request context. Responses of other media types, and error responses,
+// are passed through to the underlying response writer as they are written.
+type redactingWriter struct {
+	http.ResponseWriter
+	ctx         context.Context
+	status      int
+	wroteHeader bool
+	buffered    bool
+	body        bytes.Buffer
+}
+
+// WriteHeader decides whether the response must be redacted. If so, the response is
+// buffered until it is flushed; otherwise, the status code is written immediately.
+func (rw *redactingWriter) WriteHeader(status int) {
+	if rw.wroteHeader {
+		return
+	}
+	rw.wroteHeader = true
+	rw.status = status
+
+	mediatype, _, _ := mime.ParseMediaType(rw.Header().Get("Content-Type"))
+	rw.buffered = mediatype == jsonapi.MediaType && status < http.StatusBadRequest
+	if !rw.buffered {
+		rw.ResponseWriter.WriteHeader(status)
+	}
+}
+
+// Write buffers the response document if the response must be redacted; otherwise, the
+// data is written to the underlying response writer.
+func (rw *redactingWriter) Write(p []byte) (int, error) {
+	if !rw.wroteHeader {
+		rw.WriteHeader(http.StatusOK)
+	}
+	if rw.buffered {
+		return rw.body.Write(p)
+	}
+	return rw.ResponseWriter.Write(p)
+}
+
+// Flush sends the data written so far to the client, unless the response is buffered
+// for redaction.
+func (rw *redactingWriter) Flush() {
+	if !rw.buffered {
+		swallowFlushResult(http.NewResponseController(rw.ResponseWriter).Flush())
+	}
+}
+
+// Hijack lets the caller take over the connection, if the underlying response writer supports it.
+func (rw *redactingWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(rw.ResponseWriter).Hijack()
+}
+
+// Unwrap returns the underlying response writer.
+func (rw *redactingWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// flush redacts the buffered response document, if any, and writes it to the underlying
+// response writer.
+func (rw *redactingWriter) flush() {
+	if !rw.buffered {
+		return
+	}
+
+	mem := NewRecorder()
+	mem.HeaderMap = rw.Header()
+	mem.Status = rw.status
+	if rw.body.Len() > 0 {
+		if _, err := mem.Write(rw.body.Bytes()); err != nil {
+			// the document cannot be redacted; do not risk disclosing its content.
+			mem.Header().Del("Content-Type")
+			mem.Header().Del(HeaderKeyETag)
+			Error(rw.ResponseWriter, fmt.Errorf("redact response: %w", err), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// the redacted document is shorter than the one written by the handler.
+	mem.Header().Del("Content-Length")
+	RedactResponse(rw.ctx, mem)
+	mem.Flush(rw.ResponseWriter)
+}
+
+// filterResources returns the resources for which keep returns true.
+func filterResources(resources []*jsonapi.Resource, keep func(*jsonapi.Resource) bool) []*jsonapi.Resource {
+	kept := make([]*jsonapi.Resource, 0, len(resources))
+	for _, resource := range resources {
+		if resource != nil && keep(resource) {
+			kept = append(kept, resource)
+		}
+	}
+	return kept
+}
+
+// redactResources replaces the resources with copies whose fields that may not be read
+// are removed.
+func redactResources(ctx context.Context, policy Policy, resources []*jsonapi.Resource) []*jsonapi.Resource {
+	for i, resource := range resources {
+		resources[i] = redactFields(ctx, policy, resource)
+	}
+	return resources
+}
+
+// redactFields returns a copy of the resource without the attributes and relationships
+// that may not be read.
+func redactFields(ctx context.Context, policy Policy, resource *jsonapi.Resource) *jsonapi.Resource {
+	redacted := *resource
+	if resource.Attributes != nil {
+		redacted.Attributes = make(map[string]any, len(resource.Attributes))
+		for name, value := range resource.Attributes {
+			if policy.AuthorizeField(ctx, resource.Type, name) {
+				redacted.Attributes[name] = value
+			}
+		}
+	}
+	if resource.Relationships != nil {
+		redacted.Relationships = make(jsonapi.RelationshipsNode, len(resource.Relationships))
+		for name, relationship := range resource.Relationships {
+			if policy.AuthorizeField(ctx, resource.Type, name) {
+				redacted.Relationships[name] = relationship
+			}
+		}
+	}
+	return &redacted
+}
+
+// referencedResources returns the included resources that are reachable from the primary
+// data through relationship linkage, in their original order.
+func referencedResources(primary []*jsonapi.Resource, included []*jsonapi.Resource) []*jsonapi.Resource {
+	type identity struct{ Type, ID string }
+
+	byIdentity := make(map[identity]*jsonapi.Resource, len(included))
+	for _, resource := range included {
+		byIdentity[identity{resource.Type, resource.ID}] = resource
+	}
+
+	reached := make(map[identity]bool, len(included))
+	queue := append([]*jsonapi.Resource{}, primary...)
+	for len(queue) > 0 {
+		resource := queue[0]
+		queue = queue[1:]
+		for _, relationship := range resource.Relationships {
+			if relationship == nil || relationship.Data == nil {
+				continue
+			}
+			for _, ref := range relationship.Data.Items() {
+				if ref == nil {
+					continue
+				}
+				key := identity{ref.Type, ref.ID}
+				if target, ok := byIdentity[key]; ok && !reached[key] {
+					reached[key] = true
+					queue = append(queue, target)
+				}
+			}
+		}
+	}
+
+	referenced := make([]*jsonapi.Resource, 0, len(reached))
+	for _, resource := range included {
+		if reached[identity{resource.Type, resource.ID}] {
+			referenced = append(referenced, resource)
+		}
+	}
+	return referenced
+}
@@ -0,0 +1,334 @@
+package server_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gonobo/jsonapi/v2"
+	"github.com/gonobo/jsonapi/v2/server"
+	"github.com/gonobo/jsonapi/v2/server/middleware"
+	"github.com/stretchr/testify/assert"
+)
+
+// staffPolicy hides salaries and payroll records, and forbids access to executives.
+type staffPolicy struct{}
+
+func (staffPolicy) Authorize(ctx context.Context, op server.Operation) server.Decision {
+	switch {
+	case op.ResourceType == "payrolls":
+		return server.DecisionNotFound
+	case op.ResourceType == "employees" && op.ResourceID == "ceo":
+		return server.DecisionForbid
+	case op.Action == server.ActionDelete:
+		return server.DecisionForbid
+	}
+	return server.DecisionAllow
+}
+
+func (staffPolicy) AuthorizeField(ctx context.Context, resourceType string, field string) bool {
+	return resourceType != "employees" || (field != "salary" && field != "payroll")
+}
+
+func employee(id string) *jsonapi.Resource {
+	return &jsonapi.Resource{
+		Type:       "employees",
+		ID:         id,
+		Attributes: map[string]any{"name": "Employee " + id, "salary": 100},
+		Relationships: jsonapi.RelationshipsNode{
+			"payroll":    {Data: jsonapi.One{Value: &jsonapi.Resource{Type: "payrolls", ID: id}}},
+			"department": {Data: jsonapi.One{Value: &jsonapi.Resource{Type: "departments", ID: "sales"}}},
+			"manager":    {Data: jsonapi.One{Value: &jsonapi.Resource{Type: "employees", ID: "ceo"}}},
+		},
+	}
+}
+
+func newEmployeeHandler(options ...server.Options) http.Handler {
+	get := func(w http.ResponseWriter, r *http.Request) {
+		ctx := jsonapi.FromContext(r.Context())
+		server.Write(w, jsonapi.NewSingleDocument(employee(ctx.ResourceID)), http.StatusOK)
+	}
+	list := func(w http.ResponseWriter, r *http.Request) {
+		server.Write(w, jsonapi.NewMultiDocument(employee("1"), employee("ceo")), http.StatusOK)
+	}
+	fetch := func(w http.ResponseWriter, r *http.Request) {
+		ctx := jsonapi.FromContext(r.Context())
+		doc := jsonapi.NewMultiDocument()
+		for _, id := range ctx.FetchIDs {
+			doc.Data = jsonapi.Many{Value: append(doc.Data.Items(), &jsonapi.Resource{Type: ctx.ResourceType, ID: id})}
+		}
+		server.Write(w, doc, http.StatusOK)
+	}
+
+	mux := server.ResourceMux{
+		"employees": server.Resource{
+			Get:    http.HandlerFunc(get),
+			List:   http.HandlerFunc(list),
+			Delete: http.HandlerFunc(get),
+			Relationships: server.Relationship{
+				Get: http.HandlerFunc(get),
+			},
+		},
+		"payrolls":    server.Resource{Get: http.HandlerFunc(get), List: http.HandlerFunc(fetch)},
+		"departments": server.Resource{List: http.HandlerFunc(fetch)},
+	}
+	return server.Handle(mux, options...)
+}
+
+func TestPolicy(t *testing.T) {
+	serve := func(handler http.Handler, method, target string) (*http.Response, jsonapi.Document) {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+		doc := jsonapi.Document{}
+		if w.Body.Len() > 0 {
+			assert.NoError(t, jsonapi.Decode(w.Result().Body, &doc))
+		}
+		return w.Result(), doc
+	}
+
+	handler := newEmployeeHandler(
+		server.WithPolicy(staffPolicy{}),
+		middleware.UseIncludeQueryParser(),
+		middleware.UseIncludedResourceResolver(),
+	)
+
+	t.Run("redacts fields", func(t *testing.T) {
+		res, doc := serve(handler, "GET", "/employees/1")
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		resource := doc.Data.First()
+		assert.Equal(t, map[string]any{"name": "Employee 1"}, resource.Attributes)
+		assert.NotContains(t, resource.Relationships, "payroll")
+		assert.Contains(t, resource.Relationships, "department")
+	})
+
+	t.Run("removes included resources", func(t *testing.T) {
+		res, doc := serve(handler, "GET", "/employees/1?include=payroll,department")
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Len(t, doc.Included, 1)
+		assert.Equal(t, "departments", doc.Included[0].Type)
+	})
+
+	t.Run("filters collections", func(t *testing.T) {
+		res, doc := serve(handler, "GET", "/employees")
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Len(t, doc.Data.Items(), 1)
+		assert.Equal(t, "1", doc.Data.First().ID)
+	})
+
+	t.Run("forbids operations", func(t *testing.T) {
+		res, _ := serve(handler, "GET", "/employees/ceo")
+		assert.Equal(t, http.StatusForbidden, res.StatusCode)
+
+		res, _ = serve(handler, "DELETE", "/employees/1")
+		assert.Equal(t, http.StatusForbidden, res.StatusCode)
+	})
+
+	t.Run("conceals resources", func(t *testing.T) {
+		res, _ := serve(handler, "GET", "/payrolls/1")
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+
+		res, _ = serve(handler, "GET", "/employees/1/relationships/payroll")
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	})
+
+	t.Run("conceals loaded to-one related resources", func(t *testing.T) {
+		loader := server.LoaderFunc(func(ctx context.Context, resourceType string, ids []string) ([]*jsonapi.Resource, error) {
+			items := make([]*jsonapi.Resource, 0, len(ids))
+			for _, id := range ids {
+				items = append(items, employee(id))
+			}
+			return items, nil
+		})
+		handler := newEmployeeHandler(
+			server.WithPolicy(staffPolicy{}),
+			server.WithLoader(loader),
+			middleware.UseRelatedResourceResolver(),
+		)
+
+		res, doc := serve(handler, "GET", "/employees/1/manager")
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.False(t, doc.Data.IsMany())
+		assert.Nil(t, doc.Data.First())
+	})
+
+	t.Run("authorizes related resource requests", func(t *testing.T) {
+		loader := server.LoaderFunc(func(ctx context.Context, resourceType string, ids []string) ([]*jsonapi.Resource, error) {
+			items := make([]*jsonapi.Resource, 0, len(ids))
+			for _, id := range ids {
+				item := employee(id)
+				item.Type = resourceType
+				items = append(items, item)
+			}
+			return items, nil
+		})
+
+		for _, options := range [][]server.Options{
+			{server.WithPolicy(staffPolicy{}), middleware.UseRelatedResourceResolver()},
+			{server.WithPolicy(staffPolicy{}), server.WithLoader(loader), middleware.UseRelatedResourceResolver()},
+		} {
+			handler := newEmployeeHandler(options...)
+
+			res, doc := serve(handler, "GET", "/employees/1/department")
+			assert.Equal(t, http.StatusOK, res.StatusCode)
+			assert.Equal(t, "sales", doc.Data.First().ID)
+
+			res, _ = serve(handler, "GET", "/employees/1/payroll")
+			assert.Equal(t, http.StatusNotFound, res.StatusCode)
+
+			res, _ = serve(handler, "GET", "/employees/ceo/department")
+			assert.Equal(t, http.StatusForbidden, res.StatusCode)
+		}
+	})
+
+	t.Run("passes other responses through", func(t *testing.T) {
+		mux := server.ResourceMux{
+			"reports": server.Resource{
+				List: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.Header().Set("Content-Type", "text/csv")
+					w.Write([]byte("name,salary\nEmployee 1,100\n"))
+				}),
+				Get: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					http.Error(w, "report failed", http.StatusInternalServerError)
+				}),
+			},
+		}
+		handler := server.Handle(mux, server.WithPolicy(staffPolicy{}))
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "/reports", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "text/csv", w.Header().Get("Content-Type"))
+		assert.Equal(t, "name,salary\nEmployee 1,100\n", w.Body.String())
+
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "/reports/1", nil))
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Equal(t, "report failed\n", w.Body.String())
+	})
+
+	t.Run("serves everything without policy", func(t *testing.T) {
+		handler := newEmployeeHandler(middleware.UseIncludeQueryParser(), middleware.UseIncludedResourceResolver())
+
+		res, doc := serve(handler, "GET", "/employees/ceo?include=payroll")
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Contains(t, doc.Data.First().Attributes, "salary")
+		assert.Len(t, doc.Included, 1)
+	})
+}
+
+func TestRequestOperation(t *testing.T) {
+	for _, tc := range []struct {
+		method string
+		ctx    jsonapi.RequestContext
+		want   server.Action
+	}{
+		{method: "GET", ctx: jsonapi.RequestContext{ResourceType: "items"}, want: server.ActionRead},
+		{method: "HEAD", ctx: jsonapi.RequestContext{ResourceType: "items", ResourceID: "1"}, want: server.ActionRead},
+		{method: "POST", ctx: jsonapi.RequestContext{ResourceType: "items"}, want: server.ActionCreate},
+		{method: "PATCH", ctx: jsonapi.RequestContext{ResourceType: "items", ResourceID: "1"}, want: server.ActionUpdate},
+		{method: "DELETE", ctx: jsonapi.RequestContext{ResourceType: "items", ResourceID: "1"}, want: server.ActionDelete},
+		{method: "POST", ctx: jsonapi.RequestContext{ResourceType: "items", ResourceID: "1", Relationship: "tags"}, want: server.ActionUpdate},
+		{method: "DELETE", ctx: jsonapi.RequestContext{ResourceType: "items", ResourceID: "1", Relationship: "tags"}, want: server.ActionUpdate},
+	} {
+		t.Run(tc.method, func(t *testing.T) {
+			op := server.RequestOperation(httptest.NewRequest(tc.method, "/", nil), &tc.ctx)
+			assert.Equal(t, tc.want, op.Action)
+			assert.Equal(t, tc.ctx.ResourceType, op.ResourceType)
+			assert.Equal(t, tc.ctx.ResourceID, op.ResourceID)
+			assert.Equal(t, tc.ctx.Relationship, op.Relationship)
+		})
+	}
+}
+
+func TestRedactDocument(t *testing.T) {
+	doc := jsonapi.NewMultiDocument(employee("1"), employee("ceo"))
+	doc.Included = []*jsonapi.Resource{
+		{Type: "payrolls", ID: "1"},
+		{Type: "departments", ID: "sales", Relationships: jsonapi.RelationshipsNode{
+			"manager": {Data: jsonapi.One{Value: &jsonapi.Resource{Type: "employees", ID: "2"}}},
+		}},
+		employee("2"),
+		employee("3"),
+	}
+
+	first := doc.Data.First()
+	server.RedactDocument(context.Background(), staffPolicy{}, doc)
+
+	// the original resources are left untouched.
+	assert.Contains(t, first.Attributes, "salary")
+	assert.Contains(t, first.Relationships, "payroll")
+
+	assert.Len(t, doc.Data.Items(), 1)
+	assert.NotContains(t, doc.Data.First().Attributes, "salary")
+
+	// the second employee is referenced through the department; the third is not referenced.
+	ids := []string{}
+	for _, resource := range doc.Included {
+		ids = append(ids, resource.Type+"/"+resource.ID)
+	}
+	assert.Equal(t, []string{"departments/sales", "employees/2"}, ids)
+	assert.NotContains(t, doc.Included[1].Attributes, "salary")
+}
+
+func TestRedactSingleResource(t *testing.T) {
+	doc := jsonapi.NewSingleDocument(employee("ceo"))
+	server.RedactDocument(context.Background(), staffPolicy{}, doc)
+	assert.Nil(t, doc.Data.First())
+
+	doc = jsonapi.NewSingleDocument(employee("1"))
+	server.RedactDocument(context.Background(), staffPolicy{}, doc)
+	assert.Equal(t, map[string]any{"name": "Employee 1"}, doc.Data.First().Attributes)
+}
+
+// openPolicy allows everything.
+type openPolicy struct{}
+
+func (openPolicy) Authorize(context.Context, server.Operation) server.Decision {
+	return server.DecisionAllow
+}
+func (openPolicy) AuthorizeField(context.Context, string, string) bool { return true }
+
+func TestPolicyETags(t *testing.T) {
+	serve := func(handler http.Handler, inm string) *http.Response {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/employees/1", nil)
+		if inm != "" {
+			req.Header.Set("If-None-Match", inm)
+		}
+		handler.ServeHTTP(w, req)
+		return w.Result()
+	}
+
+	staff := newEmployeeHandler(server.WithPolicy(staffPolicy{}), middleware.UseConditionalRequests())
+	open := newEmployeeHandler(server.WithPolicy(openPolicy{}), middleware.UseConditionalRequests())
+
+	staffTag := serve(staff, "").Header.Get(server.HeaderKeyETag)
+	openTag := serve(open, "").Header.Get(server.HeaderKeyETag)
+	assert.NotEmpty(t, staffTag)
+	assert.NotEqual(t, staffTag, openTag, "principals reading different content share a validator")
+
+	redacted := jsonapi.NewSingleDocument(employee("1"))
+	server.RedactDocument(context.Background(), staffPolicy{}, redacted)
+	want, err := server.ETag(redacted)
+	assert.NoError(t, err)
+	assert.Equal(t, want, staffTag)
+
+	assert.Equal(t, http.StatusOK, serve(staff, openTag).StatusCode)
+	assert.Equal(t, http.StatusNotModified, serve(staff, staffTag).StatusCode)
+}
+
+func TestRedactResponse(t *testing.T) {
+	mem := server.NewRecorder()
+	server.Write(mem, jsonapi.NewSingleDocument(employee("1")), http.StatusOK, server.WriteETag())
+	unredacted := mem.Header().Get(server.HeaderKeyETag)
+
+	ctx := server.ContextWithPolicy(context.Background(), staffPolicy{})
+	server.RedactResponse(ctx, mem)
+	assert.NotContains(t, mem.Document.Data.First().Attributes, "salary")
+
+	want, err := server.ETag(mem.Document)
+	assert.NoError(t, err)
+	assert.Equal(t, want, mem.Header().Get(server.HeaderKeyETag))
+	assert.NotEqual(t, unredacted, want)
+}
@@ -1,7 +1,9 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
+	"log/slog"
 	"net/http"
 	"slices"
 
@@ -10,10 +12,19 @@ import (
 
 type Config struct {
 	contextResolver jsonapi.ContextResolver
+	ctx             context.Context
 	documentOptions []DocumentOptions
+	etag            bool
+	instrumentation Instrumentation
 	jsonapiMarshal  jsonapiMarshalFunc
 	jsonMarshal     jsonMarshalFunc
+	limits          *Limits
+	loader          Loader
+	logger          *slog.Logger
+	memberNames     *jsonapi.MemberNames
 	middlewares     []Middleware
+	policy          Policy
+	urlResolver     jsonapi.URLResolver
 }
 
 type jsonapiMarshalFunc = func(any) (jsonapi.Document, error)
@@ -69,7 +80,7 @@ func (c Config) applyMiddleware(handler http.Handler) http.Handler {
 
 func DefaultConfig() Config {
 	return Config{
-		jsonapiMarshal:  jsonapi.Marshal,
+		jsonapiMarshal:  func(in any) (jsonapi.Document, error) { return jsonapi.Marshal(in) },
 		jsonMarshal:     json.Marshal,
 		contextResolver: jsonapi.DefaultContextResolver(),
 	}
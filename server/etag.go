@@ -0,0 +1,87 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/gonobo/jsonapi/v2"
+)
+
+const (
+	HeaderKeyETag         = "ETag"
+	HeaderKeyLastModified = "Last-Modified"
+)
+
+// EntityTagger is implemented by models that supply their own version token. When
+// a model that implements EntityTagger is written with [WriteETag], its token is used
+// as the response's entity tag instead of a hash of the response document.
+type EntityTagger interface {
+	// EntityTag returns the version token of the model, e.g. a revision number
+	// or a hash of its stored state. The token is quoted if it is not already.
+	EntityTag() string
+}
+
+// ETag computes a strong entity tag over the canonical serialization of the document.
+// Object members are serialized in sorted order, so documents with equal content
+// always produce the same tag.
+func ETag(doc *jsonapi.Document) (string, error) {
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return "", fmt.Errorf("etag: failed to marshal document: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return quoteETag(base64.RawURLEncoding.EncodeToString(sum[:])), nil
+}
+
+// WriteETag adds the "ETag" http header to the response. If the written data
+// implements [EntityTagger], its token is used; otherwise, the tag is computed
+// from the response document with [ETag].
+func WriteETag() WriteOptions {
+	return func(c *Config) {
+		c.etag = true
+	}
+}
+
+// WriteLastModified adds the "Last-Modified" http header to the response.
+func WriteLastModified(t time.Time) WriteOptions {
+	return WithDocumentOptions(func(w http.ResponseWriter, d *jsonapi.Document) error {
+		w.Header().Set(HeaderKeyLastModified, t.UTC().Format(http.TimeFormat))
+		return nil
+	})
+}
+
+// entityTag returns the entity tag of the written data and its document.
+func entityTag(data any, doc *jsonapi.Document) (string, error) {
+	if tagger, ok := entityTagger(data); ok {
+		return quoteETag(tagger.EntityTag()), nil
+	}
+	return ETag(doc)
+}
+
+func entityTagger(data any) (EntityTagger, bool) {
+	if tagger, ok := data.(EntityTagger); ok {
+		return tagger, true
+	}
+	// check the pointer receiver as well; models are often written by value.
+	value := reflect.ValueOf(data)
+	if value.Kind() != reflect.Pointer && value.IsValid() {
+		ptr := reflect.New(value.Type())
+		ptr.Elem().Set(value)
+		tagger, ok := ptr.Interface().(EntityTagger)
+		return tagger, ok
+	}
+	return nil, false
+}
+
+func quoteETag(tag string) string {
+	if strings.HasPrefix(tag, `"`) || strings.HasPrefix(tag, `W/"`) {
+		return tag
+	}
+	return `"` + tag + `"`
+}
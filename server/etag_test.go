@@ -0,0 +1,83 @@
+package server_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gonobo/jsonapi/v2"
+	"github.com/gonobo/jsonapi/v2/server"
+	"github.com/stretchr/testify/assert"
+)
+
+type versioned struct {
+	ID      string `jsonapi:"primary,things"`
+	Version string `jsonapi:"attr,version"`
+}
+
+func (v versioned) EntityTag() string {
+	return "v" + v.Version
+}
+
+func TestETag(t *testing.T) {
+	newDoc := func(attrs map[string]any) *jsonapi.Document {
+		return &jsonapi.Document{Data: jsonapi.One{Value: &jsonapi.Resource{
+			ID: "1", Type: "things", Attributes: attrs,
+		}}}
+	}
+
+	a, err := server.ETag(newDoc(map[string]any{"a": 1, "b": 2, "c": 3}))
+	assert.NoError(t, err)
+	b, err := server.ETag(newDoc(map[string]any{"c": 3, "b": 2, "a": 1}))
+	assert.NoError(t, err)
+	c, err := server.ETag(newDoc(map[string]any{"a": 2}))
+	assert.NoError(t, err)
+
+	assert.Equal(t, a, b, "equal documents should produce equal tags")
+	assert.NotEqual(t, a, c, "different documents should produce different tags")
+	assert.Regexp(t, `^"[A-Za-z0-9_-]+"$`, a)
+}
+
+func TestWriteETag(t *testing.T) {
+	t.Run("computes tag from document", func(t *testing.T) {
+		type thing struct {
+			ID string `jsonapi:"primary,things"`
+		}
+		w := httptest.NewRecorder()
+		server.Write(w, thing{ID: "1"}, http.StatusOK, server.WriteETag())
+		assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+
+		doc := jsonapi.Document{}
+		err := jsonapi.Decode(w.Result().Body, &doc)
+		if assert.NoError(t, err) {
+			want, err := server.ETag(&doc)
+			assert.NoError(t, err)
+			assert.Equal(t, want, w.Header().Get(server.HeaderKeyETag))
+		}
+	})
+
+	t.Run("uses entity tagger", func(t *testing.T) {
+		for _, data := range []any{
+			versioned{ID: "1", Version: "3"},
+			&versioned{ID: "1", Version: "3"},
+		} {
+			w := httptest.NewRecorder()
+			server.Write(w, data, http.StatusOK, server.WriteETag())
+			assert.Equal(t, `"v3"`, w.Header().Get(server.HeaderKeyETag))
+		}
+	})
+
+	t.Run("omits tag by default", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		server.Write(w, versioned{ID: "1"}, http.StatusOK)
+		assert.Empty(t, w.Header().Get(server.HeaderKeyETag))
+	})
+}
+
+func TestWriteLastModified(t *testing.T) {
+	modified := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.FixedZone("EST", -5*60*60))
+	w := httptest.NewRecorder()
+	server.Write(w, versioned{ID: "1"}, http.StatusOK, server.WriteLastModified(modified))
+	assert.Equal(t, "Fri, 01 Mar 2024 17:00:00 GMT", w.Header().Get(server.HeaderKeyLastModified))
+}
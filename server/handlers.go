@@ -3,7 +3,9 @@ package server
 import (
 	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"strings"
 
 	"github.com/gonobo/jsonapi/v2"
 )
@@ -35,14 +37,64 @@ type Handler struct {
 // ServeHTTP handles incoming http requests, and injects the JSON:API request context
 // into the http request instance.
 func (h Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := h.log()
+	r = r.WithContext(ContextWithLogger(r.Context(), logger))
+
+	instrumentation := InstrumentationFromContext(r.Context())
+	if h.instrumentation != nil {
+		instrumentation = h.instrumentation
+		r = r.WithContext(ContextWithInstrumentation(r.Context(), instrumentation))
+	}
+
+	_, span := instrumentation.StartSpan(r.Context(), SpanResolveContext)
 	ctx, err := h.contextResolver.ResolveContext(r)
+	EndSpan(span, err)
 
 	if err != nil {
+		logger.ErrorContext(r.Context(), "jsonapi: failed to resolve context",
+			slog.String("method", r.Method), slog.String("url", r.URL.String()), slog.Any("error", err))
 		Error(w, fmt.Errorf("failed to resolve context: %w", err), http.StatusInternalServerError)
 		return
 	}
 
-	h.wrapped.ServeHTTP(w, jsonapi.RequestWithContext(r, ctx))
+	if h.urlResolver != nil {
+		r = r.WithContext(ContextWithURLResolver(r.Context(), h.urlResolver))
+	}
+
+	if h.limits != nil {
+		r = r.WithContext(ContextWithLimits(r.Context(), *h.limits))
+	}
+
+	if h.loader != nil {
+		// memoize loads for the duration of the request.
+		loader := MemoizeLoader(h.loader)
+		r = r.WithContext(ContextWithLoader(r.Context(), loader))
+	}
+
+	r = jsonapi.RequestWithContext(r, ctx)
+	if h.policy != nil {
+		r = r.WithContext(ContextWithPolicy(r.Context(), h.policy))
+		h.serveRedacted(instrumentation, w, r)
+		return
+	}
+
+	h.serve(instrumentation, w, r)
+}
+
+func (h Handler) serve(instrumentation Instrumentation, w http.ResponseWriter, r *http.Request) {
+	if h.instrumentation != nil {
+		serveInstrumented(instrumentation, w, r, h.wrapped)
+		return
+	}
+	h.wrapped.ServeHTTP(writerWithContext(w, r.Context()), r)
+}
+
+// serveRedacted redacts the response document with the handler's policy. Responses
+// that are not JSON:API documents are passed through unchanged.
+func (h Handler) serveRedacted(instrumentation Instrumentation, w http.ResponseWriter, r *http.Request) {
+	rw := &redactingWriter{ResponseWriter: w, ctx: r.Context()}
+	h.serve(instrumentation, rw, r)
+	rw.flush()
 }
 
 // Handle returns a [Handler], which wraps the provided http handler. The provided
@@ -64,6 +116,14 @@ func Handle(handler http.Handler, options ...Options) Handler {
 // the request will be determined by the request context's resource type. Undefined
 // resource requests will return 404 responses.
 //
+// Requests to nested routes (see [jsonapi.Routes]) are scoped by parent resources. If the mux
+// has a handler for the innermost parent's resource type, ResourceMux verifies that the parent
+// exists -- by serving a request to fetch it -- before invoking the child handler; if it does
+// not, a 404 is returned to the client. Parents further up the scope are verified in turn.
+//
+// If an authorization policy is registered with the handler (see [WithPolicy]), requests
+// are authorized before they are dispatched.
+//
 // ResourceMux must have be wrapped by or have a parent handler wrapped by [Handle]
 // to provide JSON:API request context.
 type ResourceMux map[string]http.Handler
@@ -79,9 +139,44 @@ func (m ResourceMux) Handle(resource string, handler http.Handler) {
 func (m ResourceMux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	ctx := jsonapi.FromContext(r.Context())
 	resource, ok := m[ctx.ResourceType]
+	if ok && !Authorize(w, r, ctx) {
+		return
+	}
+	if ok && !m.parentExists(r, ctx) {
+		notFound(w)
+		return
+	}
 	serveIfNotNil(w, r, resource, !ok)
 }
 
+// parentExists fetches the innermost parent of the request's scope, returning false if it
+// could not be found. Parents without a handler in the mux are not verified.
+func (m ResourceMux) parentExists(r *http.Request, current *jsonapi.RequestContext) bool {
+	if len(current.Scope) == 0 {
+		return true
+	}
+
+	last := len(current.Scope) - 1
+	parent := current.Scope[last]
+	if _, ok := m[parent.Type]; !ok {
+		return true
+	}
+
+	ctx := current.EmptyChild()
+	ctx.ResourceType = parent.Type
+	ctx.ResourceID = parent.ID
+	ctx.Scope = current.Scope[:last:last]
+
+	get := jsonapi.RequestWithContext(r, ctx)
+	get.Method = http.MethodGet
+	get.Body = http.NoBody
+	get.ContentLength = 0
+
+	mem := NewRecorder()
+	m.ServeHTTP(mem, get)
+	return mem.Status == http.StatusOK
+}
+
 // Resource is a collection of handlers for resource endpoints.
 // Each handler corresponds to a specific JSON:API resource operation,
 // such as Create, List, Get, Update, etc. The request type is determined
@@ -95,7 +190,13 @@ func (m ResourceMux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 // handler is nil, the request is rejected with a 404 Not Found response.
 //
 // If the request method does not conform to the JSON:API specification,
-// the request is rejected with a 405 Method Not Allowed response.
+// the request is rejected with a 405 Method Not Allowed response, whose "Allow"
+// header lists the methods of the non-nil handlers. OPTIONS requests are answered
+// with the same header, and HEAD requests are served by the GET handler without
+// the response body.
+//
+// The optional Loader retrieves resources in batches on behalf of the include
+// and related resource middleware; see [WithLoader].
 type Resource struct {
 	Relationships http.Handler // Relationships handles requests to resource relationships.
 	Create        http.Handler // Create handles requests to create new resources.
@@ -103,6 +204,7 @@ type Resource struct {
 	Get           http.Handler // Get handles requests to fetch a specific resource.
 	Update        http.Handler // Update handles requests to update a specific resource.
 	Delete        http.Handler // Delete handles requests to delete a specific resource.
+	Loader        Loader       // Loader loads resources of this type in batches.
 }
 
 // ServeHTTP routes incoming JSON:API requests to the appropriate resource operation.
@@ -129,30 +231,51 @@ func (h Resource) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 // serveCollection handles incoming JSON:API requests for collections of resources.
 func (h Resource) serveCollection(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case http.MethodGet:
-		serveIfNotNil(w, r, h.List, h.List == nil)
-		return
-	case http.MethodPost:
-		serveIfNotNil(w, r, h.Create, h.Create == nil)
-		return
-	}
-
-	// no matches, return 405
-	methodNotAllowed(w)
+	serveMethod(w, r, []methodHandler{
+		{http.MethodGet, h.List},
+		{http.MethodPost, h.Create},
+	})
 }
 
 // serveResource handles incoming JSON:API requests for individual resources.
 func (h Resource) serveResource(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case http.MethodGet:
-		serveIfNotNil(w, r, h.Get, h.Get == nil)
-		return
-	case http.MethodPatch:
-		serveIfNotNil(w, r, h.Update, h.Update == nil)
+	serveMethod(w, r, []methodHandler{
+		{http.MethodGet, h.Get},
+		{http.MethodPatch, h.Update},
+		{http.MethodDelete, h.Delete},
+	})
+}
+
+// methodHandler associates a request method with its handler.
+type methodHandler struct {
+	method  string
+	handler http.Handler
+}
+
+// serveMethod serves the request with the handler of its method. HEAD requests are served
+// by the GET handler, without the response body. OPTIONS requests, and requests with methods
+// that are not listed, are answered with the methods of the non-nil handlers in the "Allow"
+// header. Requests with listed methods whose handler is nil are rejected with a 404.
+func serveMethod(w http.ResponseWriter, r *http.Request, handlers []methodHandler) {
+	method := r.Method
+	if method == http.MethodHead {
+		method = http.MethodGet
+	}
+
+	for _, mh := range handlers {
+		if mh.method != method {
+			continue
+		}
+		if mh.handler != nil && r.Method == http.MethodHead {
+			w = headWriter{w}
+		}
+		serveIfNotNil(w, r, mh.handler, mh.handler == nil)
 		return
-	case http.MethodDelete:
-		serveIfNotNil(w, r, h.Delete, h.Delete == nil)
+	}
+
+	w.Header().Set("Allow", allowedMethods(handlers))
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
 		return
 	}
 
@@ -160,12 +283,45 @@ func (h Resource) serveResource(w http.ResponseWriter, r *http.Request) {
 	methodNotAllowed(w)
 }
 
+// allowedMethods returns the value of the "Allow" header, listing the methods of the
+// non-nil handlers.
+func allowedMethods(handlers []methodHandler) string {
+	allowed := make([]string, 0, len(handlers)+2)
+	for _, mh := range handlers {
+		if mh.handler == nil {
+			continue
+		}
+		allowed = append(allowed, mh.method)
+		if mh.method == http.MethodGet {
+			allowed = append(allowed, http.MethodHead)
+		}
+	}
+	allowed = append(allowed, http.MethodOptions)
+	return strings.Join(allowed, ", ")
+}
+
+// headWriter discards the response body of HEAD requests.
+type headWriter struct {
+	http.ResponseWriter
+}
+
+// Write discards p, reporting it as written.
+func (hw headWriter) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+// Unwrap returns the underlying response writer.
+func (hw headWriter) Unwrap() http.ResponseWriter {
+	return hw.ResponseWriter
+}
+
 func serveIfNotNil(w http.ResponseWriter, r *http.Request, h http.Handler, isNil bool) {
 	if isNil {
 		notFound(w)
 		return
 	}
-	h.ServeHTTP(w, r)
+	// middleware may have replaced the writer; associate the handler's writes with the request.
+	h.ServeHTTP(writerWithContext(w, r.Context()), r)
 }
 
 // notFound returns a 404 error.
@@ -180,7 +336,8 @@ func methodNotAllowed(w http.ResponseWriter) {
 // Relationship handlers route requests that correspond to a resource's relationships.
 // Supported requests include GetRef, UpdateRef, AddRef, and RemoveRef. If the request does
 // not match the JSON:API specifications for the above handlers, a 404 error is returned
-// to the client
+// to the client. Like [Resource], Relationship answers OPTIONS requests and rejected methods
+// with an "Allow" header, and serves HEAD requests with the Get handler.
 //
 // Relationship instances can be used alone or as a handler to a [Resource] instance's Ref field.
 type Relationship struct {
@@ -192,23 +349,12 @@ type Relationship struct {
 
 // ServeHTTP handles incoming JSON:API requests for resource relationships.
 func (h Relationship) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case http.MethodGet:
-		serveIfNotNil(w, r, h.Get, h.Get == nil)
-		return
-	case http.MethodPost:
-		serveIfNotNil(w, r, h.AddRef, h.AddRef == nil)
-		return
-	case http.MethodPatch:
-		serveIfNotNil(w, r, h.Update, h.Update == nil)
-		return
-	case http.MethodDelete:
-		serveIfNotNil(w, r, h.RemoveRef, h.RemoveRef == nil)
-		return
-	}
-
-	// no matches, return 405
-	methodNotAllowed(w)
+	serveMethod(w, r, []methodHandler{
+		{http.MethodGet, h.Get},
+		{http.MethodPost, h.AddRef},
+		{http.MethodPatch, h.Update},
+		{http.MethodDelete, h.RemoveRef},
+	})
 }
 
 // RelationshipMux is a http handler multiplexer for a resource's relationships.
@@ -2,6 +2,7 @@ package server_test
 
 import (
 	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -11,6 +12,7 @@ import (
 	"github.com/gonobo/jsonapi/v2/server"
 	"github.com/gonobo/jsonapi/v2/server/middleware"
 	"github.com/gonobo/jsonapi/v2/server/servertest"
+	"github.com/stretchr/testify/assert"
 )
 
 type fixture struct {
@@ -146,6 +148,57 @@ func TestResourceMux(t *testing.T) {
 	}...)
 }
 
+func TestResourceMuxScope(t *testing.T) {
+	routes := jsonapi.DefaultRoutes("")
+	routes.Nested = []string{
+		"/organizations/{id}/teams",
+		"/organizations/{id}/teams/{id}/members",
+	}
+
+	// existing resources, by type.
+	existing := map[string]map[string]bool{
+		"organizations": {"1": true},
+		"teams":         {"a": true},
+	}
+
+	get := func(w http.ResponseWriter, r *http.Request) {
+		ctx := jsonapi.FromContext(r.Context())
+		if !existing[ctx.ResourceType][ctx.ResourceID] {
+			server.Error(w, errors.New("not found"), http.StatusNotFound)
+			return
+		}
+		server.Write(w, jsonapi.NewSingleDocument(&jsonapi.Resource{Type: ctx.ResourceType, ID: ctx.ResourceID}), http.StatusOK)
+	}
+	list := func(w http.ResponseWriter, r *http.Request) {
+		server.Write(w, jsonapi.NewMultiDocument(), http.StatusOK)
+	}
+
+	handler := server.Handle(server.ResourceMux{
+		"organizations": server.Resource{Get: http.HandlerFunc(get)},
+		"teams":         server.Resource{Get: http.HandlerFunc(get), List: http.HandlerFunc(list)},
+		"members":       server.Resource{List: http.HandlerFunc(list)},
+	}, server.WithRouter(jsonapi.MustCompileRoutes(routes)))
+
+	for _, tc := range []struct {
+		target string
+		want   int
+	}{
+		{target: "/organizations/1/teams", want: http.StatusOK},
+		{target: "/organizations/2/teams", want: http.StatusNotFound},
+		{target: "/organizations/1/teams/a/members", want: http.StatusOK},
+		{target: "/organizations/1/teams/b/members", want: http.StatusNotFound},
+		{target: "/organizations/2/teams/a/members", want: http.StatusNotFound},
+		{target: "/organizations/1/teams/a", want: http.StatusOK},
+		{target: "/organizations/2/teams/a", want: http.StatusNotFound},
+	} {
+		t.Run(tc.target, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest("GET", tc.target, nil))
+			assert.Equal(t, tc.want, w.Result().StatusCode)
+		})
+	}
+}
+
 type node struct {
 	ID       string  `jsonapi:"primary,nodes"`
 	Value    string  `jsonapi:"attr,value"`
@@ -239,6 +292,12 @@ func servesResource(n nodeResource) fixtureopts {
 	return wrapsHandler(newNodeResourceHandler(n))
 }
 
+func hasAllow(allow string) func(*testing.T, *http.Response) {
+	return func(t *testing.T, res *http.Response) {
+		assert.Equal(t, allow, res.Header.Get("Allow"))
+	}
+}
+
 func TestResource(t *testing.T) {
 	type testcase = servertest.Case[fixture]
 
@@ -278,7 +337,7 @@ func TestResource(t *testing.T) {
 						},
 					},
 				},
-			}),
+			}.Reader()),
 			Options: []fixtureopts{
 				servesResource(nodeResource{}),
 				withOption(middleware.UseRequestBodyParser()),
@@ -343,7 +402,7 @@ func TestResource(t *testing.T) {
 						},
 					},
 				},
-			}),
+			}.Reader()),
 			Options: []fixtureopts{
 				servesResource(nodeResource{"42": {"42", "forty-two", nil}}),
 				withOption(middleware.UseRequestBodyParser()),
@@ -478,6 +537,7 @@ func TestResource(t *testing.T) {
 				wrapsHandler(server.Resource{}),
 			},
 			WantStatus: http.StatusMethodNotAllowed,
+			Assert:     []func(*testing.T, *http.Response){hasAllow("OPTIONS")},
 		},
 		{
 			Name: "returns 405 on invalid collection endpoint",
@@ -494,6 +554,67 @@ func TestResource(t *testing.T) {
 				wrapsHandler(server.Relationship{}),
 			},
 			WantStatus: http.StatusMethodNotAllowed,
+			Assert:     []func(*testing.T, *http.Response){hasAllow("OPTIONS")},
+		},
+		{
+			Name: "returns allowed methods on 405",
+			Req:  httptest.NewRequest("PUT", "/nodes/42", nil),
+			Options: []fixtureopts{
+				servesResource(nodeResource{}),
+			},
+			WantStatus: http.StatusMethodNotAllowed,
+			Assert:     []func(*testing.T, *http.Response){hasAllow("GET, HEAD, PATCH, DELETE, OPTIONS")},
+		},
+		{
+			Name: "answers collection options",
+			Req:  httptest.NewRequest("OPTIONS", "/nodes", nil),
+			Options: []fixtureopts{
+				servesResource(nodeResource{}),
+			},
+			WantStatus: http.StatusNoContent,
+			Assert:     []func(*testing.T, *http.Response){hasAllow("GET, HEAD, POST, OPTIONS")},
+		},
+		{
+			Name: "answers partial resource options",
+			Req:  httptest.NewRequest("OPTIONS", "/nodes/42", nil),
+			Options: []fixtureopts{
+				wrapsHandler(server.Resource{Delete: http.NotFoundHandler()}),
+			},
+			WantStatus: http.StatusNoContent,
+			Assert:     []func(*testing.T, *http.Response){hasAllow("DELETE, OPTIONS")},
+		},
+		{
+			Name: "answers ref options",
+			Req:  httptest.NewRequest("OPTIONS", "/nodes/42/relationships/children", nil),
+			Options: []fixtureopts{
+				servesResource(nodeResource{}),
+			},
+			WantStatus: http.StatusNoContent,
+			Assert:     []func(*testing.T, *http.Response){hasAllow("GET, HEAD, OPTIONS")},
+		},
+		{
+			Name: "serves head with the get handler",
+			Req:  httptest.NewRequest("HEAD", "/nodes/42", nil),
+			Options: []fixtureopts{
+				servesResource(nodeResource{"42": {"42", "forty-two", nil}}),
+			},
+			WantStatus: http.StatusOK,
+			Assert: []func(*testing.T, *http.Response){
+				func(t *testing.T, res *http.Response) {
+					assert.Equal(t, jsonapi.MediaType, res.Header.Get("Content-Type"))
+					body, err := io.ReadAll(res.Body)
+					assert.NoError(t, err)
+					assert.Empty(t, body)
+				},
+			},
+		},
+		{
+			Name: "returns 404 on unhandled head resource endpoint",
+			Req:  httptest.NewRequest("HEAD", "/nodes/42", nil),
+			Options: []fixtureopts{
+				wrapsHandler(server.Resource{}),
+			},
+			WantStatus: http.StatusNotFound,
 		},
 	}...)
 }
@@ -0,0 +1,225 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Names of the spans started while serving requests.
+const (
+	SpanResolveContext = "jsonapi.resolve_context" // Resolution of the JSON:API request context.
+	SpanHandle         = "jsonapi.handle"          // Execution of the middleware chain and handler.
+	SpanParseDocument  = "jsonapi.parse_document"  // Parsing of the request document.
+	SpanParseQuery     = "jsonapi.parse_query"     // Parsing of a family of query parameters.
+	SpanInclude        = "jsonapi.include"         // Retrieval of related or included resources.
+	SpanMarshal        = "jsonapi.marshal"         // Marshaling of the response document.
+)
+
+// Names of the metrics recorded while serving requests.
+const (
+	MetricRequests        = "jsonapi.requests"         // Counter of served requests.
+	MetricRequestDuration = "jsonapi.request.duration" // Histogram of request durations, in seconds.
+	MetricIncludeFetches  = "jsonapi.include.fetches"  // Counter of related or included resource retrievals.
+)
+
+// Instrumentation records traces and metrics of the stages of serving a request: context
+// resolution, request parsing, handler execution, include sub-requests and marshaling.
+// Attributes are expressed as [slog.Attr] values, so that implementations can bridge
+// them to any telemetry stack without additional dependencies.
+type Instrumentation interface {
+	// StartSpan starts a span, returning a context containing it. Spans started with the
+	// returned context are children of the span.
+	StartSpan(ctx context.Context, name string, attrs ...slog.Attr) (context.Context, Span)
+	// AddCounter adds the value to the named counter.
+	AddCounter(ctx context.Context, name string, value int64, attrs ...slog.Attr)
+	// RecordHistogram records the value in the named histogram.
+	RecordHistogram(ctx context.Context, name string, value float64, attrs ...slog.Attr)
+}
+
+// Span is a stage of serving a request, started with [Instrumentation.StartSpan].
+type Span interface {
+	// SetAttributes adds attributes to the span.
+	SetAttributes(attrs ...slog.Attr)
+	// RecordError records that the stage failed with the error.
+	RecordError(err error)
+	// End completes the span.
+	End()
+}
+
+// WithInstrumentation registers the instrumentation with the handler. On each request, the
+// instrumentation is stored in the request's context, where it can be retrieved downstream
+// via [InstrumentationFromContext].
+func WithInstrumentation(instrumentation Instrumentation) Options {
+	return func(c *Config) {
+		c.instrumentation = instrumentation
+	}
+}
+
+// WithWriteContext associates the write with the request's context: the response document is
+// marshaled within a span of the context's instrumentation, and failures are recorded with the
+// context's logger.
+//
+// Writes to response writers passed down by a [Handler] are associated with the request's
+// context automatically; WithWriteContext overrides that context, and associates writes
+// made outside of a Handler.
+func WithWriteContext(ctx context.Context) WriteOptions {
+	return func(c *Config) {
+		c.ctx = ctx
+	}
+}
+
+// contextWriter carries the context of the request it responds to, so that [Write] can
+// instrument the response without [WithWriteContext]. It forwards flushes and hijacks to
+// the response writer it wraps, so handlers can still stream responses.
+type contextWriter struct {
+	http.ResponseWriter
+	ctx context.Context
+}
+
+// Flush sends any buffered data to the client, if the underlying response writer supports it.
+func (cw contextWriter) Flush() {
+	swallowFlushResult(http.NewResponseController(cw.ResponseWriter).Flush())
+}
+
+// Hijack lets the caller take over the connection, if the underlying response writer supports it.
+func (cw contextWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(cw.ResponseWriter).Hijack()
+}
+
+// Unwrap returns the underlying response writer.
+func (cw contextWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// writerWithContext returns a response writer that carries the request's context.
+func writerWithContext(w http.ResponseWriter, ctx context.Context) http.ResponseWriter {
+	if cw, ok := w.(contextWriter); ok {
+		w = cw.ResponseWriter
+	}
+	return contextWriter{ResponseWriter: w, ctx: ctx}
+}
+
+// writerContext returns the request context carried by the response writer or the writers
+// it wraps, or nil if there is none.
+func writerContext(w http.ResponseWriter) context.Context {
+	for w != nil {
+		if cw, ok := w.(contextWriter); ok {
+			return cw.ctx
+		}
+		unwrapper, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return nil
+		}
+		w = unwrapper.Unwrap()
+	}
+	return nil
+}
+
+const instrumentationContextKey contextkey = "jsonapi_instrumentation"
+
+// ContextWithInstrumentation stores the instrumentation in the parent context.
+func ContextWithInstrumentation(parent context.Context, instrumentation Instrumentation) context.Context {
+	return context.WithValue(parent, instrumentationContextKey, instrumentation)
+}
+
+// InstrumentationFromContext returns the instrumentation stored in the context. If there is
+// none, an instrumentation that records nothing is returned.
+func InstrumentationFromContext(ctx context.Context) Instrumentation {
+	instrumentation, ok := ctx.Value(instrumentationContextKey).(Instrumentation)
+	if !ok || instrumentation == nil {
+		return noopInstrumentation{}
+	}
+	return instrumentation
+}
+
+// StartSpan starts a span with the instrumentation stored in the context.
+func StartSpan(ctx context.Context, name string, attrs ...slog.Attr) (context.Context, Span) {
+	return InstrumentationFromContext(ctx).StartSpan(ctx, name, attrs...)
+}
+
+// EndSpan records the error, if any, and ends the span.
+func EndSpan(span Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+	}
+	span.End()
+}
+
+// instrumentedWriter records the status of responses.
+type instrumentedWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader sends an HTTP response header with the provided status code.
+func (iw *instrumentedWriter) WriteHeader(status int) {
+	if iw.status == 0 {
+		iw.status = status
+	}
+	iw.ResponseWriter.WriteHeader(status)
+}
+
+// Write writes the data to the connection as part of an HTTP reply.
+func (iw *instrumentedWriter) Write(p []byte) (int, error) {
+	if iw.status == 0 {
+		iw.status = http.StatusOK
+	}
+	return iw.ResponseWriter.Write(p)
+}
+
+// Flush sends any buffered data to the client, if the underlying response writer supports it.
+func (iw *instrumentedWriter) Flush() {
+	swallowFlushResult(http.NewResponseController(iw.ResponseWriter).Flush())
+}
+
+// Hijack lets the caller take over the connection, if the underlying response writer supports it.
+func (iw *instrumentedWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(iw.ResponseWriter).Hijack()
+}
+
+// Unwrap returns the underlying response writer.
+func (iw *instrumentedWriter) Unwrap() http.ResponseWriter {
+	return iw.ResponseWriter
+}
+
+// serveInstrumented serves the request within a span, and records the request metrics.
+func serveInstrumented(instrumentation Instrumentation, w http.ResponseWriter, r *http.Request, next http.Handler) {
+	start := time.Now()
+	iw := &instrumentedWriter{ResponseWriter: w}
+
+	ctx, span := instrumentation.StartSpan(r.Context(), SpanHandle,
+		slog.String("method", r.Method), slog.String("url", r.URL.String()))
+	next.ServeHTTP(writerWithContext(iw, ctx), r.WithContext(ctx))
+
+	status := iw.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	span.SetAttributes(slog.Int("status", status))
+	span.End()
+
+	attrs := []slog.Attr{slog.String("method", r.Method), slog.String("status", strconv.Itoa(status))}
+	instrumentation.AddCounter(r.Context(), MetricRequests, 1, attrs...)
+	instrumentation.RecordHistogram(r.Context(), MetricRequestDuration, time.Since(start).Seconds(), attrs...)
+}
+
+type noopInstrumentation struct{}
+
+func (noopInstrumentation) StartSpan(ctx context.Context, name string, attrs ...slog.Attr) (context.Context, Span) {
+	return ctx, noopSpan{}
+}
+
+func (noopInstrumentation) AddCounter(context.Context, string, int64, ...slog.Attr) {}
+
+func (noopInstrumentation) RecordHistogram(context.Context, string, float64, ...slog.Attr) {}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttributes(...slog.Attr) {}
+func (noopSpan) RecordError(error)          {}
+func (noopSpan) End()                       {}
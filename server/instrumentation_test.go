@@ -0,0 +1,129 @@
+package server_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gonobo/jsonapi/v2"
+	"github.com/gonobo/jsonapi/v2/server"
+	"github.com/gonobo/jsonapi/v2/server/servertest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInstrumentation(t *testing.T) {
+	instrumentation := &servertest.Instrumentation{}
+
+	handler := server.Handle(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := jsonapi.FromContext(r.Context())
+		server.Write(w, &jsonapi.Resource{Type: ctx.ResourceType, ID: ctx.ResourceID}, http.StatusCreated)
+	}), server.WithInstrumentation(instrumentation))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", "https://example.com/articles/1", nil))
+	assert.Equal(t, http.StatusCreated, w.Result().StatusCode)
+
+	resolve := instrumentation.Spans(server.SpanResolveContext)
+	if assert.Len(t, resolve, 1) {
+		assert.True(t, resolve[0].Ended)
+		assert.Empty(t, resolve[0].Errors)
+	}
+
+	handle := instrumentation.Spans(server.SpanHandle)
+	if assert.Len(t, handle, 1) {
+		assert.True(t, handle[0].Ended)
+		assert.Equal(t, "POST", handle[0].Attrs["method"])
+		assert.Equal(t, int64(http.StatusCreated), handle[0].Attrs["status"])
+	}
+
+	marshal := instrumentation.Spans(server.SpanMarshal)
+	if assert.Len(t, marshal, 1) {
+		assert.Same(t, handle[0], marshal[0].Parent)
+		assert.True(t, marshal[0].Ended)
+		assert.NotZero(t, marshal[0].Attrs["bytes"])
+	}
+
+	requests := instrumentation.Measurements(server.MetricRequests)
+	if assert.Len(t, requests, 1) {
+		assert.Equal(t, float64(1), requests[0].Value)
+		assert.Equal(t, map[string]any{"method": "POST", "status": "201"}, requests[0].Attrs)
+	}
+	assert.Len(t, instrumentation.Measurements(server.MetricRequestDuration), 1)
+}
+
+func TestInstrumentationContextError(t *testing.T) {
+	instrumentation := &servertest.Instrumentation{}
+	resolver := jsonapi.ContextResolverFunc(func(r *http.Request) (*jsonapi.RequestContext, error) {
+		return nil, errors.New("unresolved")
+	})
+
+	handler := server.Handle(http.NotFoundHandler(),
+		server.WithContextResolver(resolver),
+		server.WithInstrumentation(instrumentation),
+	)
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "https://example.com/articles", nil))
+
+	resolve := instrumentation.Spans(server.SpanResolveContext)
+	if assert.Len(t, resolve, 1) {
+		assert.EqualError(t, resolve[0].Errors[0], "unresolved")
+	}
+	assert.Empty(t, instrumentation.Spans(server.SpanHandle))
+}
+
+func TestInstrumentationRecordedWrites(t *testing.T) {
+	instrumentation := &servertest.Instrumentation{}
+
+	// the middleware replaces the response writer, as response-rewriting middleware does.
+	record := server.WithMiddleware(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mem := server.NewRecorder()
+			next.ServeHTTP(mem, r)
+			mem.Flush(w)
+		})
+	})
+
+	mux := server.ResourceMux{"articles": server.Resource{
+		Get: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			server.Write(w, &jsonapi.Resource{Type: "articles", ID: "1"}, http.StatusOK)
+		}),
+	}}
+	handler := server.Handle(mux, record, server.WithInstrumentation(instrumentation))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "https://example.com/articles/1", nil))
+	assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+
+	handle := instrumentation.Spans(server.SpanHandle)
+	marshal := instrumentation.Spans(server.SpanMarshal)
+	if assert.Len(t, handle, 1) && assert.Len(t, marshal, 1) {
+		assert.Same(t, handle[0], marshal[0].Parent)
+		assert.True(t, marshal[0].Ended)
+	}
+}
+
+func TestInstrumentationFlush(t *testing.T) {
+	mux := server.ResourceMux{"articles": server.Resource{
+		List: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Write([]byte("data: 1\n\n"))
+			flusher, ok := w.(http.Flusher)
+			if assert.True(t, ok) {
+				flusher.Flush()
+			}
+		}),
+	}}
+
+	for name, options := range map[string][]server.Options{
+		"plain":           nil,
+		"instrumentation": {server.WithInstrumentation(&servertest.Instrumentation{})},
+		"policy":          {server.WithInstrumentation(&servertest.Instrumentation{}), server.WithPolicy(openPolicy{})},
+	} {
+		t.Run(name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			server.Handle(mux, options...).ServeHTTP(w, httptest.NewRequest("GET", "/articles", nil))
+			assert.True(t, w.Flushed)
+			assert.Equal(t, "data: 1\n\n", w.Body.String())
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package server
+
+import "context"
+
+// Limits bound the cost of serving a request. They are enforced by the request body and
+// query parameter parsers of the middleware package. Zero values disable the limit.
+type Limits struct {
+	MaxBodyBytes    int64 // The maximum size of request bodies, in bytes.
+	MaxResources    int   // The maximum number of resources -- primary data and included -- in request documents.
+	MaxIncludeDepth int   // The maximum number of relationships in an include path, e.g. 2 for "comments.author".
+	MaxIncludePaths int   // The maximum number of include paths.
+	MaxFilterBytes  int   // The maximum combined length of the filter query parameters, in bytes.
+	MaxFilterNodes  int   // The maximum number of filters and logical operators in filter expressions.
+	MaxPageSize     int   // The maximum page limit.
+	MaxSortKeys     int   // The maximum number of sort criteria.
+}
+
+// DefaultLimits returns limits suitable for most public APIs.
+func DefaultLimits() Limits {
+	return Limits{
+		MaxBodyBytes:    1 << 20,
+		MaxResources:    100,
+		MaxIncludeDepth: 3,
+		MaxIncludePaths: 10,
+		MaxFilterBytes:  4096,
+		MaxFilterNodes:  50,
+		MaxPageSize:     100,
+		MaxSortKeys:     5,
+	}
+}
+
+// WithLimits registers the request limits with the handler. On each request, the limits are
+// stored in the request's context, where they can be retrieved downstream via [LimitsFromContext].
+func WithLimits(limits Limits) Options {
+	return func(c *Config) {
+		c.limits = &limits
+	}
+}
+
+const limitsContextKey contextkey = "jsonapi_limits"
+
+// ContextWithLimits stores the limits in the parent context.
+func ContextWithLimits(parent context.Context, limits Limits) context.Context {
+	return context.WithValue(parent, limitsContextKey, limits)
+}
+
+// LimitsFromContext returns the limits stored in the context. If there are none, the zero
+// value -- which enforces no limits -- is returned.
+func LimitsFromContext(ctx context.Context) Limits {
+	limits, _ := ctx.Value(limitsContextKey).(Limits)
+	return limits
+}
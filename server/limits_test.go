@@ -0,0 +1,23 @@
+package server_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gonobo/jsonapi/v2/server"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimitsFromContext(t *testing.T) {
+	assert.Equal(t, server.Limits{}, server.LimitsFromContext(context.Background()))
+
+	var got server.Limits
+	handler := server.Handle(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = server.LimitsFromContext(r.Context())
+	}), server.WithLimits(server.DefaultLimits()))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/items", nil))
+	assert.Equal(t, server.DefaultLimits(), got)
+}
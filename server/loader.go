@@ -0,0 +1,144 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/gonobo/jsonapi/v2"
+)
+
+var (
+	// ErrNoLoader is returned when no loader is registered for a resource type.
+	ErrNoLoader = errors.New("no loader registered for resource type")
+)
+
+// Loader loads resources in batches. Loaders are used by the include and related
+// resource middleware to retrieve resources directly, without sending sub-requests
+// through the handler chain.
+type Loader interface {
+	// LoadResources returns the resources of the provided type with the provided ids.
+	// Resources that do not exist are omitted from the result. If the loader cannot
+	// serve the resource type, LoadResources should return an error wrapping [ErrNoLoader].
+	LoadResources(ctx context.Context, resourceType string, ids []string) ([]*jsonapi.Resource, error)
+}
+
+// LoaderFunc functions implement Loader.
+type LoaderFunc func(ctx context.Context, resourceType string, ids []string) ([]*jsonapi.Resource, error)
+
+// LoadResources returns the resources of the provided type with the provided ids.
+func (fn LoaderFunc) LoadResources(ctx context.Context, resourceType string, ids []string) ([]*jsonapi.Resource, error) {
+	return fn(ctx, resourceType, ids)
+}
+
+// WithLoader registers the loader with the handler. On each request, the loader is
+// memoized and stored in the request's context, where it can be retrieved downstream
+// via [LoaderFromContext]. A [ResourceMux] can be used to dispatch loads to the
+// loaders of its [Resource] handlers.
+func WithLoader(loader Loader) Options {
+	return func(c *Config) {
+		c.loader = loader
+	}
+}
+
+type contextkey string
+
+const loaderContextKey contextkey = "jsonapi_loader"
+
+// ContextWithLoader stores the loader in the parent context.
+func ContextWithLoader(parent context.Context, loader Loader) context.Context {
+	return context.WithValue(parent, loaderContextKey, loader)
+}
+
+// LoaderFromContext returns the loader stored in the context, if any.
+func LoaderFromContext(ctx context.Context) (Loader, bool) {
+	loader, ok := ctx.Value(loaderContextKey).(Loader)
+	return loader, ok && loader != nil
+}
+
+// LoadResources dispatches the load to the handler registered for the resource type.
+// The handler must implement [Loader], as [Resource] does.
+func (m ResourceMux) LoadResources(ctx context.Context, resourceType string, ids []string) ([]*jsonapi.Resource, error) {
+	handler, ok := m[resourceType]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNoLoader, resourceType)
+	}
+	loader, ok := handler.(Loader)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNoLoader, resourceType)
+	}
+	return loader.LoadResources(ctx, resourceType, ids)
+}
+
+// LoadResources loads resources with the resource's Loader. If the loader is nil,
+// an error wrapping [ErrNoLoader] is returned.
+func (h Resource) LoadResources(ctx context.Context, resourceType string, ids []string) ([]*jsonapi.Resource, error) {
+	if h.Loader == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNoLoader, resourceType)
+	}
+	return h.Loader.LoadResources(ctx, resourceType, ids)
+}
+
+// MemoizeLoader returns a loader that caches the results of the provided loader.
+// Subsequent loads only request the ids that have not been loaded before. The
+// returned loader is safe for concurrent use, and is intended to be scoped to a
+// single request.
+func MemoizeLoader(loader Loader) Loader {
+	return &memoLoader{
+		loader: loader,
+		cache:  make(map[memoKey]*jsonapi.Resource),
+	}
+}
+
+type memoKey struct {
+	resourceType string
+	id           string
+}
+
+type memoLoader struct {
+	mu     sync.Mutex
+	loader Loader
+	cache  map[memoKey]*jsonapi.Resource
+}
+
+// LoadResources returns cached resources, loading any that have not been loaded before.
+func (m *memoLoader) LoadResources(ctx context.Context, resourceType string, ids []string) ([]*jsonapi.Resource, error) {
+	m.mu.Lock()
+	missing := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if _, ok := m.cache[memoKey{resourceType, id}]; !ok {
+			missing = append(missing, id)
+		}
+	}
+	m.mu.Unlock()
+
+	if len(missing) > 0 {
+		items, err := m.loader.LoadResources(ctx, resourceType, missing)
+		if err != nil {
+			return nil, err
+		}
+
+		m.mu.Lock()
+		for _, id := range missing {
+			// remember missing resources, so that they are not loaded again.
+			m.cache[memoKey{resourceType, id}] = nil
+		}
+		for _, item := range items {
+			m.cache[memoKey{item.Type, item.ID}] = item
+		}
+		m.mu.Unlock()
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	items := make([]*jsonapi.Resource, 0, len(ids))
+	for _, id := range ids {
+		if item := m.cache[memoKey{resourceType, id}]; item != nil {
+			items = append(items, item)
+		}
+	}
+
+	return items, nil
+}
@@ -0,0 +1,87 @@
+package server_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gonobo/jsonapi/v2"
+	"github.com/gonobo/jsonapi/v2/server"
+	"github.com/stretchr/testify/assert"
+)
+
+type countingLoader struct {
+	calls [][]string
+}
+
+func (l *countingLoader) LoadResources(ctx context.Context, resourceType string, ids []string) ([]*jsonapi.Resource, error) {
+	l.calls = append(l.calls, ids)
+	items := make([]*jsonapi.Resource, 0, len(ids))
+	for _, id := range ids {
+		if id != "missing" {
+			items = append(items, &jsonapi.Resource{Type: resourceType, ID: id})
+		}
+	}
+	return items, nil
+}
+
+func TestMemoizeLoader(t *testing.T) {
+	counter := &countingLoader{}
+	loader := server.MemoizeLoader(counter)
+	ctx := context.Background()
+
+	items, err := loader.LoadResources(ctx, "things", []string{"1", "2", "missing"})
+	assert.NoError(t, err)
+	assert.Len(t, items, 2)
+
+	items, err = loader.LoadResources(ctx, "things", []string{"2", "3", "missing"})
+	assert.NoError(t, err)
+	assert.Len(t, items, 2)
+	assert.Equal(t, "2", items[0].ID)
+	assert.Equal(t, "3", items[1].ID)
+
+	assert.Equal(t, [][]string{{"1", "2", "missing"}, {"3"}}, counter.calls,
+		"only unseen ids should be loaded")
+}
+
+func TestResourceMuxLoader(t *testing.T) {
+	counter := &countingLoader{}
+	mux := server.ResourceMux{
+		"things":  server.Resource{Loader: counter},
+		"others":  server.Resource{},
+		"handler": http.NotFoundHandler(),
+	}
+
+	items, err := mux.LoadResources(context.Background(), "things", []string{"1"})
+	assert.NoError(t, err)
+	assert.Len(t, items, 1)
+
+	for _, resourceType := range []string{"others", "handler", "unknown"} {
+		_, err = mux.LoadResources(context.Background(), resourceType, []string{"1"})
+		assert.True(t, errors.Is(err, server.ErrNoLoader), "want ErrNoLoader for %s", resourceType)
+	}
+}
+
+func TestWithLoader(t *testing.T) {
+	counter := &countingLoader{}
+
+	handler := server.Handle(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		loader, ok := server.LoaderFromContext(r.Context())
+		if !assert.True(t, ok, "loader should be in request context") {
+			return
+		}
+		_, _ = loader.LoadResources(r.Context(), "things", []string{"1"})
+		_, _ = loader.LoadResources(r.Context(), "things", []string{"1"})
+		w.WriteHeader(http.StatusNoContent)
+	}), server.WithLoader(counter))
+
+	for range 2 {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "/things", nil))
+		assert.Equal(t, http.StatusNoContent, w.Result().StatusCode)
+	}
+
+	assert.Len(t, counter.calls, 2, "loads should be memoized per request")
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/gonobo/jsonapi/v2"
+	"github.com/gonobo/jsonapi/v2/internal/log"
+)
+
+const loggerContextKey contextkey = "jsonapi_logger"
+
+// WithLogger registers the logger with the handler. On each request, the logger is stored
+// in the request's context, where it can be retrieved downstream via [LoggerFromContext].
+// If no logger is registered, records are discarded unless the JSONAPI_DEBUG environment
+// variable is true.
+func WithLogger(logger *slog.Logger) Options {
+	return func(c *Config) {
+		c.logger = logger
+	}
+}
+
+// WithMarshalLogger sets the logger that records failures to marshal response documents.
+//
+// Deprecated: failures are recorded with the logger of the write's context; use
+// [WithWriteContext] with a context returned by [ContextWithLogger] instead.
+func WithMarshalLogger(logger *slog.Logger) WriteOptions {
+	return func(c *Config) {
+		ctx := c.ctx
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		c.ctx = ContextWithLogger(ctx, logger)
+	}
+}
+
+// ContextWithLogger stores the logger in the parent context.
+func ContextWithLogger(parent context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(parent, loggerContextKey, logger)
+}
+
+// LoggerFromContext returns the logger stored in the context, or the default logger if
+// there is none. If the context contains a JSON:API request context, the logger includes
+// its attributes, as returned by [RequestAttrs].
+func LoggerFromContext(ctx context.Context) *slog.Logger {
+	logger, ok := ctx.Value(loggerContextKey).(*slog.Logger)
+	if !ok || logger == nil {
+		logger = log.Default()
+	}
+	if jsonapiContext, ok := jsonapi.LookupContext(ctx); ok {
+		logger = logger.With(RequestAttrs(jsonapiContext))
+	}
+	return logger
+}
+
+// RequestAttrs returns the attributes of the JSON:API request context -- its resource type,
+// id, relationship and include paths -- grouped under the "jsonapi" key. Empty attributes
+// are omitted.
+func RequestAttrs(ctx *jsonapi.RequestContext) slog.Attr {
+	attrs := make([]any, 0, 5)
+	if ctx.ResourceType != "" {
+		attrs = append(attrs, slog.String("type", ctx.ResourceType))
+	}
+	if ctx.ResourceID != "" {
+		attrs = append(attrs, slog.String("id", ctx.ResourceID))
+	}
+	if ctx.Relationship != "" {
+		attrs = append(attrs, slog.String("relationship", ctx.Relationship))
+		attrs = append(attrs, slog.Bool("related", ctx.Related))
+	}
+	include := make([]string, 0, len(ctx.Include))
+	for _, path := range ctx.Include {
+		if path != "" {
+			include = append(include, path)
+		}
+	}
+	if len(include) > 0 {
+		attrs = append(attrs, slog.Any("include", include))
+	}
+	return slog.Group("jsonapi", attrs...)
+}
+
+func (c Config) log() *slog.Logger {
+	if c.logger != nil {
+		return c.logger
+	} else if c.ctx != nil {
+		return LoggerFromContext(c.ctx)
+	}
+	return log.Default()
+}
@@ -0,0 +1,81 @@
+package server_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gonobo/jsonapi/v2"
+	"github.com/gonobo/jsonapi/v2/server"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestAttrs(t *testing.T) {
+	attr := server.RequestAttrs(&jsonapi.RequestContext{
+		ResourceType: "articles",
+		ResourceID:   "1",
+		Relationship: "author",
+		Include:      []string{"", "comments"},
+	})
+
+	assert.Equal(t, "jsonapi", attr.Key)
+	assert.Equal(t, "[type=articles id=1 relationship=author related=false include=[comments]]", attr.Value.String())
+}
+
+func TestLoggerFromContext(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	t.Run("without logger", func(t *testing.T) {
+		assert.NotNil(t, server.LoggerFromContext(context.Background()))
+	})
+
+	t.Run("with request context", func(t *testing.T) {
+		buf.Reset()
+		ctx := server.ContextWithLogger(context.Background(), logger)
+		ctx = jsonapi.WithContext(ctx, &jsonapi.RequestContext{ResourceType: "articles"})
+		server.LoggerFromContext(ctx).Info("hello")
+
+		entry := map[string]any{}
+		assert.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+		assert.Equal(t, "hello", entry["msg"])
+		assert.Equal(t, map[string]any{"type": "articles"}, entry["jsonapi"])
+	})
+
+	t.Run("from handler", func(t *testing.T) {
+		buf.Reset()
+		handler := server.Handle(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			server.LoggerFromContext(r.Context()).Info("hello")
+		}), server.WithLogger(logger))
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "https://example.com/articles/1", nil))
+
+		entry := map[string]any{}
+		assert.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+		assert.Equal(t, map[string]any{"type": "articles", "id": "1"}, entry["jsonapi"])
+	})
+}
+
+func TestWithMarshalLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	w := httptest.NewRecorder()
+	server.Write(w, &jsonapi.Resource{Type: "articles", ID: "1"}, http.StatusOK,
+		server.WithMarshalLogger(logger),
+		server.WithDocumentOptions(func(w http.ResponseWriter, d *jsonapi.Document) error {
+			return errors.New("broken option")
+		}),
+	)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Result().StatusCode)
+
+	entry := map[string]any{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, "ERROR", entry["level"])
+	assert.Equal(t, "broken option", entry["error"])
+}
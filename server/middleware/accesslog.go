@@ -0,0 +1,124 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gonobo/jsonapi/v2"
+	"github.com/gonobo/jsonapi/v2/server"
+)
+
+// UseAccessLog is a middleware that records a log entry for each request, once it has
+// been served. Entries contain the request method and URL, the response status, the
+// duration of the request, the attributes of the JSON:API request context (see
+// [server.RequestAttrs]), and -- for error responses -- the codes of the JSON:API errors
+// in the response document.
+//
+// Entries are recorded at the info level for successful responses, at the warn level for
+// client errors, and at the error level for server errors. If logger is nil, the handler's
+// logger is used (see [server.WithLogger]).
+func UseAccessLog(logger *slog.Logger) server.Options {
+	return server.WithMiddleware(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			aw := &accessLogWriter{ResponseWriter: w}
+
+			next.ServeHTTP(aw, r)
+
+			log := logger
+			if log == nil {
+				log = server.LoggerFromContext(r.Context())
+			} else if ctx, ok := jsonapi.LookupContext(r.Context()); ok {
+				log = log.With(server.RequestAttrs(ctx))
+			}
+
+			status := aw.statusCode()
+			attrs := []slog.Attr{
+				slog.String("method", r.Method),
+				slog.String("url", r.URL.String()),
+				slog.Int("status", status),
+				slog.Duration("duration", time.Since(start)),
+			}
+			if codes := aw.errorCodes(); len(codes) > 0 {
+				attrs = append(attrs, slog.Any("errors", codes))
+			}
+
+			level := slog.LevelInfo
+			switch {
+			case status >= http.StatusInternalServerError:
+				level = slog.LevelError
+			case status >= http.StatusBadRequest:
+				level = slog.LevelWarn
+			}
+
+			log.LogAttrs(r.Context(), level, "jsonapi: request served", attrs...)
+		})
+	})
+}
+
+// accessLogWriter records the response status, and the body of error responses.
+type accessLogWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+// WriteHeader sends an HTTP response header with the provided status code.
+func (aw *accessLogWriter) WriteHeader(status int) {
+	if aw.status == 0 {
+		aw.status = status
+	}
+	aw.ResponseWriter.WriteHeader(status)
+}
+
+// Write writes the data to the connection as part of an HTTP reply.
+func (aw *accessLogWriter) Write(p []byte) (int, error) {
+	if aw.status == 0 {
+		aw.status = http.StatusOK
+	}
+	if aw.status >= http.StatusBadRequest {
+		aw.body.Write(p)
+	}
+	return aw.ResponseWriter.Write(p)
+}
+
+// Unwrap returns the underlying response writer.
+func (aw *accessLogWriter) Unwrap() http.ResponseWriter {
+	return aw.ResponseWriter
+}
+
+func (aw *accessLogWriter) statusCode() int {
+	if aw.status == 0 {
+		return http.StatusOK
+	}
+	return aw.status
+}
+
+// errorCodes returns the codes of the JSON:API errors in the response body -- or their
+// status, if they have no code.
+func (aw *accessLogWriter) errorCodes() []string {
+	if aw.body.Len() == 0 {
+		return nil
+	}
+
+	doc := jsonapi.Document{}
+	if err := json.Unmarshal(aw.body.Bytes(), &doc); err != nil {
+		return nil
+	}
+
+	codes := make([]string, 0, len(doc.Errors))
+	for _, e := range doc.Errors {
+		if e == nil {
+			continue
+		}
+		if e.Code != "" {
+			codes = append(codes, e.Code)
+		} else {
+			codes = append(codes, e.Status)
+		}
+	}
+	return codes
+}
@@ -0,0 +1,137 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gonobo/jsonapi/v2"
+	"github.com/gonobo/jsonapi/v2/query"
+	"github.com/gonobo/jsonapi/v2/server"
+)
+
+// MemberNamesConfig configures how clients negotiate the naming convention of member names.
+type MemberNamesConfig struct {
+	profiles map[string]jsonapi.MemberCase
+	header   string
+}
+
+// WithCasingProfile registers a profile that clients apply -- via the "profile" media type
+// parameter of the "Content-Type" or "Accept" header -- to request the naming convention.
+func WithCasingProfile(uri string, wire jsonapi.MemberCase) func(*MemberNamesConfig) {
+	return func(c *MemberNamesConfig) {
+		c.profiles[uri] = wire
+	}
+}
+
+// WithCasingHeader allows clients to request a naming convention by name -- "snake",
+// "camel", "kebab" or "pascal" -- with the provided request header.
+func WithCasingHeader(header string) func(*MemberNamesConfig) {
+	return func(c *MemberNamesConfig) {
+		c.header = header
+	}
+}
+
+// UseMemberNames is a middleware that converts member names between the canonical naming
+// convention of the server's models and the convention used by clients.
+//
+// Attribute and relationship names in the request document, the requested relationship,
+// include paths, and the field names of fieldset, sort and filter criteria are converted
+// into their canonical form before the request is served; the member names of the response
+// document are converted into their wire form. The middleware must therefore be applied
+// after [UseRequestBodyParser] and the query parsers, and before any resolvers.
+//
+// The wire convention defaults to names.Wire, and can be negotiated per request with
+// [WithCasingProfile] or [WithCasingHeader]. If a profile is negotiated, it is listed in the
+// response document's "jsonapi" member and "Content-Type" header.
+func UseMemberNames(names jsonapi.MemberNames, options ...func(*MemberNamesConfig)) server.Options {
+	cfg := MemberNamesConfig{profiles: make(map[string]jsonapi.MemberCase)}
+	for _, option := range options {
+		option(&cfg)
+	}
+
+	return server.WithMiddleware(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			names, profile := cfg.negotiate(r, names)
+
+			ctx := jsonapi.FromContext(r.Context()).Child()
+			decodeRequestContext(ctx, names)
+
+			mem := server.NewRecorder()
+			next.ServeHTTP(mem, jsonapi.RequestWithContext(r, ctx))
+
+			if mem.Document != nil {
+				names.EncodeDocument(mem.Document)
+				if profile != "" {
+					mem.Document.Jsonapi.Profile = append(mem.Document.Jsonapi.Profile, profile)
+					addMediaTypeParam(mem.Header(), "profile", profile)
+				}
+			}
+
+			mem.Flush(w)
+		})
+	})
+}
+
+// negotiate returns the member names requested by the client, and the applied profile, if any.
+func (c MemberNamesConfig) negotiate(r *http.Request, names jsonapi.MemberNames) (jsonapi.MemberNames, string) {
+	if c.header != "" {
+		if wire := jsonapi.MemberCase(r.Header.Get(c.header)); isMemberCase(wire) {
+			names.Wire = wire
+			return names, ""
+		}
+	}
+
+	for _, header := range []string{"Content-Type", "Accept"} {
+		for _, uris := range mediaTypeParams(r.Header.Get(header), "profile") {
+			for _, uri := range uris {
+				if wire, ok := c.profiles[uri]; ok {
+					names.Wire = wire
+					return names, uri
+				}
+			}
+		}
+	}
+
+	return names, ""
+}
+
+func isMemberCase(c jsonapi.MemberCase) bool {
+	switch c {
+	case jsonapi.SnakeCase, jsonapi.CamelCase, jsonapi.KebabCase, jsonapi.PascalCase:
+		return true
+	}
+	return false
+}
+
+// decodeRequestContext converts the member names of the request context into their canonical form.
+func decodeRequestContext(ctx *jsonapi.RequestContext, names jsonapi.MemberNames) {
+	if ctx.Document != nil {
+		// the document is shared with the parent context; decode a copy.
+		doc := *ctx.Document
+		names.DecodeDocument(&doc)
+		ctx.Document = &doc
+	}
+
+	ctx.Relationship = names.Decode(ctx.Relationship)
+
+	include := make([]string, len(ctx.Include))
+	for i, path := range ctx.Include {
+		include[i] = names.DecodePath(path)
+	}
+	ctx.Include = include
+
+	fields := make([]query.Fieldset, len(ctx.Fields))
+	for i, field := range ctx.Fields {
+		fields[i] = query.Fieldset{Property: names.Decode(field.Property)}
+	}
+	ctx.Fields = fields
+
+	sort := make([]query.Sort, len(ctx.Sort))
+	for i, criterion := range ctx.Sort {
+		sort[i] = query.Sort{Property: names.Decode(criterion.Property), Descending: criterion.Descending}
+	}
+	ctx.Sort = sort
+
+	if ctx.Filter != nil {
+		ctx.Filter = query.RenameFilterFields(ctx.Filter, names.Decode)
+	}
+}
@@ -0,0 +1,229 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gonobo/jsonapi/v2"
+	"github.com/gonobo/jsonapi/v2/server"
+)
+
+const (
+	headerIfMatch           = "If-Match"
+	headerIfNoneMatch       = "If-None-Match"
+	headerIfModifiedSince   = "If-Modified-Since"
+	headerIfUnmodifiedSince = "If-Unmodified-Since"
+)
+
+// UseConditionalRequests is a middleware that evaluates http request preconditions
+// against entity tags and modification dates, as described in RFC 9110.
+//
+// For GET and HEAD requests, the downstream response is assigned an "ETag" header --
+// either the one written by the handler (see [server.WriteETag]) or one computed over
+// the canonical serialization of the response document. If the client's "If-None-Match"
+// (or "If-Modified-Since") header matches, a 304 Not Modified response is returned.
+//
+// For PATCH and DELETE requests that carry an "If-Match" (or "If-Unmodified-Since")
+// header, the current representation of the target is retrieved with a downstream GET
+// request before the request is served. If the precondition fails, a 412 Precondition
+// Failed error is returned and the request is not served, providing optimistic
+// concurrency control.
+//
+// If an authorization policy is registered with the handler (see [server.WithPolicy]), the
+// response document is redacted before its entity tag is computed, so that the tag only
+// reflects the content the principal may read.
+func UseConditionalRequests() server.Options {
+	return server.WithMiddleware(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodGet, http.MethodHead:
+				serveConditionalFetch(next, w, r)
+			case http.MethodPatch, http.MethodDelete:
+				serveConditionalUpdate(next, w, r)
+			default:
+				next.ServeHTTP(w, r)
+			}
+		})
+	})
+}
+
+// serveConditionalFetch tags the downstream response and evaluates the
+// "If-None-Match" and "If-Modified-Since" preconditions.
+//
+// HEAD requests are served downstream as GET requests, so that their validators are computed
+// over the same document; the document is dropped once the validators are known.
+func serveConditionalFetch(next http.Handler, w http.ResponseWriter, r *http.Request) {
+	get := r
+	if r.Method == http.MethodHead {
+		get = r.Clone(r.Context())
+		get.Method = http.MethodGet
+	}
+
+	mem := server.NewRecorder()
+	next.ServeHTTP(mem, get)
+	server.RedactResponse(r.Context(), mem)
+
+	tag, ok := recordedETag(mem)
+	if r.Method == http.MethodHead {
+		mem.Document = nil
+	}
+
+	if !ok {
+		mem.Flush(w)
+		return
+	}
+
+	if inm := r.Header.Get(headerIfNoneMatch); inm != "" {
+		if matchETag(inm, tag, true, false) {
+			notModified(w, mem)
+			return
+		}
+	} else if modifiedBefore(mem.Header(), r.Header.Get(headerIfModifiedSince)) {
+		notModified(w, mem)
+		return
+	}
+
+	mem.Flush(w)
+}
+
+// serveConditionalUpdate evaluates the "If-Match" and "If-Unmodified-Since" preconditions
+// against the current representation of the target before serving the request.
+func serveConditionalUpdate(next http.Handler, w http.ResponseWriter, r *http.Request) {
+	ifMatch := r.Header.Get(headerIfMatch)
+	ifUnmodifiedSince := r.Header.Get(headerIfUnmodifiedSince)
+
+	if ifMatch == "" && ifUnmodifiedSince == "" {
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	// retrieve the current representation of the target.
+
+	current := jsonapi.FromContext(r.Context())
+	ctx := current.EmptyChild()
+	ctx.ResourceType = current.ResourceType
+	ctx.ResourceID = current.ResourceID
+	ctx.Relationship = current.Relationship
+
+	get := jsonapi.RequestWithContext(r, ctx)
+	get.Method = http.MethodGet
+	get.Body = http.NoBody
+	get.ContentLength = 0
+
+	mem := server.NewRecorder()
+	next.ServeHTTP(mem, get)
+	server.RedactResponse(get.Context(), mem)
+
+	if mem.Status != http.StatusOK && mem.Status != http.StatusNotFound {
+		// the current representation could not be retrieved.
+		mem.Flush(w)
+		return
+	}
+
+	tag, exists := recordedETag(mem)
+
+	if ifMatch != "" {
+		if !matchETag(ifMatch, tag, exists, true) {
+			preconditionFailed(w, headerIfMatch)
+			return
+		}
+	} else if exists && !modifiedBefore(mem.Header(), ifUnmodifiedSince) {
+		preconditionFailed(w, headerIfUnmodifiedSince)
+		return
+	}
+
+	next.ServeHTTP(w, r)
+}
+
+// recordedETag returns the entity tag of a successful recorded response. If the
+// handler did not supply a tag, it is computed from the recorded document and
+// added to the recorded headers.
+func recordedETag(mem *server.ResponseRecorder) (string, bool) {
+	if mem.Status != http.StatusOK || mem.Document == nil {
+		return "", false
+	}
+
+	if tag := mem.Header().Get(server.HeaderKeyETag); tag != "" {
+		return tag, true
+	}
+
+	tag, err := server.ETag(mem.Document)
+	if err != nil {
+		return "", false
+	}
+
+	mem.Header().Set(server.HeaderKeyETag, tag)
+	return tag, true
+}
+
+// matchETag reports whether the tag matches the list of entity tags in the header value.
+// The wildcard "*" matches if the representation exists. Strong comparison requires
+// that neither tag is weak.
+func matchETag(header string, tag string, exists bool, strong bool) bool {
+	if !exists {
+		return false
+	}
+
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if strong && (isWeakETag(candidate) || isWeakETag(tag)) {
+			continue
+		}
+		if strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(tag, "W/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isWeakETag(tag string) bool {
+	return strings.HasPrefix(tag, "W/")
+}
+
+// modifiedBefore reports whether the representation's "Last-Modified" header is not
+// after the provided http date. If either date is missing or invalid, it returns false.
+func modifiedBefore(header http.Header, date string) bool {
+	if date == "" {
+		return false
+	}
+
+	since, err := http.ParseTime(date)
+	if err != nil {
+		return false
+	}
+
+	modified, err := http.ParseTime(header.Get(server.HeaderKeyLastModified))
+	if err != nil {
+		return false
+	}
+
+	return !modified.Truncate(time.Second).After(since)
+}
+
+// notModified writes a 304 response, keeping the recorded validator headers.
+func notModified(w http.ResponseWriter, mem *server.ResponseRecorder) {
+	for k, v := range mem.Header() {
+		if k == "Content-Type" || k == "Content-Length" {
+			continue
+		}
+		w.Header()[k] = v
+	}
+	w.WriteHeader(http.StatusNotModified)
+}
+
+// preconditionFailed writes a 412 error naming the failed header.
+func preconditionFailed(w http.ResponseWriter, header string) {
+	server.Error(w, jsonapi.Error{
+		Status: strconv.Itoa(http.StatusPreconditionFailed),
+		Title:  http.StatusText(http.StatusPreconditionFailed),
+		Detail: fmt.Sprintf("precondition in %s header failed", header),
+		Source: &jsonapi.ErrorSource{Header: header},
+	}, http.StatusPreconditionFailed)
+}
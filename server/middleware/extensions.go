@@ -0,0 +1,159 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gonobo/jsonapi/v2"
+	"github.com/gonobo/jsonapi/v2/server"
+)
+
+type contextkey string
+
+const (
+	extensionsContextKey contextkey = "jsonapi_extensions"
+	profilesContextKey   contextkey = "jsonapi_profiles"
+)
+
+// ExtensionValuesFromContext returns the typed top-level extension members of the request
+// document, as decoded by [UseExtensions].
+func ExtensionValuesFromContext(ctx context.Context) (jsonapi.ExtensionValues, bool) {
+	values, ok := ctx.Value(extensionsContextKey).(jsonapi.ExtensionValues)
+	return values, ok
+}
+
+// UseExtensions is a middleware that negotiates the extensions in the registry with clients.
+// It must be applied after [UseRequestBodyParser], which stores the request document in
+// the context.
+//
+// Requests that apply an unsupported extension in the "Content-Type" header are rejected
+// with 415 Unsupported Media Type; requests whose "Accept" header only contains JSON:API
+// media types with unsupported extensions are rejected with 406 Not Acceptable. Request
+// document members whose namespace does not belong to an applied extension, or that are not
+// declared by their extension, are rejected with 400 Bad Request. The typed values of the
+// document's top-level extension members can be retrieved downstream with
+// [ExtensionValuesFromContext].
+//
+// The URIs of the extensions used by the response document are added to its "jsonapi"
+// member and to the "ext" parameter of the "Content-Type" header.
+func UseExtensions(registry *jsonapi.ExtensionRegistry) server.Options {
+	return server.WithMiddleware(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			applied := mediaTypeParams(r.Header.Get("Content-Type"), "ext")
+			if len(applied) > 0 {
+				for _, uri := range applied[0] {
+					if _, ok := registry.Lookup(uri); !ok {
+						unsupportedExtension(w, http.StatusUnsupportedMediaType, "Content-Type", uri)
+						return
+					}
+				}
+			}
+
+			if uri, ok := unacceptableExtension(registry, r.Header.Get("Accept")); ok {
+				unsupportedExtension(w, http.StatusNotAcceptable, "Accept", uri)
+				return
+			}
+
+			ctx := jsonapi.FromContext(r.Context())
+			if ctx.Document != nil {
+				var uris []string
+				if len(applied) > 0 {
+					uris = applied[0]
+				}
+				if errs := registry.Check(ctx.Document, uris); len(errs) > 0 {
+					writeValidationErrors(w, errs)
+					return
+				}
+				values, err := registry.DecodeDocument(ctx.Document)
+				if err != nil {
+					server.Error(w, err, http.StatusBadRequest)
+					return
+				}
+				r = r.WithContext(context.WithValue(r.Context(), extensionsContextKey, values))
+			}
+
+			mem := server.NewRecorder()
+			next.ServeHTTP(mem, r)
+
+			if mem.Document != nil {
+				if uris := registry.ApplyExtensions(mem.Document); len(uris) > 0 {
+					addMediaTypeParam(mem.Header(), "ext", uris...)
+				}
+			}
+
+			mem.Flush(w)
+		})
+	})
+}
+
+// unacceptableExtension reports whether every JSON:API media type in the "Accept" header
+// applies an unsupported extension, and returns one of them.
+func unacceptableExtension(registry *jsonapi.ExtensionRegistry, accept string) (string, bool) {
+	instances := mediaTypeParams(accept, "ext")
+	if len(instances) == 0 {
+		return "", false
+	}
+
+	unsupported := ""
+	for _, uris := range instances {
+		supported := true
+		for _, uri := range uris {
+			if _, ok := registry.Lookup(uri); !ok {
+				supported = false
+				unsupported = uri
+			}
+		}
+		if supported {
+			return "", false
+		}
+	}
+
+	return unsupported, true
+}
+
+// mediaTypeParams returns the space-separated values of the parameter for each JSON:API
+// media type in the header value.
+func mediaTypeParams(value string, param string) [][]string {
+	instances := make([][]string, 0)
+	for _, item := range strings.Split(value, ",") {
+		mediatype, params, err := mime.ParseMediaType(item)
+		if err != nil || mediatype != jsonapi.MediaType {
+			continue
+		}
+		instances = append(instances, strings.Fields(params[param]))
+	}
+	return instances
+}
+
+// addMediaTypeParam adds the values to the space-separated parameter of the JSON:API media
+// type in the "Content-Type" header, keeping any other parameters and values.
+func addMediaTypeParam(header http.Header, param string, values ...string) {
+	params := map[string]string{}
+	if _, current, err := mime.ParseMediaType(header.Get("Content-Type")); err == nil {
+		params = current
+	}
+
+	merged := strings.Fields(params[param])
+	for _, value := range values {
+		if !slices.Contains(merged, value) {
+			merged = append(merged, value)
+		}
+	}
+
+	params[param] = strings.Join(merged, " ")
+	header.Set("Content-Type", mime.FormatMediaType(jsonapi.MediaType, params))
+}
+
+func unsupportedExtension(w http.ResponseWriter, status int, header string, uri string) {
+	server.Error(w, jsonapi.Error{
+		Status: strconv.Itoa(status),
+		Title:  http.StatusText(status),
+		Detail: fmt.Sprintf("unsupported extension %s", uri),
+		Source: &jsonapi.ErrorSource{Header: header},
+	}, status)
+}
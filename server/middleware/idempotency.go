@@ -0,0 +1,329 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gonobo/jsonapi/v2"
+	"github.com/gonobo/jsonapi/v2/server"
+)
+
+const (
+	// HeaderIdempotencyKey is the request header that carries the idempotency key.
+	HeaderIdempotencyKey = "Idempotency-Key"
+	// HeaderIdempotentReplayed is the response header set on replayed responses.
+	HeaderIdempotentReplayed = "Idempotent-Replayed"
+
+	// DefaultIdempotencyTTL is the default duration for which responses are recorded.
+	DefaultIdempotencyTTL = 24 * time.Hour
+	// DefaultIdempotencyLockTTL is the default duration for which a key is reserved while
+	// its request is being served.
+	DefaultIdempotencyLockTTL = time.Minute
+
+	// idempotencySweepInterval is the minimum interval between sweeps of expired records
+	// of the in-memory store.
+	idempotencySweepInterval = time.Minute
+)
+
+// IdempotencyRecord is the state of an idempotency key: the fingerprint of the request
+// that first used it and, once that request has been served, its response.
+type IdempotencyRecord struct {
+	Fingerprint string            // The fingerprint of the request's method, path and body.
+	Completed   bool              // If true, the request has been served and its response recorded.
+	Status      int               // The response status code.
+	Header      http.Header       // The response headers.
+	Document    *jsonapi.Document // The response document, if any.
+}
+
+// IdempotencyStore stores the records of idempotency keys. Implementations must be safe
+// for concurrent use.
+type IdempotencyStore interface {
+	// Reserve atomically reserves the unused key for the request with the fingerprint, for
+	// the duration of the ttl; the reservation lapses if the request is neither completed
+	// nor released in time, e.g. because the server stopped. If the key is in use, Reserve
+	// returns its record and false.
+	Reserve(ctx context.Context, key string, fingerprint string, ttl time.Duration) (IdempotencyRecord, bool, error)
+	// Complete records the response of the request that reserved the key.
+	Complete(ctx context.Context, key string, record IdempotencyRecord, ttl time.Duration) error
+	// Release removes the key, so that the request can be retried.
+	Release(ctx context.Context, key string) error
+}
+
+// IdempotencyConfig configures the idempotency key middleware.
+type IdempotencyConfig struct {
+	ttl     time.Duration
+	lockTTL time.Duration
+	scope   func(*http.Request) string
+}
+
+// WithIdempotencyTTL sets the duration for which the responses of idempotent requests are
+// recorded. Defaults to [DefaultIdempotencyTTL].
+func WithIdempotencyTTL(ttl time.Duration) func(*IdempotencyConfig) {
+	return func(c *IdempotencyConfig) {
+		c.ttl = ttl
+	}
+}
+
+// WithIdempotencyLockTTL sets the duration for which a key is reserved while its request is
+// being served. Retries are rejected with a 409 Conflict error until the request is served
+// or the reservation lapses; the duration should exceed the time needed to serve requests.
+// Defaults to [DefaultIdempotencyLockTTL].
+func WithIdempotencyLockTTL(ttl time.Duration) func(*IdempotencyConfig) {
+	return func(c *IdempotencyConfig) {
+		c.lockTTL = ttl
+	}
+}
+
+// WithIdempotencyScope scopes idempotency keys to the principal making the request, as
+// identified by the provided function -- typically from a value stored in the request's
+// context by an authentication middleware. Keys of different principals never collide.
+func WithIdempotencyScope(scope func(*http.Request) string) func(*IdempotencyConfig) {
+	return func(c *IdempotencyConfig) {
+		c.scope = scope
+	}
+}
+
+// UseIdempotencyKeys is a middleware that makes requests to create resources, and to add
+// members to relationships, idempotent. Clients send a unique key with each request in the
+// "Idempotency-Key" header; once the request has been served, its response -- status,
+// headers and document -- is recorded in the store, and replayed for retries of the request
+// with the same key. Replayed responses carry the "Idempotent-Replayed" header.
+//
+// Keys are bound to the request's method, path and body: reusing a key for a different
+// request is rejected with a 422 Unprocessable Entity error, and retrying a request that is
+// still being served is rejected with a 409 Conflict error. Server errors are not recorded,
+// so that clients can retry the request; neither are the responses of requests whose
+// handler panicked, whose keys are released. Keys are reserved for a short duration while
+// their request is served (see [WithIdempotencyLockTTL]), so that a key is not locked for
+// the full ttl if the server stops before the response is recorded. Requests without a key
+// are served as is.
+//
+// Keys are global unless scoped with [WithIdempotencyScope]: without a scope, a client that
+// reuses another client's key with the same request is served the other client's recorded
+// response. APIs serving more than one principal should always scope keys.
+//
+// The middleware can be applied before or after [UseRequestBodyParser]; request bodies read
+// by the middleware are bound by the limit's MaxBodyBytes (see [server.WithLimits]).
+func UseIdempotencyKeys(store IdempotencyStore, options ...func(*IdempotencyConfig)) server.Options {
+	cfg := IdempotencyConfig{ttl: DefaultIdempotencyTTL, lockTTL: DefaultIdempotencyLockTTL}
+	for _, option := range options {
+		option(&cfg)
+	}
+
+	return server.WithMiddleware(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(HeaderIdempotencyKey)
+			if key == "" || !isIdempotentCreate(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			fingerprint, err := requestFingerprint(w, r)
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				writeBodyTooLarge(w, tooLarge.Limit)
+				return
+			} else if err != nil {
+				server.Error(w, fmt.Errorf("failed to read request: %w", err), http.StatusBadRequest)
+				return
+			}
+
+			if cfg.scope != nil {
+				scope := cfg.scope(r)
+				key = fmt.Sprintf("%d:%s:%s", len(scope), scope, key)
+			}
+
+			record, reserved, err := store.Reserve(r.Context(), key, fingerprint, cfg.lockTTL)
+			if err != nil {
+				server.Error(w, fmt.Errorf("failed to reserve idempotency key: %w", err), http.StatusInternalServerError)
+				return
+			}
+
+			if !reserved {
+				replayIdempotentResponse(w, record, fingerprint)
+				return
+			}
+
+			// release the key unless the response is recorded, including when the handler
+			// panics, so that clients can retry the request.
+			completed := false
+			defer func() {
+				if completed {
+					return
+				}
+				if err := store.Release(context.WithoutCancel(r.Context()), key); err != nil {
+					server.LoggerFromContext(r.Context()).ErrorContext(r.Context(),
+						"jsonapi: failed to release idempotency key", slog.Any("error", err))
+				}
+			}()
+
+			mem := server.NewRecorder()
+			next.ServeHTTP(mem, r)
+
+			if mem.Status < http.StatusInternalServerError {
+				err = store.Complete(r.Context(), key, IdempotencyRecord{
+					Fingerprint: fingerprint,
+					Completed:   true,
+					Status:      mem.Status,
+					Header:      mem.HeaderMap.Clone(),
+					Document:    mem.Document,
+				}, cfg.ttl)
+				completed = err == nil
+
+				if err != nil {
+					server.LoggerFromContext(r.Context()).ErrorContext(r.Context(),
+						"jsonapi: failed to record idempotent response", slog.Any("error", err))
+				}
+			}
+
+			mem.Flush(w)
+		})
+	})
+}
+
+// isIdempotentCreate returns true if the request creates a resource or adds relationship members.
+func isIdempotentCreate(r *http.Request) bool {
+	if r.Method != http.MethodPost {
+		return false
+	}
+	ctx := jsonapi.FromContext(r.Context())
+	if ctx.Relationship != "" {
+		return !ctx.Related
+	}
+	return ctx.ResourceID == ""
+}
+
+// requestFingerprint hashes the request's method, path and raw body. The body is read -- up
+// to the body limit -- and restored.
+func requestFingerprint(w http.ResponseWriter, r *http.Request) (string, error) {
+	var body []byte
+	if r.Body != nil {
+		if max := server.LimitsFromContext(r.Context()).MaxBodyBytes; max > 0 {
+			r.Body = http.MaxBytesReader(w, r.Body, max)
+		}
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			return "", err
+		}
+		r.Body = io.NopCloser(bytes.NewReader(data))
+		body = data
+	}
+
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s %s\n", r.Method, r.URL.EscapedPath())
+	hash.Write(bytes.TrimSpace(body))
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// replayIdempotentResponse writes the response recorded for the key, or rejects the request
+// if the key was used for a different request or the original request is being served.
+func replayIdempotentResponse(w http.ResponseWriter, record IdempotencyRecord, fingerprint string) {
+	switch {
+	case record.Fingerprint != fingerprint:
+		idempotencyError(w, http.StatusUnprocessableEntity, "idempotency key was used for a different request")
+	case !record.Completed:
+		idempotencyError(w, http.StatusConflict, "a request with the same idempotency key is being processed")
+	default:
+		mem := server.NewRecorder()
+		mem.Status = record.Status
+		mem.HeaderMap = record.Header.Clone()
+		if mem.HeaderMap == nil {
+			mem.HeaderMap = make(http.Header)
+		}
+		mem.HeaderMap.Set(HeaderIdempotentReplayed, "true")
+		mem.Document = record.Document
+		mem.Flush(w)
+	}
+}
+
+func idempotencyError(w http.ResponseWriter, status int, detail string) {
+	server.Error(w, jsonapi.Error{
+		Status: strconv.Itoa(status),
+		Title:  http.StatusText(status),
+		Detail: detail,
+		Source: &jsonapi.ErrorSource{Header: HeaderIdempotencyKey},
+	}, status)
+}
+
+// MemoryIdempotencyStore is an [IdempotencyStore] that keeps records in memory. Expired
+// records are ignored when their key is reserved, and removed by periodic sweeps. The zero
+// value is ready to use.
+type MemoryIdempotencyStore struct {
+	mu      sync.Mutex
+	records map[string]memoryIdempotencyRecord
+	swept   time.Time
+}
+
+type memoryIdempotencyRecord struct {
+	IdempotencyRecord
+	expires time.Time
+}
+
+// NewMemoryIdempotencyStore creates a new in-memory idempotency store.
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{}
+}
+
+// Reserve reserves the unused key for the request with the fingerprint.
+func (s *MemoryIdempotencyStore) Reserve(ctx context.Context, key string, fingerprint string, ttl time.Duration) (IdempotencyRecord, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.swept) >= idempotencySweepInterval {
+		s.sweep(now)
+	}
+
+	if record, ok := s.records[key]; ok && now.Before(record.expires) {
+		return record.IdempotencyRecord, false, nil
+	}
+
+	if s.records == nil {
+		s.records = make(map[string]memoryIdempotencyRecord)
+	}
+	s.records[key] = memoryIdempotencyRecord{
+		IdempotencyRecord: IdempotencyRecord{Fingerprint: fingerprint},
+		expires:           now.Add(ttl),
+	}
+	return IdempotencyRecord{}, true, nil
+}
+
+// sweep removes the expired records.
+func (s *MemoryIdempotencyStore) sweep(now time.Time) {
+	for key, record := range s.records {
+		if !now.Before(record.expires) {
+			delete(s.records, key)
+		}
+	}
+	s.swept = now
+}
+
+// Complete records the response of the request that reserved the key.
+func (s *MemoryIdempotencyStore) Complete(ctx context.Context, key string, record IdempotencyRecord, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.records == nil {
+		s.records = make(map[string]memoryIdempotencyRecord)
+	}
+	s.records[key] = memoryIdempotencyRecord{IdempotencyRecord: record, expires: time.Now().Add(ttl)}
+	return nil
+}
+
+// Release removes the key.
+func (s *MemoryIdempotencyStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, key)
+	return nil
+}
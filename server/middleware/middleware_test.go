@@ -1,16 +1,32 @@
 package middleware_test
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/gonobo/jsonapi/v2"
 	"github.com/gonobo/jsonapi/v2/query"
+	"github.com/gonobo/jsonapi/v2/query/fieldset"
+	"github.com/gonobo/jsonapi/v2/query/filter"
 	"github.com/gonobo/jsonapi/v2/query/page"
+	sortparser "github.com/gonobo/jsonapi/v2/query/sort"
 	"github.com/gonobo/jsonapi/v2/server"
 	"github.com/gonobo/jsonapi/v2/server/middleware"
+	"github.com/gonobo/jsonapi/v2/server/pagination"
+	"github.com/gonobo/jsonapi/v2/server/servertest"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -69,3 +85,1797 @@ func TestPageQueryParser(t *testing.T) {
 		tc.run(t)
 	}
 }
+
+func TestRecovery(t *testing.T) {
+	type thing struct {
+		ID      string   `jsonapi:"primary,things"`
+		Related []*thing `jsonapi:"relation,related"`
+	}
+
+	for _, tc := range []struct {
+		name    string
+		options []server.Options
+		req     *http.Request
+		handler http.HandlerFunc
+	}{
+		{
+			name:    "recovers from handler panic",
+			options: []server.Options{middleware.UseRecovery(nil)},
+			req:     httptest.NewRequest("GET", "https://example.com/things/1", nil),
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				panic("oops")
+			},
+		},
+		{
+			name: "recovers from include sub-request panic",
+			options: []server.Options{
+				middleware.UseRecovery(nil),
+				middleware.UseIncludeQueryParser(),
+				middleware.UseIncludedResourceResolver(),
+			},
+			req: httptest.NewRequest("GET", "https://example.com/things/1?include=related", nil),
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				ctx := jsonapi.FromContext(r.Context())
+				if len(ctx.FetchIDs) > 0 {
+					panic("oops")
+				}
+				server.Write(w, thing{ID: "1", Related: []*thing{{ID: "2"}}}, http.StatusOK)
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			mux := server.ResourceMux{"things": tc.handler}
+			handler := server.Handle(mux, tc.options...)
+			w := httptest.NewRecorder()
+
+			assert.NotPanics(t, func() { handler.ServeHTTP(w, tc.req) })
+			assert.Equal(t, http.StatusInternalServerError, w.Result().StatusCode)
+
+			doc := jsonapi.Document{}
+			err := jsonapi.Decode(w.Result().Body, &doc)
+			if assert.NoError(t, err) && assert.Len(t, doc.Errors, 1) {
+				assert.NotEmpty(t, doc.Errors[0].ID)
+				assert.Equal(t, "500", doc.Errors[0].Status)
+			}
+		})
+	}
+
+	t.Run("logs the stack trace with the error id", func(t *testing.T) {
+		var logged string
+		logger := middleware.LoggerFunc(func(format string, v ...any) {
+			logged = fmt.Sprintf(format, v...)
+		})
+
+		mux := server.ResourceMux{"things": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic("oops")
+		})}
+
+		handler := server.Handle(mux, middleware.UseRecovery(logger))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "https://example.com/things", nil))
+
+		doc := jsonapi.Document{}
+		err := jsonapi.Decode(w.Result().Body, &doc)
+		if assert.NoError(t, err) && assert.Len(t, doc.Errors, 1) {
+			assert.Contains(t, logged, "level=ERROR")
+			assert.Contains(t, logged, "error_id="+doc.Errors[0].ID)
+			assert.Contains(t, logged, "oops")
+			assert.Contains(t, logged, "goroutine")
+		}
+	})
+
+	t.Run("logs with the handler's structured logger", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+		mux := server.ResourceMux{"things": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic("oops")
+		})}
+
+		handler := server.Handle(mux, server.WithLogger(logger), middleware.UseRecovery(nil))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "https://example.com/things/1", nil))
+
+		entry := map[string]any{}
+		assert.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+		assert.Equal(t, "ERROR", entry["level"])
+		assert.Equal(t, "oops", entry["panic"])
+		assert.Equal(t, map[string]any{"type": "things", "id": "1"}, entry["jsonapi"])
+	})
+
+	t.Run("propagates aborted handlers", func(t *testing.T) {
+		mux := server.ResourceMux{"things": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic(http.ErrAbortHandler)
+		})}
+
+		handler := server.Handle(mux, middleware.UseRecovery(nil))
+		w := httptest.NewRecorder()
+		assert.Panics(t, func() {
+			handler.ServeHTTP(w, httptest.NewRequest("GET", "https://example.com/things", nil))
+		})
+	})
+}
+
+type person struct {
+	ID string `jsonapi:"primary,people"`
+}
+
+type comment struct {
+	ID     string  `jsonapi:"primary,comments"`
+	Author *person `jsonapi:"relation,author,omitempty"`
+}
+
+type article struct {
+	ID       string     `jsonapi:"primary,articles"`
+	Author   *person    `jsonapi:"relation,author,omitempty"`
+	Comments []*comment `jsonapi:"relation,comments,omitempty"`
+}
+
+// store serves resources by type, and records the ids requested by each fetch.
+type store struct {
+	articles map[string]article
+	comments map[string]comment
+	people   map[string]person
+	fetches  map[string][][]string
+}
+
+func newStore() *store {
+	return &store{
+		articles: map[string]article{
+			"1": {ID: "1", Author: &person{ID: "9"}, Comments: []*comment{{ID: "5"}, {ID: "6"}}},
+			"2": {ID: "2", Author: &person{ID: "8"}, Comments: []*comment{{ID: "7"}}},
+		},
+		comments: map[string]comment{
+			"5": {ID: "5", Author: &person{ID: "8"}},
+			"6": {ID: "6", Author: &person{ID: "7"}},
+			"7": {ID: "7", Author: &person{ID: "9"}},
+		},
+		people: map[string]person{
+			"7": {ID: "7"},
+			"8": {ID: "8"},
+			"9": {ID: "9"},
+		},
+		fetches: map[string][][]string{},
+	}
+}
+
+// withoutIncluded removes the resources included by the marshaler, leaving
+// inclusion to the middleware under test.
+var withoutIncluded = server.WithDocumentOptions(func(w http.ResponseWriter, d *jsonapi.Document) error {
+	d.Included = nil
+	return nil
+})
+
+func serveType[T any](s *store, items map[string]T) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := jsonapi.FromContext(r.Context())
+		if ctx.ResourceID != "" {
+			server.Write(w, items[ctx.ResourceID], http.StatusOK, withoutIncluded)
+			return
+		}
+
+		ids := ctx.FetchIDs
+		if len(ids) > 0 {
+			s.fetches[ctx.ResourceType] = append(s.fetches[ctx.ResourceType], ids)
+		} else {
+			for id := range items {
+				ids = append(ids, id)
+			}
+			sort.Strings(ids)
+		}
+
+		out := make([]T, 0, len(ids))
+		for _, id := range ids {
+			out = append(out, items[id])
+		}
+		server.Write(w, out, http.StatusOK, withoutIncluded)
+	}
+}
+
+func (s *store) mux() server.ResourceMux {
+	return server.ResourceMux{
+		"articles": serveType(s, s.articles),
+		"comments": serveType(s, s.comments),
+		"people":   serveType(s, s.people),
+	}
+}
+
+func includedKeys(doc jsonapi.Document) []string {
+	keys := make([]string, 0, len(doc.Included))
+	for _, item := range doc.Included {
+		keys = append(keys, item.Type+":"+item.ID)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestIncludedResourceResolver(t *testing.T) {
+	options := []server.Options{
+		middleware.UseIncludeQueryParser(),
+		middleware.UseIncludedResourceResolver(),
+	}
+
+	get := func(t *testing.T, s *store, target string) jsonapi.Document {
+		w := httptest.NewRecorder()
+		server.Handle(s.mux(), options...).ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+		assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+		doc := jsonapi.Document{}
+		assert.NoError(t, jsonapi.Decode(w.Result().Body, &doc))
+		return doc
+	}
+
+	t.Run("includes resources for a single resource", func(t *testing.T) {
+		s := newStore()
+		doc := get(t, s, "https://example.com/articles/1?include=author")
+		assert.Equal(t, []string{"people:9"}, includedKeys(doc))
+	})
+
+	t.Run("includes resources for a collection", func(t *testing.T) {
+		s := newStore()
+		doc := get(t, s, "https://example.com/articles?include=author,comments")
+		assert.Equal(t, []string{
+			"comments:5", "comments:6", "comments:7", "people:8", "people:9",
+		}, includedKeys(doc))
+		assert.Len(t, s.fetches["people"], 1, "people should be fetched once")
+		assert.Len(t, s.fetches["comments"], 1, "comments should be fetched once")
+	})
+
+	t.Run("batches nested includes by depth", func(t *testing.T) {
+		s := newStore()
+		doc := get(t, s, "https://example.com/articles?include=author,comments.author")
+		assert.Equal(t, []string{
+			"comments:5", "comments:6", "comments:7", "people:7", "people:8", "people:9",
+		}, includedKeys(doc))
+		assert.Equal(t, [][]string{{"5", "6", "7"}}, s.fetches["comments"])
+		assert.Equal(t, [][]string{{"9", "8"}, {"7"}}, s.fetches["people"],
+			"people should be fetched once per depth, without refetching")
+	})
+
+	t.Run("skips requests without includes", func(t *testing.T) {
+		s := newStore()
+		doc := get(t, s, "https://example.com/articles")
+		assert.Empty(t, doc.Included)
+		assert.Empty(t, s.fetches)
+	})
+
+	t.Run("reports failures to fetch included resources", func(t *testing.T) {
+		s := newStore()
+		mux := s.mux()
+		mux["people"] = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			server.Error(w, errors.New("database unavailable"), http.StatusServiceUnavailable)
+		})
+
+		w := httptest.NewRecorder()
+		server.Handle(mux, options...).ServeHTTP(w, httptest.NewRequest("GET", "https://example.com/articles/1?include=author", nil))
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	})
+
+	t.Run("skips resource types that are not found", func(t *testing.T) {
+		s := newStore()
+		mux := s.mux()
+		delete(mux, "people")
+
+		w := httptest.NewRecorder()
+		server.Handle(mux, options...).ServeHTTP(w, httptest.NewRequest("GET", "https://example.com/articles/1?include=author", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		doc := jsonapi.Document{}
+		assert.NoError(t, jsonapi.Decode(w.Body, &doc))
+		assert.Empty(t, doc.Included)
+	})
+}
+
+func loadType[T any](s *store, items map[string]T) server.Loader {
+	return server.LoaderFunc(func(ctx context.Context, resourceType string, ids []string) ([]*jsonapi.Resource, error) {
+		s.fetches[resourceType] = append(s.fetches[resourceType], ids)
+		out := make([]*jsonapi.Resource, 0, len(ids))
+		for _, id := range ids {
+			item, ok := items[id]
+			if !ok {
+				continue
+			}
+			resource, err := jsonapi.MarshalResource(item)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, resource)
+		}
+		return out, nil
+	})
+}
+
+func (s *store) loaderMux() server.ResourceMux {
+	return server.ResourceMux{
+		"articles": server.Resource{
+			List:   serveType(s, s.articles),
+			Get:    serveType(s, s.articles),
+			Loader: loadType(s, s.articles),
+		},
+		"comments": server.Resource{
+			List:   serveType(s, s.comments),
+			Loader: loadType(s, s.comments),
+		},
+		"people": server.Resource{Loader: loadType(s, s.people)},
+	}
+}
+
+func TestResolversWithLoader(t *testing.T) {
+	serve := func(t *testing.T, s *store, target string, options ...server.Options) *http.Response {
+		mux := s.loaderMux()
+		options = append(options, server.WithLoader(mux))
+		w := httptest.NewRecorder()
+		server.Handle(mux, options...).ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+		return w.Result()
+	}
+
+	t.Run("includes resources with loaders", func(t *testing.T) {
+		s := newStore()
+		res := serve(t, s, "https://example.com/articles?include=comments.author",
+			middleware.UseIncludeQueryParser(),
+			middleware.UseIncludedResourceResolver(),
+		)
+
+		doc := jsonapi.Document{}
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.NoError(t, jsonapi.Decode(res.Body, &doc))
+		assert.Equal(t, []string{
+			"comments:5", "comments:6", "comments:7", "people:7", "people:8", "people:9",
+		}, includedKeys(doc))
+		assert.Equal(t, [][]string{{"5", "6", "7"}}, s.fetches["comments"])
+		assert.Equal(t, [][]string{{"8", "7", "9"}}, s.fetches["people"])
+	})
+
+	t.Run("retrieves related resources with loaders", func(t *testing.T) {
+		s := newStore()
+		res := serve(t, s, "https://example.com/articles/1/comments",
+			middleware.UseRelatedResourceResolver(),
+		)
+
+		doc := jsonapi.Document{}
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.NoError(t, jsonapi.Decode(res.Body, &doc))
+		assert.Len(t, doc.Data.Items(), 2)
+		assert.Equal(t, [][]string{{"1"}}, s.fetches["articles"])
+		assert.Equal(t, [][]string{{"5", "6"}}, s.fetches["comments"])
+	})
+
+	t.Run("returns 404 when the parent resource cannot be loaded", func(t *testing.T) {
+		s := newStore()
+		res := serve(t, s, "https://example.com/articles/404/comments",
+			middleware.UseRelatedResourceResolver(),
+		)
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	})
+}
+
+func TestConcurrentIncludes(t *testing.T) {
+	types := []string{"a", "b", "c", "d"}
+
+	primary := &jsonapi.Resource{Type: "posts", ID: "1", Relationships: jsonapi.RelationshipsNode{}}
+	include := make([]string, 0, len(types))
+	for _, name := range types {
+		primary.Relationships[name] = &jsonapi.Relationship{
+			Data: jsonapi.One{Value: &jsonapi.Resource{Type: name, ID: "1"}},
+		}
+		include = append(include, name)
+	}
+
+	serve := func(loader server.LoaderFunc, limit int, options ...server.Options) *http.Response {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			server.Write(w, jsonapi.NewSingleDocument(primary), http.StatusOK)
+		})
+		options = append(options,
+			server.WithLoader(loader),
+			middleware.UseIncludeQueryParser(),
+			middleware.UseIncludedResourceResolver(middleware.WithMaxConcurrency(limit)),
+		)
+		target := "https://example.com/posts/1?include=" + strings.Join(include, ",")
+		w := httptest.NewRecorder()
+		server.Handle(handler, options...).ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+		return w.Result()
+	}
+
+	t.Run("fetches branches concurrently within the limit", func(t *testing.T) {
+		var mu sync.Mutex
+		inflight, peak := 0, 0
+		release := make(chan struct{})
+		once := sync.Once{}
+
+		loader := func(ctx context.Context, resourceType string, ids []string) ([]*jsonapi.Resource, error) {
+			mu.Lock()
+			inflight++
+			peak = max(peak, inflight)
+			if inflight == 2 {
+				once.Do(func() { close(release) })
+			}
+			mu.Unlock()
+
+			<-release
+
+			mu.Lock()
+			inflight--
+			mu.Unlock()
+			return []*jsonapi.Resource{{Type: resourceType, ID: ids[0]}}, nil
+		}
+
+		res := serve(loader, 2)
+		doc := jsonapi.Document{}
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.NoError(t, jsonapi.Decode(res.Body, &doc))
+		assert.Equal(t, 2, peak)
+
+		got := make([]string, 0, len(doc.Included))
+		for _, item := range doc.Included {
+			got = append(got, item.Type)
+		}
+		assert.Equal(t, types, got, "included resources should be merged in order")
+	})
+
+	t.Run("cancels remaining fetches on failure", func(t *testing.T) {
+		loader := func(ctx context.Context, resourceType string, ids []string) ([]*jsonapi.Resource, error) {
+			if resourceType == "a" {
+				return nil, errors.New("backend unavailable")
+			}
+			// block until the failure cancels the remaining fetches.
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+
+		res := serve(loader, len(types))
+		assert.Equal(t, http.StatusInternalServerError, res.StatusCode)
+
+		doc := jsonapi.Document{}
+		assert.NoError(t, jsonapi.Decode(res.Body, &doc))
+		if assert.Len(t, doc.Errors, 1) {
+			assert.Contains(t, doc.Errors[0].Detail, "backend unavailable")
+		}
+	})
+
+	t.Run("raises fetch panics on the request goroutine", func(t *testing.T) {
+		loader := func(ctx context.Context, resourceType string, ids []string) ([]*jsonapi.Resource, error) {
+			panic("oops")
+		}
+
+		var logged string
+		res := serve(loader, 2, middleware.UseRecovery(middleware.LoggerFunc(func(format string, v ...any) {
+			logged = fmt.Sprintf(format, v...)
+		})))
+		assert.Equal(t, http.StatusInternalServerError, res.StatusCode)
+		assert.Contains(t, logged, "oops")
+		assert.Contains(t, logged, "fetch worker stack")
+	})
+}
+
+func TestRelatedResourceResolver(t *testing.T) {
+	serve := func(t *testing.T, mux server.ResourceMux, target string) (*http.Response, jsonapi.Document) {
+		w := httptest.NewRecorder()
+		handler := server.Handle(mux,
+			middleware.UsePageQueryParser(page.DefaultPageParser),
+			middleware.UseSortQueryParser(sortparser.DefaultParser),
+			middleware.UseRelatedResourceResolver(),
+		)
+		handler.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+		doc := jsonapi.Document{}
+		assert.NoError(t, jsonapi.Decode(w.Result().Body, &doc))
+		return w.Result(), doc
+	}
+
+	t.Run("delegates to-many relationships to the list handler", func(t *testing.T) {
+		s := newStore()
+		mux := s.mux()
+		mux["comments"] = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := jsonapi.FromContext(r.Context())
+			assert.Equal(t, []string{"5", "6"}, ctx.FetchIDs)
+			assert.Equal(t, query.Page{PageNumber: 1, Limit: 1}, ctx.Pagination)
+			assert.Equal(t, []query.Sort{{Property: "created"}}, ctx.Sort)
+
+			server.Write(w, []comment{s.comments["6"]}, http.StatusOK,
+				withoutIncluded,
+				server.WriteNavigationLinks(r, map[string]query.Page{
+					"next": {PageNumber: 2, Limit: 1},
+				}),
+			)
+		})
+
+		res, doc := serve(t, mux, "https://example.com/articles/1/comments?page[number]=1&page[limit]=1&sort=created")
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		if assert.Len(t, doc.Data.Items(), 1) {
+			assert.Equal(t, "6", doc.Data.First().ID)
+		}
+		assert.Contains(t, doc.Links["self"].Href, "/articles/1/comments")
+		assert.Contains(t, doc.Links["next"].Href, "/articles/1/comments")
+		assert.Contains(t, doc.Links["next"].Href, "page%5Bnumber%5D=2")
+	})
+
+	t.Run("navigates the related resource URL", func(t *testing.T) {
+		s := newStore()
+		mux := s.mux()
+		mux["comments"] = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// the links are derived from the collection URL of the comments.
+			server.Write(w, []comment{s.comments["5"]}, http.StatusOK,
+				withoutIncluded,
+				server.WriteLink("first", "https://example.com/comments?page%5Bnumber%5D=1&page%5Blimit%5D=1"),
+				server.WriteLink("next", "https://example.com/comments?page%5Bnumber%5D=2&page%5Blimit%5D=1"),
+			)
+		})
+
+		res, doc := serve(t, mux, "https://example.com/articles/1/comments?page[number]=1&page[limit]=1")
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "https://example.com/articles/1/comments?page%5Bnumber%5D=1&page%5Blimit%5D=1", doc.Links["first"].Href)
+		assert.Equal(t, "https://example.com/articles/1/comments?page%5Bnumber%5D=2&page%5Blimit%5D=1", doc.Links["next"].Href)
+	})
+
+	t.Run("returns a single resource for to-one relationships", func(t *testing.T) {
+		s := newStore()
+		res, doc := serve(t, s.mux(), "https://example.com/articles/1/author")
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.False(t, doc.Data.IsMany())
+		assert.Equal(t, "9", doc.Data.First().ID)
+		assert.Equal(t, "people", doc.Data.First().Type)
+	})
+
+	t.Run("returns null for empty to-one relationships", func(t *testing.T) {
+		mux := server.ResourceMux{
+			"articles": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				server.Write(w, jsonapi.NewSingleDocument(&jsonapi.Resource{
+					Type: "articles",
+					ID:   "1",
+					Relationships: jsonapi.RelationshipsNode{
+						"author": &jsonapi.Relationship{Data: jsonapi.One{}},
+					},
+				}), http.StatusOK)
+			}),
+		}
+
+		res, doc := serve(t, mux, "https://example.com/articles/1/author")
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.False(t, doc.Data.IsMany())
+		assert.Nil(t, doc.Data.First())
+	})
+
+	t.Run("returns 404 on unknown relationships", func(t *testing.T) {
+		s := newStore()
+		res, _ := serve(t, s.mux(), "https://example.com/articles/1/unknown")
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	})
+
+	t.Run("reports failures to fetch related resources", func(t *testing.T) {
+		s := newStore()
+		mux := s.mux()
+		mux["people"] = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			server.Error(w, errors.New("database unavailable"), http.StatusServiceUnavailable)
+		})
+
+		res, doc := serve(t, mux, "https://example.com/articles/1/author")
+		assert.Equal(t, http.StatusServiceUnavailable, res.StatusCode)
+		if assert.Len(t, doc.Errors, 1) {
+			assert.Equal(t, "database unavailable", doc.Errors[0].Detail)
+		}
+	})
+}
+
+func TestConditionalRequests(t *testing.T) {
+	type thing struct {
+		ID    string `jsonapi:"primary,things"`
+		Title string `jsonapi:"attr,title"`
+	}
+
+	modified := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+	// serve returns a handler over a single mutable thing, and a count of updates.
+	serve := func() (http.Handler, *int) {
+		current := &thing{ID: "1", Title: "first"}
+		updates := 0
+		mux := server.ResourceMux{"things": server.Resource{
+			Get: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if current == nil {
+					server.Error(w, errors.New("not found"), http.StatusNotFound)
+					return
+				}
+				server.Write(w, current, http.StatusOK, server.WriteLastModified(modified))
+			}),
+			Update: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				updates++
+				current.Title = "second"
+				server.Write(w, current, http.StatusOK)
+			}),
+			Delete: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				updates++
+				current = nil
+				w.WriteHeader(http.StatusNoContent)
+			}),
+		}}
+		return server.Handle(mux, middleware.UseConditionalRequests()), &updates
+	}
+
+	request := func(handler http.Handler, method string, header http.Header) *http.Response {
+		var body io.Reader
+		if method == http.MethodPatch {
+			body = strings.NewReader(`{"data":{"type":"things","id":"1","attributes":{"title":"second"}}}`)
+		}
+		req := httptest.NewRequest(method, "https://example.com/things/1", body)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Result()
+	}
+
+	etag := func(t *testing.T, handler http.Handler) string {
+		res := request(handler, http.MethodGet, nil)
+		tag := res.Header.Get(server.HeaderKeyETag)
+		assert.NotEmpty(t, tag)
+		return tag
+	}
+
+	t.Run("adds etag to fetch responses", func(t *testing.T) {
+		handler, _ := serve()
+		assert.Equal(t, etag(t, handler), etag(t, handler))
+	})
+
+	t.Run("head responses carry the same validators as get", func(t *testing.T) {
+		handler, _ := serve()
+		tag := etag(t, handler)
+
+		res := request(handler, http.MethodHead, nil)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, tag, res.Header.Get(server.HeaderKeyETag))
+		data, _ := io.ReadAll(res.Body)
+		assert.Empty(t, data)
+
+		res = request(handler, http.MethodHead, http.Header{"If-None-Match": {tag}})
+		assert.Equal(t, http.StatusNotModified, res.StatusCode)
+		assert.Equal(t, tag, res.Header.Get(server.HeaderKeyETag))
+	})
+
+	for _, tc := range []struct {
+		name       string
+		header     func(tag string) http.Header
+		wantStatus int
+	}{
+		{
+			name:       "if-none-match matches",
+			header:     func(tag string) http.Header { return http.Header{"If-None-Match": {tag}} },
+			wantStatus: http.StatusNotModified,
+		},
+		{
+			name:       "if-none-match matches weak tag",
+			header:     func(tag string) http.Header { return http.Header{"If-None-Match": {`"other", W/` + tag}} },
+			wantStatus: http.StatusNotModified,
+		},
+		{
+			name:       "if-none-match wildcard",
+			header:     func(tag string) http.Header { return http.Header{"If-None-Match": {"*"}} },
+			wantStatus: http.StatusNotModified,
+		},
+		{
+			name:       "if-none-match does not match",
+			header:     func(tag string) http.Header { return http.Header{"If-None-Match": {`"other"`}} },
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "if-modified-since not modified",
+			header: func(tag string) http.Header {
+				return http.Header{"If-Modified-Since": {modified.Format(http.TimeFormat)}}
+			},
+			wantStatus: http.StatusNotModified,
+		},
+		{
+			name: "if-modified-since modified",
+			header: func(tag string) http.Header {
+				return http.Header{"If-Modified-Since": {modified.Add(-time.Hour).Format(http.TimeFormat)}}
+			},
+			wantStatus: http.StatusOK,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			handler, _ := serve()
+			tag := etag(t, handler)
+			res := request(handler, http.MethodGet, tc.header(tag))
+			assert.Equal(t, tc.wantStatus, res.StatusCode)
+			assert.Equal(t, tag, res.Header.Get(server.HeaderKeyETag))
+			if tc.wantStatus == http.StatusNotModified {
+				data, _ := io.ReadAll(res.Body)
+				assert.Empty(t, data)
+			}
+		})
+	}
+
+	for _, tc := range []struct {
+		name        string
+		method      string
+		header      func(tag string) http.Header
+		wantStatus  int
+		wantUpdates int
+	}{
+		{
+			name:        "if-match matches update",
+			method:      http.MethodPatch,
+			header:      func(tag string) http.Header { return http.Header{"If-Match": {tag}} },
+			wantStatus:  http.StatusOK,
+			wantUpdates: 1,
+		},
+		{
+			name:        "if-match matches delete",
+			method:      http.MethodDelete,
+			header:      func(tag string) http.Header { return http.Header{"If-Match": {`"other", ` + tag}} },
+			wantStatus:  http.StatusNoContent,
+			wantUpdates: 1,
+		},
+		{
+			name:        "if-match wildcard",
+			method:      http.MethodPatch,
+			header:      func(tag string) http.Header { return http.Header{"If-Match": {"*"}} },
+			wantStatus:  http.StatusOK,
+			wantUpdates: 1,
+		},
+		{
+			name:       "if-match stale tag",
+			method:     http.MethodPatch,
+			header:     func(tag string) http.Header { return http.Header{"If-Match": {`"stale"`}} },
+			wantStatus: http.StatusPreconditionFailed,
+		},
+		{
+			name:       "if-match rejects weak tag",
+			method:     http.MethodDelete,
+			header:     func(tag string) http.Header { return http.Header{"If-Match": {"W/" + tag}} },
+			wantStatus: http.StatusPreconditionFailed,
+		},
+		{
+			name:   "if-unmodified-since not modified",
+			method: http.MethodPatch,
+			header: func(tag string) http.Header {
+				return http.Header{"If-Unmodified-Since": {modified.Format(http.TimeFormat)}}
+			},
+			wantStatus:  http.StatusOK,
+			wantUpdates: 1,
+		},
+		{
+			name:   "if-unmodified-since modified",
+			method: http.MethodPatch,
+			header: func(tag string) http.Header {
+				return http.Header{"If-Unmodified-Since": {modified.Add(-time.Hour).Format(http.TimeFormat)}}
+			},
+			wantStatus: http.StatusPreconditionFailed,
+		},
+		{
+			name:        "no preconditions",
+			method:      http.MethodPatch,
+			header:      func(tag string) http.Header { return nil },
+			wantStatus:  http.StatusOK,
+			wantUpdates: 1,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			handler, updates := serve()
+			tag := etag(t, handler)
+			res := request(handler, tc.method, tc.header(tag))
+			assert.Equal(t, tc.wantStatus, res.StatusCode)
+			assert.Equal(t, tc.wantUpdates, *updates)
+
+			if tc.wantStatus == http.StatusPreconditionFailed {
+				doc := jsonapi.Document{}
+				err := jsonapi.Decode(res.Body, &doc)
+				if assert.NoError(t, err) && assert.Len(t, doc.Errors, 1) {
+					assert.Equal(t, "412", doc.Errors[0].Status)
+					assert.NotEmpty(t, doc.Errors[0].Source.Header)
+				}
+			}
+		})
+	}
+
+	t.Run("if-match fails once resource is updated", func(t *testing.T) {
+		handler, updates := serve()
+		tag := etag(t, handler)
+		header := http.Header{"If-Match": {tag}}
+		assert.Equal(t, http.StatusOK, request(handler, http.MethodPatch, header).StatusCode)
+		assert.Equal(t, http.StatusPreconditionFailed, request(handler, http.MethodPatch, header).StatusCode)
+		assert.Equal(t, 1, *updates)
+	})
+
+	t.Run("if-match fails for missing resource", func(t *testing.T) {
+		handler, updates := serve()
+		assert.Equal(t, http.StatusNoContent, request(handler, http.MethodDelete, nil).StatusCode)
+		header := http.Header{"If-Match": {"*"}}
+		assert.Equal(t, http.StatusPreconditionFailed, request(handler, http.MethodDelete, header).StatusCode)
+		assert.Equal(t, 1, *updates)
+	})
+}
+
+func TestRequestValidator(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux := server.ResourceMux{"articles": server.Resource{
+		Create:        ok,
+		Update:        ok,
+		Delete:        ok,
+		Relationships: ok,
+	}}
+	handler := server.Handle(mux, middleware.UseRequestBodyParser(), middleware.UseRequestValidator())
+
+	for _, tc := range []struct {
+		name         string
+		method       string
+		target       string
+		body         string
+		wantStatus   int
+		wantPointers []string
+	}{
+		{
+			name:       "valid create",
+			method:     "POST",
+			target:     "/articles",
+			body:       `{"data":{"type":"articles","attributes":{"title":"a"}}}`,
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name:         "create with mismatched type",
+			method:       "POST",
+			target:       "/articles",
+			body:         `{"data":{"type":"people","attributes":{"name":"a"}}}`,
+			wantStatus:   http.StatusConflict,
+			wantPointers: []string{"/data/type"},
+		},
+		{
+			name:         "create without data",
+			method:       "POST",
+			target:       "/articles",
+			body:         `{"meta":{}}`,
+			wantStatus:   http.StatusBadRequest,
+			wantPointers: []string{"/data"},
+		},
+		{
+			name:         "create without body",
+			method:       "POST",
+			target:       "/articles",
+			wantStatus:   http.StatusBadRequest,
+			wantPointers: []string{"/data"},
+		},
+		{
+			name:         "create with null data",
+			method:       "POST",
+			target:       "/articles",
+			body:         `{"data":null}`,
+			wantStatus:   http.StatusBadRequest,
+			wantPointers: []string{"/data"},
+		},
+		{
+			name:         "create with collection",
+			method:       "POST",
+			target:       "/articles",
+			body:         `{"data":[{"type":"articles"}]}`,
+			wantStatus:   http.StatusBadRequest,
+			wantPointers: []string{"/data"},
+		},
+		{
+			name:       "valid update",
+			method:     "PATCH",
+			target:     "/articles/1",
+			body:       `{"data":{"type":"articles","id":"1","attributes":{"title":"b"}}}`,
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name:         "update with mismatched id",
+			method:       "PATCH",
+			target:       "/articles/1",
+			body:         `{"data":{"type":"articles","id":"2"}}`,
+			wantStatus:   http.StatusConflict,
+			wantPointers: []string{"/data/id"},
+		},
+		{
+			name:         "update with mismatched type and missing id",
+			method:       "PATCH",
+			target:       "/articles/1",
+			body:         `{"data":{"type":"people"}}`,
+			wantStatus:   http.StatusBadRequest,
+			wantPointers: []string{"/data/type", "/data/id"},
+		},
+		{
+			name:       "delete without body",
+			method:     "DELETE",
+			target:     "/articles/1",
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name:       "valid to-one relationship update",
+			method:     "PATCH",
+			target:     "/articles/1/relationships/author",
+			body:       `{"data":{"type":"people","id":"9"}}`,
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name:       "clear to-one relationship",
+			method:     "PATCH",
+			target:     "/articles/1/relationships/author",
+			body:       `{"data":null}`,
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name:       "valid to-many relationship addition",
+			method:     "POST",
+			target:     "/articles/1/relationships/comments",
+			body:       `{"data":[{"type":"comments","id":"5"},{"type":"comments","lid":"x"}]}`,
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name:         "relationship update with resource object",
+			method:       "PATCH",
+			target:       "/articles/1/relationships/comments",
+			body:         `{"data":[{"type":"comments","id":"5"},{"type":"comments","attributes":{"body":"x"}}]}`,
+			wantStatus:   http.StatusBadRequest,
+			wantPointers: []string{"/data/1/id", "/data/1/attributes"},
+		},
+		{
+			name:         "relationship addition with null identifier",
+			method:       "POST",
+			target:       "/articles/1/relationships/tags",
+			body:         `{"data":[null]}`,
+			wantStatus:   http.StatusBadRequest,
+			wantPointers: []string{"/data/0"},
+		},
+		{
+			name:         "relationship removal with single identifier",
+			method:       "DELETE",
+			target:       "/articles/1/relationships/comments",
+			body:         `{"data":{"type":"comments","id":"5"}}`,
+			wantStatus:   http.StatusBadRequest,
+			wantPointers: []string{"/data"},
+		},
+		{
+			name:         "relationship removal without data",
+			method:       "DELETE",
+			target:       "/articles/1/relationships/comments",
+			wantStatus:   http.StatusBadRequest,
+			wantPointers: []string{"/data"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var body io.Reader
+			if tc.body != "" {
+				body = strings.NewReader(tc.body)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(tc.method, "https://example.com"+tc.target, body))
+			assert.Equal(t, tc.wantStatus, w.Result().StatusCode)
+
+			if len(tc.wantPointers) == 0 {
+				return
+			}
+
+			doc := jsonapi.Document{}
+			err := jsonapi.Decode(w.Result().Body, &doc)
+			if assert.NoError(t, err) {
+				pointers := make([]string, 0, len(doc.Errors))
+				for _, e := range doc.Errors {
+					pointers = append(pointers, e.Source.Pointer)
+				}
+				assert.Equal(t, tc.wantPointers, pointers)
+			}
+		})
+	}
+}
+
+func TestMemberNames(t *testing.T) {
+	type author struct {
+		ID string `jsonapi:"primary,people"`
+	}
+	type article struct {
+		ID        string  `jsonapi:"primary,articles"`
+		FirstLine string  `jsonapi:"attr,first_line"`
+		CoAuthor  *author `jsonapi:"relation,co_author"`
+	}
+
+	const profile = "https://example.com/profiles/kebab-case"
+
+	var got jsonapi.RequestContext
+	mux := server.ResourceMux{"articles": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := jsonapi.FromContext(r.Context())
+		got = *ctx
+
+		in := article{ID: "1"}
+		if ctx.Document != nil {
+			if err := jsonapi.Unmarshal(ctx.Document, &in); err != nil {
+				server.Error(w, err, http.StatusBadRequest)
+				return
+			}
+		}
+		in.CoAuthor = &author{ID: "9"}
+		server.Write(w, in, http.StatusOK, withoutIncluded)
+	})}
+
+	handler := server.Handle(mux,
+		middleware.UseRequestBodyParser(),
+		middleware.UseIncludeQueryParser(),
+		middleware.UseSortQueryParser(sortparser.DefaultParser),
+		middleware.UseMemberNames(
+			jsonapi.MemberNames{Canonical: jsonapi.SnakeCase, Wire: jsonapi.CamelCase},
+			middleware.WithCasingProfile(profile, jsonapi.KebabCase),
+			middleware.WithCasingHeader("X-Member-Case"),
+		),
+	)
+
+	serve := func(req *http.Request) (*http.Response, jsonapi.Document) {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		doc := jsonapi.Document{}
+		assert.NoError(t, jsonapi.Decode(w.Result().Body, &doc))
+		return w.Result(), doc
+	}
+
+	t.Run("converts request and response names", func(t *testing.T) {
+		body := strings.NewReader(`{"data":{"type":"articles","id":"1","attributes":{"firstLine":"hello"}}}`)
+		req := httptest.NewRequest("PATCH", "https://example.com/articles/1?include=coAuthor&sort=firstLine", body)
+		res, doc := serve(req)
+
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, []string{"co_author"}, got.Include)
+		assert.Equal(t, "first_line", got.Sort[0].Property)
+		assert.Equal(t, map[string]any{"firstLine": "hello"}, doc.Data.First().Attributes)
+		assert.Contains(t, doc.Data.First().Relationships, "coAuthor")
+	})
+
+	t.Run("negotiates case by profile", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "https://example.com/articles/1/relationships/coAuthor", nil)
+		req.Header.Set("Accept", `application/vnd.api+json; profile="`+profile+`"`)
+		res, doc := serve(req)
+
+		assert.Equal(t, "co_author", got.Relationship)
+		assert.Contains(t, doc.Data.First().Attributes, "first-line")
+		assert.Equal(t, []string{profile}, doc.Jsonapi.Profile)
+		assert.Contains(t, res.Header.Get("Content-Type"), profile)
+	})
+
+	t.Run("negotiates case by header", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "https://example.com/articles/1", nil)
+		req.Header.Set("X-Member-Case", "pascal")
+		_, doc := serve(req)
+		assert.Contains(t, doc.Data.First().Attributes, "FirstLine")
+		assert.Empty(t, doc.Jsonapi.Profile)
+	})
+
+	t.Run("leaves the parent document intact", func(t *testing.T) {
+		var parent *jsonapi.Document
+		capture := server.WithMiddleware(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				next.ServeHTTP(w, r)
+				parent = jsonapi.FromContext(r.Context()).Document
+			})
+		})
+		handler := server.Handle(mux,
+			middleware.UseRequestBodyParser(),
+			capture,
+			middleware.UseMemberNames(jsonapi.MemberNames{Canonical: jsonapi.SnakeCase, Wire: jsonapi.CamelCase}),
+		)
+
+		body := strings.NewReader(`{"data":{"type":"articles","id":"1","attributes":{"firstLine":"hello"}}}`)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("PATCH", "https://example.com/articles/1", body))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, map[string]any{"first_line": "hello"}, got.Document.Data.First().Attributes)
+		if assert.NotNil(t, parent) {
+			assert.Equal(t, map[string]any{"firstLine": "hello"}, parent.Data.First().Attributes)
+		}
+	})
+}
+
+func TestExtensions(t *testing.T) {
+	const uri = "https://example.com/ext/version"
+
+	registry, err := jsonapi.NewExtensionRegistry(jsonapi.Extension{
+		URI:       uri,
+		Namespace: "version",
+		Members: []jsonapi.ExtensionMember{
+			jsonapi.NewExtensionMember[string]("id", jsonapi.ScopeDocument|jsonapi.ScopeResource),
+		},
+	})
+	assert.NoError(t, err)
+
+	var got jsonapi.ExtensionValues
+	mux := server.ResourceMux{"articles": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = middleware.ExtensionValuesFromContext(r.Context())
+
+		version := json.RawMessage(`"v1"`)
+		server.Write(w, &jsonapi.Resource{
+			Type:       "articles",
+			ID:         "1",
+			Extensions: jsonapi.ExtensionsNode{"version:id": &version},
+		}, http.StatusOK)
+	})}
+
+	handler := server.Handle(mux,
+		middleware.UseRequestBodyParser(),
+		middleware.UseExtensions(registry),
+	)
+
+	for _, tc := range []struct {
+		name        string
+		contentType string
+		accept      string
+		body        string
+		wantStatus  int
+		wantSource  jsonapi.ErrorSource
+	}{
+		{
+			name:        "applied extension",
+			contentType: `application/vnd.api+json; ext="` + uri + `"`,
+			body:        `{"version:id":"v2","data":{"type":"articles","id":"1","version:id":"v2"}}`,
+			wantStatus:  http.StatusOK,
+		},
+		{
+			name:        "null primary data items",
+			contentType: `application/vnd.api+json; ext="` + uri + `"`,
+			body:        `{"version:id":"v2","data":[null]}`,
+			wantStatus:  http.StatusOK,
+		},
+		{
+			name:        "unsupported content type extension",
+			contentType: `application/vnd.api+json; ext="https://example.com/ext/unknown"`,
+			body:        `{"data":{"type":"articles","id":"1"}}`,
+			wantStatus:  http.StatusUnsupportedMediaType,
+			wantSource:  jsonapi.ErrorSource{Header: "Content-Type"},
+		},
+		{
+			name:       "unsupported accept extension",
+			accept:     `application/vnd.api+json; ext="https://example.com/ext/unknown"`,
+			wantStatus: http.StatusNotAcceptable,
+			wantSource: jsonapi.ErrorSource{Header: "Accept"},
+		},
+		{
+			name:        "extension member not applied",
+			contentType: jsonapi.MediaType,
+			body:        `{"version:id":"v2","data":{"type":"articles","id":"1"}}`,
+			wantStatus:  http.StatusBadRequest,
+			wantSource:  jsonapi.ErrorSource{Pointer: "/version:id"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got = nil
+			method := "GET"
+			if tc.body != "" {
+				method = "PATCH"
+			}
+			req := httptest.NewRequest(method, "https://example.com/articles/1", strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", tc.contentType)
+			req.Header.Set("Accept", tc.accept)
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			res := w.Result()
+			assert.Equal(t, tc.wantStatus, res.StatusCode)
+
+			doc := jsonapi.Document{}
+			assert.NoError(t, jsonapi.Decode(res.Body, &doc))
+
+			if tc.wantStatus != http.StatusOK {
+				assert.Len(t, doc.Errors, 1)
+				assert.Equal(t, tc.wantSource, *doc.Errors[0].Source)
+				return
+			}
+
+			assert.Equal(t, jsonapi.ExtensionValues{"version:id": "v2"}, got)
+			assert.Equal(t, []string{uri}, doc.Jsonapi.Ext)
+			assert.Contains(t, res.Header.Get("Content-Type"), uri)
+		})
+	}
+}
+
+func TestProfiles(t *testing.T) {
+	const timestamps = "https://example.com/profiles/timestamps"
+
+	registry, err := jsonapi.NewProfileRegistry(
+		jsonapi.Profile{
+			URI: timestamps,
+			Decode: func(d *jsonapi.Document) error {
+				if _, ok := d.Data.First().Meta["timestamps"]; ok {
+					return errors.New("timestamps are read-only")
+				}
+				return nil
+			},
+		},
+		pagination.CursorPaginationProfile(),
+	)
+	assert.NoError(t, err)
+
+	var got []string
+	mux := server.ResourceMux{"articles": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = middleware.ProfilesFromContext(r.Context())
+		server.Write(w, []*jsonapi.Resource{{Type: "articles", ID: "1"}}, http.StatusOK)
+	})}
+
+	handler := server.Handle(mux,
+		middleware.UseRequestBodyParser(),
+		middleware.UseProfiles(registry),
+	)
+
+	t.Run("applies requested profiles", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "https://example.com/articles", nil)
+		req.Header.Set("Accept", `application/vnd.api+json; profile="https://example.com/unknown `+pagination.ProfileCursorPagination+`"`)
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		res := w.Result()
+		doc := jsonapi.Document{}
+		assert.NoError(t, jsonapi.Decode(res.Body, &doc))
+
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, []string{pagination.ProfileCursorPagination}, got)
+		assert.Equal(t, []string{pagination.ProfileCursorPagination}, doc.Jsonapi.Profile)
+		assert.Equal(t, pagination.ProfileCursorPagination, doc.Links[jsonapi.LinkProfile].Href)
+		assert.Contains(t, doc.Links, pagination.LinkNext)
+		assert.Contains(t, res.Header.Get("Content-Type"), pagination.ProfileCursorPagination)
+		assert.NotContains(t, res.Header.Get("Content-Type"), "unknown")
+	})
+
+	t.Run("rejects documents that do not follow applied profiles", func(t *testing.T) {
+		body := strings.NewReader(`{"data":{"type":"articles","id":"1","meta":{"timestamps":{}}}}`)
+		req := httptest.NewRequest("PATCH", "https://example.com/articles/1", body)
+		req.Header.Set("Content-Type", `application/vnd.api+json; profile="`+timestamps+`"`)
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
+
+	t.Run("ignores unknown profiles", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "https://example.com/articles", nil)
+		req.Header.Set("Accept", `application/vnd.api+json; profile="https://example.com/unknown"`)
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		doc := jsonapi.Document{}
+		assert.NoError(t, jsonapi.Decode(w.Result().Body, &doc))
+
+		assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+		assert.Empty(t, got)
+		assert.Empty(t, doc.Jsonapi.Profile)
+	})
+}
+
+func TestAccessLog(t *testing.T) {
+	mux := server.ResourceMux{"articles": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := jsonapi.FromContext(r.Context())
+		if ctx.ResourceID == "missing" {
+			server.Error(w, jsonapi.Error{Code: "not_found", Status: "404"}, http.StatusNotFound)
+			return
+		}
+		server.Write(w, &jsonapi.Resource{Type: "articles", ID: ctx.ResourceID}, http.StatusOK)
+	})}
+
+	for _, tc := range []struct {
+		name      string
+		target    string
+		wantLevel string
+		wantAttrs map[string]any
+	}{
+		{
+			name:      "successful request",
+			target:    "https://example.com/articles/1",
+			wantLevel: "INFO",
+			wantAttrs: map[string]any{
+				"method":  "GET",
+				"status":  float64(http.StatusOK),
+				"jsonapi": map[string]any{"type": "articles", "id": "1"},
+			},
+		},
+		{
+			name:      "error response",
+			target:    "https://example.com/articles/missing?include=author",
+			wantLevel: "WARN",
+			wantAttrs: map[string]any{
+				"status": float64(http.StatusNotFound),
+				"errors": []any{"not_found"},
+				"jsonapi": map[string]any{
+					"type":    "articles",
+					"id":      "missing",
+					"include": []any{"author"},
+				},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+			handler := server.Handle(mux,
+				server.WithLogger(logger),
+				middleware.UseIncludeQueryParser(),
+				middleware.UseAccessLog(nil),
+			)
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", tc.target, nil))
+
+			entry := map[string]any{}
+			assert.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+			assert.Equal(t, tc.wantLevel, entry["level"])
+			assert.Contains(t, entry, "duration")
+			for key, want := range tc.wantAttrs {
+				assert.Equal(t, want, entry[key], key)
+			}
+		})
+	}
+}
+
+func TestInstrumentedMiddleware(t *testing.T) {
+	instrumentation := &servertest.Instrumentation{}
+	s := newStore()
+
+	handler := server.Handle(s.mux(),
+		server.WithInstrumentation(instrumentation),
+		middleware.UseSortQueryParser(sortparser.DefaultParser),
+		middleware.UseIncludeQueryParser(),
+		middleware.UseIncludedResourceResolver(),
+	)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "https://example.com/articles?include=author&sort=id", nil))
+	assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+
+	handle := instrumentation.Spans(server.SpanHandle)
+	assert.Len(t, handle, 1, "sub-requests are not instrumented as requests")
+
+	families := make([]any, 0)
+	for _, span := range instrumentation.Spans(server.SpanParseQuery) {
+		assert.True(t, span.Ended)
+		assert.Same(t, handle[0], span.Parent)
+		families = append(families, span.Attrs["family"])
+	}
+	assert.Equal(t, []any{"sort", "include"}, families)
+
+	include := instrumentation.Spans(server.SpanInclude)
+	if assert.Len(t, include, 1) {
+		assert.Same(t, handle[0], include[0].Parent)
+		assert.Equal(t, "people", include[0].Attrs["type"])
+		assert.Equal(t, int64(2), include[0].Attrs["resources"])
+	}
+
+	fetches := instrumentation.Measurements(server.MetricIncludeFetches)
+	if assert.Len(t, fetches, 1) {
+		assert.Equal(t, map[string]any{"type": "people", "source": "request"}, fetches[0].Attrs)
+	}
+}
+
+func TestIdempotencyKeys(t *testing.T) {
+	type fixture struct {
+		handler http.Handler
+		creates int
+		release chan struct{}
+		mu      sync.Mutex
+	}
+
+	newFixture := func(options ...server.Options) *fixture {
+		f := &fixture{}
+		create := func(w http.ResponseWriter, r *http.Request) {
+			if f.release != nil {
+				<-f.release
+			}
+			body, _ := io.ReadAll(r.Body)
+			if strings.Contains(string(body), "panic") {
+				panic("create failed")
+			}
+			if strings.Contains(string(body), "fail") {
+				server.Error(w, errors.New("unavailable"), http.StatusServiceUnavailable)
+				return
+			}
+
+			f.mu.Lock()
+			f.creates++
+			id := fmt.Sprint(f.creates)
+			f.mu.Unlock()
+
+			w.Header().Set(server.HeaderKeyLocation, "/orders/"+id)
+			server.Write(w, &jsonapi.Resource{Type: "orders", ID: id}, http.StatusCreated)
+		}
+		f.handler = server.Handle(server.ResourceMux{
+			"orders": server.Resource{
+				Create: http.HandlerFunc(create),
+				Relationships: server.Relationship{
+					AddRef: http.HandlerFunc(create),
+				},
+			},
+		}, options...)
+		return f
+	}
+
+	post := func(f *fixture, target, key, body string, headers ...string) *http.Response {
+		req := httptest.NewRequest("POST", target, strings.NewReader(body))
+		if key != "" {
+			req.Header.Set(middleware.HeaderIdempotencyKey, key)
+		}
+		for i := 0; i+1 < len(headers); i += 2 {
+			req.Header.Set(headers[i], headers[i+1])
+		}
+		w := httptest.NewRecorder()
+		f.handler.ServeHTTP(w, req)
+		return w.Result()
+	}
+
+	const order = `{"data": {"type": "orders", "attributes": {"total": 10}}}`
+
+	t.Run("replays responses", func(t *testing.T) {
+		f := newFixture(middleware.UseIdempotencyKeys(middleware.NewMemoryIdempotencyStore()))
+
+		first := post(f, "/orders", "key-1", order)
+		assert.Equal(t, http.StatusCreated, first.StatusCode)
+		assert.Empty(t, first.Header.Get(middleware.HeaderIdempotentReplayed))
+		firstBody, _ := io.ReadAll(first.Body)
+
+		retry := post(f, "/orders", "key-1", order)
+		assert.Equal(t, http.StatusCreated, retry.StatusCode)
+		assert.Equal(t, "true", retry.Header.Get(middleware.HeaderIdempotentReplayed))
+		assert.Equal(t, "/orders/1", retry.Header.Get(server.HeaderKeyLocation))
+		retryBody, _ := io.ReadAll(retry.Body)
+		assert.JSONEq(t, string(firstBody), string(retryBody))
+		assert.Equal(t, 1, f.creates)
+
+		assert.Equal(t, http.StatusCreated, post(f, "/orders", "key-2", order).StatusCode)
+		assert.Equal(t, http.StatusCreated, post(f, "/orders", "", order).StatusCode)
+		assert.Equal(t, 3, f.creates)
+	})
+
+	t.Run("rejects reused keys", func(t *testing.T) {
+		f := newFixture(middleware.UseIdempotencyKeys(middleware.NewMemoryIdempotencyStore()))
+
+		assert.Equal(t, http.StatusCreated, post(f, "/orders", "key", order).StatusCode)
+
+		res := post(f, "/orders", "key", `{"data": {"type": "orders", "attributes": {"total": 20}}}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+		doc := jsonapi.Document{}
+		assert.NoError(t, jsonapi.Decode(res.Body, &doc))
+		assert.Equal(t, middleware.HeaderIdempotencyKey, doc.Errors[0].Source.Header)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, post(f, "/orders/1/relationships/items", "key", order).StatusCode)
+		assert.Equal(t, 1, f.creates)
+	})
+
+	t.Run("rejects concurrent requests", func(t *testing.T) {
+		f := newFixture(middleware.UseIdempotencyKeys(middleware.NewMemoryIdempotencyStore()))
+		f.release = make(chan struct{})
+
+		done := make(chan *http.Response)
+		go func() { done <- post(f, "/orders", "key", order) }()
+
+		assert.Eventually(t, func() bool {
+			return post(f, "/orders", "key", order).StatusCode == http.StatusConflict
+		}, time.Second, time.Millisecond)
+
+		close(f.release)
+		assert.Equal(t, http.StatusCreated, (<-done).StatusCode)
+		assert.Equal(t, http.StatusCreated, post(f, "/orders", "key", order).StatusCode)
+		assert.Equal(t, 1, f.creates)
+	})
+
+	t.Run("does not record server errors", func(t *testing.T) {
+		f := newFixture(middleware.UseIdempotencyKeys(middleware.NewMemoryIdempotencyStore()))
+
+		const failing = `{"data": {"type": "orders", "attributes": {"note": "fail"}}}`
+		assert.Equal(t, http.StatusServiceUnavailable, post(f, "/orders", "key", failing).StatusCode)
+		assert.Equal(t, http.StatusServiceUnavailable, post(f, "/orders", "key", failing).StatusCode)
+		assert.Equal(t, http.StatusCreated, post(f, "/orders", "key", order).StatusCode)
+	})
+
+	t.Run("releases keys when the handler panics", func(t *testing.T) {
+		f := newFixture(
+			middleware.UseRecovery(middleware.LoggerFunc(func(string, ...any) {})),
+			middleware.UseIdempotencyKeys(middleware.NewMemoryIdempotencyStore()),
+		)
+
+		const panicking = `{"data": {"type": "orders", "attributes": {"note": "panic"}}}`
+		assert.Equal(t, http.StatusInternalServerError, post(f, "/orders", "key", panicking).StatusCode)
+		assert.Equal(t, http.StatusInternalServerError, post(f, "/orders", "key", panicking).StatusCode)
+	})
+
+	t.Run("limits request bodies", func(t *testing.T) {
+		f := newFixture(
+			server.WithLimits(server.Limits{MaxBodyBytes: 16}),
+			middleware.UseIdempotencyKeys(middleware.NewMemoryIdempotencyStore()),
+		)
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, post(f, "/orders", "key", order).StatusCode)
+		assert.Equal(t, 0, f.creates)
+	})
+
+	t.Run("scopes keys", func(t *testing.T) {
+		f := newFixture(middleware.UseIdempotencyKeys(middleware.NewMemoryIdempotencyStore(),
+			middleware.WithIdempotencyScope(func(r *http.Request) string { return r.Header.Get("X-Principal") })))
+
+		assert.Equal(t, http.StatusCreated, post(f, "/orders", "key", order, "X-Principal", "alice").StatusCode)
+		res := post(f, "/orders", "key", order, "X-Principal", "bob")
+		assert.Equal(t, http.StatusCreated, res.StatusCode)
+		assert.Empty(t, res.Header.Get(middleware.HeaderIdempotentReplayed))
+
+		res = post(f, "/orders", "key", order, "X-Principal", "alice")
+		assert.Equal(t, "true", res.Header.Get(middleware.HeaderIdempotentReplayed))
+		assert.Equal(t, 2, f.creates)
+	})
+
+	t.Run("expires keys", func(t *testing.T) {
+		f := newFixture(middleware.UseIdempotencyKeys(middleware.NewMemoryIdempotencyStore(),
+			middleware.WithIdempotencyTTL(time.Millisecond)))
+
+		assert.Equal(t, http.StatusCreated, post(f, "/orders", "key", order).StatusCode)
+		time.Sleep(5 * time.Millisecond)
+		res := post(f, "/orders", "key", order)
+		assert.Equal(t, http.StatusCreated, res.StatusCode)
+		assert.Empty(t, res.Header.Get(middleware.HeaderIdempotentReplayed))
+		assert.Equal(t, 2, f.creates)
+	})
+
+	t.Run("expires reservations", func(t *testing.T) {
+		store := middleware.NewMemoryIdempotencyStore()
+		f := newFixture(middleware.UseIdempotencyKeys(store, middleware.WithIdempotencyLockTTL(time.Millisecond)))
+
+		// the reservation of a request that was never completed, e.g. because the server stopped.
+		_, reserved, err := store.Reserve(context.Background(), "key", "fingerprint", time.Millisecond)
+		assert.NoError(t, err)
+		assert.True(t, reserved)
+		assert.Equal(t, http.StatusUnprocessableEntity, post(f, "/orders", "key", order).StatusCode)
+
+		time.Sleep(5 * time.Millisecond)
+		assert.Equal(t, http.StatusCreated, post(f, "/orders", "key", order).StatusCode)
+		res := post(f, "/orders", "key", order)
+		assert.Equal(t, "true", res.Header.Get(middleware.HeaderIdempotentReplayed))
+		assert.Equal(t, 1, f.creates)
+	})
+
+	t.Run("replays add ref responses", func(t *testing.T) {
+		f := newFixture(middleware.UseIdempotencyKeys(middleware.NewMemoryIdempotencyStore()))
+
+		const ref = `{"data": [{"type": "items", "id": "1"}]}`
+		assert.Equal(t, http.StatusCreated, post(f, "/orders/1/relationships/items", "key", ref).StatusCode)
+		res := post(f, "/orders/1/relationships/items", "key", ref)
+		assert.Equal(t, "true", res.Header.Get(middleware.HeaderIdempotentReplayed))
+		assert.Equal(t, 1, f.creates)
+	})
+
+	t.Run("fingerprints raw bodies", func(t *testing.T) {
+		const reordered = `{"data": {"attributes": {"total": 10}, "type": "orders"}}`
+		for _, options := range [][]server.Options{
+			{middleware.UseRequestBodyParser(), middleware.UseIdempotencyKeys(middleware.NewMemoryIdempotencyStore())},
+			{middleware.UseIdempotencyKeys(middleware.NewMemoryIdempotencyStore()), middleware.UseRequestBodyParser()},
+		} {
+			f := newFixture(options...)
+			assert.Equal(t, http.StatusCreated, post(f, "/orders", "key", order).StatusCode)
+			res := post(f, "/orders", "key", order)
+			assert.Equal(t, "true", res.Header.Get(middleware.HeaderIdempotentReplayed))
+			assert.Equal(t, http.StatusUnprocessableEntity, post(f, "/orders", "key", reordered).StatusCode)
+			assert.Equal(t, http.StatusUnprocessableEntity, post(f, "/orders", "key", `{"data": {"type": "orders"}}`).StatusCode)
+		}
+	})
+}
+
+type filterParserFunc func(*http.Request) (query.FilterExpression, error)
+
+func (fn filterParserFunc) ParseFilterQuery(r *http.Request) (query.FilterExpression, error) {
+	return fn(r)
+}
+
+func TestLimits(t *testing.T) {
+	limits := server.Limits{
+		MaxBodyBytes:    256,
+		MaxResources:    2,
+		MaxIncludeDepth: 2,
+		MaxIncludePaths: 2,
+		MaxFilterBytes:  32,
+		MaxFilterNodes:  3,
+		MaxPageSize:     10,
+		MaxSortKeys:     2,
+	}
+
+	// the filter expression is a conjunction of the "f" query parameters.
+	filters := filterParserFunc(func(r *http.Request) (query.FilterExpression, error) {
+		var expr query.FilterExpression
+		for _, name := range r.URL.Query()["f"] {
+			f := &query.Filter{Name: name, Condition: string(query.Equal), Value: "1"}
+			if expr == nil {
+				expr = f
+			} else {
+				expr = &query.AndFilter{Left: expr, Right: f}
+			}
+		}
+		return expr, nil
+	})
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		server.Write(w, jsonapi.NewMultiDocument(), http.StatusOK)
+	})
+
+	newHandler := func(limits server.Limits) http.Handler {
+		return server.Handle(server.ResourceMux{"items": ok},
+			server.WithLimits(limits),
+			middleware.UseRequestBodyParser(),
+			middleware.UseIncludeQueryParser(),
+			middleware.UseFilterQueryParser(filters),
+			middleware.UsePageQueryParser(page.DefaultPageParser),
+			middleware.UseSortQueryParser(sortparser.DefaultParser),
+		)
+	}
+
+	resources := func(n int) string {
+		items := make([]string, n)
+		for i := range items {
+			items[i] = fmt.Sprintf(`{"type": "items", "id": "%d"}`, i)
+		}
+		return `{"data": [` + strings.Join(items, ",") + `]}`
+	}
+
+	for _, tc := range []struct {
+		name        string
+		method      string
+		target      string
+		body        string
+		wantStatus  int
+		wantParam   string
+		wantPointer string
+	}{
+		{name: "within limits", method: "GET", target: "/items?include=a.b,c&sort=a,-b&page[limit]=10&f=a&f=b", wantStatus: http.StatusOK},
+		{name: "body within limits", method: "POST", target: "/items", body: resources(2), wantStatus: http.StatusOK},
+		{name: "body too large", method: "POST", target: "/items", body: `{"data": {"type": "items", "attributes": {"name": "` + strings.Repeat("a", 256) + `"}}}`, wantStatus: http.StatusRequestEntityTooLarge},
+		{name: "too many resources", method: "POST", target: "/items", body: resources(3), wantStatus: http.StatusRequestEntityTooLarge, wantPointer: "/data"},
+		{name: "include too deep", method: "GET", target: "/items?include=a.b.c", wantStatus: http.StatusBadRequest, wantParam: "include"},
+		{name: "too many includes", method: "GET", target: "/items?include=a,b,c", wantStatus: http.StatusBadRequest, wantParam: "include"},
+		{name: "filter too large", method: "GET", target: "/items?f=a&f=b&f=c", wantStatus: http.StatusBadRequest, wantParam: "filter"},
+		{name: "filter too long", method: "GET", target: "/items?filter=" + strings.Repeat("a", 32), wantStatus: http.StatusBadRequest, wantParam: "filter"},
+		{name: "page too large", method: "GET", target: "/items?page[limit]=11", wantStatus: http.StatusBadRequest, wantParam: "page[limit]"},
+		{name: "too many sort keys", method: "GET", target: "/items?sort=a,b,c", wantStatus: http.StatusBadRequest, wantParam: "sort"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			newHandler(limits).ServeHTTP(w, httptest.NewRequest(tc.method, tc.target, strings.NewReader(tc.body)))
+			assert.Equal(t, tc.wantStatus, w.Code)
+
+			if tc.wantStatus == http.StatusOK {
+				return
+			}
+
+			doc := jsonapi.Document{}
+			assert.NoError(t, jsonapi.Decode(w.Body, &doc))
+			assert.Len(t, doc.Errors, 1)
+			assert.Equal(t, fmt.Sprint(tc.wantStatus), doc.Errors[0].Status)
+			if tc.wantParam != "" || tc.wantPointer != "" {
+				assert.Equal(t, tc.wantParam, doc.Errors[0].Source.Parameter)
+				assert.Equal(t, tc.wantPointer, doc.Errors[0].Source.Pointer)
+			}
+		})
+	}
+
+	t.Run("reports the page size parameter", func(t *testing.T) {
+		paginator := pagination.New(pagination.CursorStrategy{})
+		for _, option := range []server.Options{
+			middleware.UsePageQueryParser(paginator),
+			middleware.UseQueryParameters(middleware.WithPageParser(paginator)),
+		} {
+			handler := server.Handle(server.ResourceMux{"items": ok}, server.WithLimits(limits), option)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest("GET", "/items?page[size]=11", nil))
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+
+			doc := jsonapi.Document{}
+			assert.NoError(t, jsonapi.Decode(w.Body, &doc))
+			if assert.Len(t, doc.Errors, 1) {
+				assert.Equal(t, "page[size]", doc.Errors[0].Source.Parameter)
+			}
+		}
+	})
+
+	t.Run("enforces no limits by default", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		target := "/items?include=a.b.c.d,e,f&sort=a,b,c&page[limit]=1000&f=a&f=b&f=c"
+		newHandler(server.Limits{}).ServeHTTP(w, httptest.NewRequest("POST", target, strings.NewReader(resources(10))))
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+}
+
+func TestQueryParameters(t *testing.T) {
+	var got *jsonapi.RequestContext
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = jsonapi.FromContext(r.Context())
+		server.Write(w, jsonapi.NewMultiDocument(), http.StatusOK)
+	})
+
+	handler := server.Handle(server.ResourceMux{"items": ok},
+		server.WithLimits(server.Limits{MaxSortKeys: 2, MaxFilterBytes: 128}),
+		middleware.UseQueryParameters(
+			middleware.WithFieldsetParser(fieldset.DefaultParser),
+			middleware.WithPageParser(page.DefaultPageParser),
+			middleware.WithFilterParser(filter.DefaultParser),
+			middleware.WithSortParser(sortparser.DefaultParser),
+			middleware.WithIncludeParser(),
+			middleware.WithQueryFamilies("x-custom"),
+		),
+	)
+
+	serve := func(target string) *httptest.ResponseRecorder {
+		got = nil
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+		return w
+	}
+
+	errorParams := func(t *testing.T, w *httptest.ResponseRecorder) []string {
+		doc := jsonapi.Document{}
+		assert.NoError(t, jsonapi.Decode(w.Body, &doc))
+		params := make([]string, 0, len(doc.Errors))
+		for _, err := range doc.Errors {
+			assert.Equal(t, "400", err.Status)
+			params = append(params, err.Source.Parameter)
+		}
+		sort.Strings(params)
+		return params
+	}
+
+	t.Run("parses every family", func(t *testing.T) {
+		w := serve("/items?include=author&sort=-name&page[limit]=5&fields[items]=name" +
+			"&q=p01&filter[p01][name]=name&filter[p01][condition]=eq&filter[p01][value]=a&x-custom[a]=1")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, []string{"author"}, got.Include)
+		assert.Equal(t, 5, got.Pagination.Limit)
+		assert.Len(t, got.Sort, 1)
+		assert.Equal(t, []query.Fieldset{{Property: "items"}}, got.Fields)
+		assert.Equal(t, &query.Filter{Name: "name", Condition: "eq", Value: "a"}, got.Filter)
+	})
+
+	t.Run("skips absent families", func(t *testing.T) {
+		w := serve("/items")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Nil(t, got.Filter)
+		assert.Nil(t, got.Include)
+	})
+
+	t.Run("reports every error", func(t *testing.T) {
+		w := serve("/items?page[limit]=ten&sort=a,b,c&foo=bar&camelCase=1&bad%21=1&-dash=1")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Nil(t, got)
+		assert.Equal(t, []string{"-dash", "bad!", "camelCase", "foo", "page[limit]", "sort"}, errorParams(t, w))
+	})
+
+	t.Run("references the family of parse errors", func(t *testing.T) {
+		w := serve("/items?q=p01")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, []string{"filter"}, errorParams(t, w))
+	})
+
+	t.Run("validates implementation-specific families", func(t *testing.T) {
+		assert.Panics(t, func() { middleware.WithQueryFamilies("custom") })
+		assert.Panics(t, func() { middleware.WithQueryFamilies("bad!") })
+		assert.NotPanics(t, func() { middleware.WithQueryFamilies("x-custom", "camelCase") })
+	})
+
+	t.Run("rejects long filters before parsing", func(t *testing.T) {
+		w := serve("/items?q=p01" + strings.Repeat("+or+p01", 20) +
+			"&filter[p01][name]=name&filter[p01][condition]=eq&filter[p01][value]=a")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, []string{"filter"}, errorParams(t, w))
+	})
+
+	t.Run("parses registered families", func(t *testing.T) {
+		stats := jsonapi.NewQueryFamily("itemStats",
+			func(params url.Values) ([]string, error) {
+				if params.Get("itemStats[total]") == "" {
+					return nil, errors.New("itemStats[total] is required")
+				}
+				return strings.Split(params.Get("itemStats[total]"), ","), nil
+			},
+			func(value []string, params url.Values) {
+				params.Set("itemStats[total]", strings.Join(value, ","))
+			},
+		)
+		registry, err := jsonapi.NewQueryFamilyRegistry(stats)
+		assert.NoError(t, err)
+
+		handler := server.Handle(server.ResourceMux{"items": ok},
+			middleware.UseQueryParameters(middleware.WithQueryFamilyRegistry(registry)))
+
+		got = nil
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "/items?itemStats[total]=count,sum", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		value, _ := jsonapi.QueryValue[[]string](got.Query, "itemStats")
+		assert.Equal(t, []string{"count", "sum"}, value)
+
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "/items?itemStats[avg]=x", nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, []string{"itemStats"}, errorParams(t, w))
+	})
+}
@@ -1,11 +1,15 @@
 package middleware
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 
 	"github.com/gonobo/jsonapi/v2"
@@ -13,23 +17,61 @@ import (
 	"github.com/gonobo/jsonapi/v2/server"
 )
 
+// UseRequestBodyParser is a middleware that parses the request document and stores it within
+// the JSON:API request context. Request bodies larger than the limit's MaxBodyBytes, and
+// documents with more resources than its MaxResources, are rejected with a 413 Content Too
+// Large error (see [server.WithLimits]). The raw request body remains readable downstream.
 func UseRequestBodyParser() server.Options {
 	return server.WithMiddleware(func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			defer r.Body.Close()
 
+			limits := server.LimitsFromContext(r.Context())
+			if limits.MaxBodyBytes > 0 {
+				if r.ContentLength > limits.MaxBodyBytes {
+					writeBodyTooLarge(w, limits.MaxBodyBytes)
+					return
+				}
+				r.Body = http.MaxBytesReader(w, r.Body, limits.MaxBodyBytes)
+			}
+
 			document := jsonapi.Document{}
 
-			if err := json.NewDecoder(r.Body).Decode(&document); errors.Is(err, io.EOF) {
+			_, span := server.StartSpan(r.Context(), server.SpanParseDocument)
+			body, err := io.ReadAll(r.Body)
+			if err == nil {
+				err = json.NewDecoder(bytes.NewReader(body)).Decode(&document)
+			}
+			empty := errors.Is(err, io.EOF)
+			if empty {
+				err = nil
+			}
+			server.EndSpan(span, err)
+
+			// restore the raw body for downstream handlers.
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			var tooLarge *http.MaxBytesError
+			if empty {
 				// no document inside the payload; execute the next handler
 				next.ServeHTTP(w, r)
 				return
+			} else if errors.As(err, &tooLarge) {
+				writeBodyTooLarge(w, limits.MaxBodyBytes)
+				return
 			} else if err != nil {
 				// document parsing failed; return error to client
 				server.Error(w, fmt.Errorf("request document error: %w", err), http.StatusBadRequest)
 				return
 			}
 
+			if count := documentResources(&document); limits.MaxResources > 0 && count > limits.MaxResources {
+				server.Error(w, *limitError(http.StatusRequestEntityTooLarge, &jsonapi.ErrorSource{Pointer: "/data"},
+					"request document contains %d resources, exceeding the maximum of %d", count, limits.MaxResources),
+					http.StatusRequestEntityTooLarge)
+				return
+			}
+
 			// save document to context and continue
 			ctx := jsonapi.FromContext(r.Context())
 			ctx.Document = &document
@@ -48,7 +90,9 @@ type FieldsetQueryParser interface {
 func UseFieldsetQueryParser(parser FieldsetQueryParser) server.Options {
 	return server.WithMiddleware(func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, span := server.StartSpan(r.Context(), server.SpanParseQuery, slog.String("family", "fields"))
 			fields, err := parser.ParseFieldsetQuery(r)
+			server.EndSpan(span, err)
 
 			if err != nil {
 				server.Error(w, fmt.Errorf("failed to parse fieldset params: %s", err), http.StatusBadRequest)
@@ -73,13 +117,20 @@ type PageQueryParser interface {
 func UsePageQueryParser(parser PageQueryParser) server.Options {
 	return server.WithMiddleware(func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, span := server.StartSpan(r.Context(), server.SpanParseQuery, slog.String("family", "page"))
 			page, err := parser.ParsePageQuery(r)
+			server.EndSpan(span, err)
 
 			if err != nil {
 				server.Error(w, fmt.Errorf("sort: failed to parse query params: %w", err), http.StatusBadRequest)
 				return
 			}
 
+			if err := pageLimitError(page, server.LimitsFromContext(r.Context()), limitParameter(parser)); err != nil {
+				server.Error(w, *err, http.StatusBadRequest)
+				return
+			}
+
 			ctx := jsonapi.FromContext(r.Context())
 			ctx.Pagination = page
 
@@ -96,17 +147,32 @@ type FilterQueryParser interface {
 
 // UseFilterQueryParser is a middleware that parses and extracts any filter parameters in the
 // request query and generates a filter expression stored within
-// the JSON:API request context.
+// the JSON:API request context. Filter parameters longer than the limit's MaxFilterBytes are
+// rejected before they are parsed.
 func UseFilterQueryParser(parser FilterQueryParser) server.Options {
+	families := filterFamilies(parser)
 	return server.WithMiddleware(func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			limits := server.LimitsFromContext(r.Context())
+			if err := filterBytesLimitError(r.URL.Query(), families, limits); err != nil {
+				server.Error(w, *err, http.StatusBadRequest)
+				return
+			}
+
+			_, span := server.StartSpan(r.Context(), server.SpanParseQuery, slog.String("family", "filter"))
 			filter, err := parser.ParseFilterQuery(r)
+			server.EndSpan(span, err)
 
 			if err != nil {
 				server.Error(w, fmt.Errorf("failed to parse filter params: %s", err), http.StatusBadRequest)
 				return
 			}
 
+			if err := filterLimitError(filter, limits); err != nil {
+				server.Error(w, *err, http.StatusBadRequest)
+				return
+			}
+
 			ctx := jsonapi.FromContext(r.Context())
 			ctx.Filter = filter
 			next.ServeHTTP(w, jsonapi.RequestWithContext(r, ctx))
@@ -125,13 +191,20 @@ type SortQueryParser interface {
 func UseSortQueryParser(parser SortQueryParser) server.Options {
 	return server.WithMiddleware(func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, span := server.StartSpan(r.Context(), server.SpanParseQuery, slog.String("family", "sort"))
 			sort, err := parser.ParseSortQuery(r)
+			server.EndSpan(span, err)
 
 			if err != nil {
 				server.Error(w, fmt.Errorf("sort: failed to parse query params: %w", err), http.StatusBadRequest)
 				return
 			}
 
+			if err := sortLimitError(sort, server.LimitsFromContext(r.Context())); err != nil {
+				server.Error(w, *err, http.StatusBadRequest)
+				return
+			}
+
 			ctx := jsonapi.FromContext(r.Context())
 			ctx.Sort = sort
 
@@ -145,10 +218,136 @@ func UseSortQueryParser(parser SortQueryParser) server.Options {
 func UseIncludeQueryParser() server.Options {
 	return server.WithMiddleware(func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, span := server.StartSpan(r.Context(), server.SpanParseQuery, slog.String("family", "include"))
 			include := strings.Split(r.URL.Query().Get(query.ParamInclude), ",")
+			span.End()
+
+			if err := includeLimitError(include, server.LimitsFromContext(r.Context())); err != nil {
+				server.Error(w, *err, http.StatusBadRequest)
+				return
+			}
 			ctx := jsonapi.FromContext(r.Context())
 			ctx.Include = include
 			next.ServeHTTP(w, jsonapi.RequestWithContext(r, ctx))
 		})
 	})
 }
+
+// paramFilter is the family of filter query parameters.
+const paramFilter = "filter"
+
+// limitError returns a JSON:API error reporting that the request exceeds a limit.
+func limitError(status int, source *jsonapi.ErrorSource, format string, args ...any) *jsonapi.Error {
+	return &jsonapi.Error{
+		Status: strconv.Itoa(status),
+		Title:  http.StatusText(status),
+		Detail: fmt.Sprintf(format, args...),
+		Source: source,
+	}
+}
+
+func writeBodyTooLarge(w http.ResponseWriter, max int64) {
+	server.Error(w, *limitError(http.StatusRequestEntityTooLarge, nil,
+		"request body exceeds the maximum of %d bytes", max), http.StatusRequestEntityTooLarge)
+}
+
+// documentResources returns the number of resources in the document's primary data and
+// included resources.
+func documentResources(doc *jsonapi.Document) int {
+	count := len(doc.Included)
+	if doc.Data != nil {
+		count += len(doc.Data.Items())
+	}
+	return count
+}
+
+// includeLimitError returns an error if the include paths exceed the limits.
+func includeLimitError(include []string, limits server.Limits) *jsonapi.Error {
+	paths := 0
+	for _, path := range include {
+		if path == "" {
+			continue
+		}
+		paths++
+
+		if depth := strings.Count(path, ".") + 1; limits.MaxIncludeDepth > 0 && depth > limits.MaxIncludeDepth {
+			return limitError(http.StatusBadRequest, &jsonapi.ErrorSource{Parameter: query.ParamInclude},
+				"include path %q exceeds the maximum depth of %d", path, limits.MaxIncludeDepth)
+		}
+	}
+
+	if limits.MaxIncludePaths > 0 && paths > limits.MaxIncludePaths {
+		return limitError(http.StatusBadRequest, &jsonapi.ErrorSource{Parameter: query.ParamInclude},
+			"%d include paths exceed the maximum of %d", paths, limits.MaxIncludePaths)
+	}
+	return nil
+}
+
+// pageLimitError returns an error if the page limit exceeds the limits. The error references
+// the query parameter the limit was read from.
+func pageLimitError(page query.Page, limits server.Limits, param string) *jsonapi.Error {
+	if limits.MaxPageSize > 0 && page.Limit > limits.MaxPageSize {
+		return limitError(http.StatusBadRequest, &jsonapi.ErrorSource{Parameter: param},
+			"page limit %d exceeds the maximum of %d", page.Limit, limits.MaxPageSize)
+	}
+	return nil
+}
+
+// limitParameter returns the name of the query parameter the page parser reads the limit from.
+func limitParameter(parser PageQueryParser) string {
+	if p, ok := parser.(PageLimitParameter); ok {
+		return p.LimitParameter()
+	}
+	return query.ParamPageLimit
+}
+
+// filterFamilies returns the query parameter families read by the filter parser.
+func filterFamilies(parser FilterQueryParser) map[string]bool {
+	families := map[string]bool{paramFilter: true}
+	if p, ok := parser.(QueryFamilies); ok {
+		for _, family := range p.QueryFamilies() {
+			families[family] = true
+		}
+	}
+	return families
+}
+
+// filterBytesLimitError returns an error if the raw parameters of the filter families exceed
+// the limits. It is checked before parsing, bounding the work of the filter parser.
+func filterBytesLimitError(params url.Values, families map[string]bool, limits server.Limits) *jsonapi.Error {
+	if limits.MaxFilterBytes <= 0 {
+		return nil
+	}
+	size := 0
+	for key, values := range params {
+		if !families[queryFamily(key)] {
+			continue
+		}
+		for _, value := range values {
+			size += len(key) + len(value)
+		}
+	}
+	if size > limits.MaxFilterBytes {
+		return limitError(http.StatusBadRequest, &jsonapi.ErrorSource{Parameter: paramFilter},
+			"filter parameters exceed the maximum of %d bytes", limits.MaxFilterBytes)
+	}
+	return nil
+}
+
+// filterLimitError returns an error if the filter expression exceeds the limits.
+func filterLimitError(filter query.FilterExpression, limits server.Limits) *jsonapi.Error {
+	if count := query.CountFilterNodes(filter); limits.MaxFilterNodes > 0 && count > limits.MaxFilterNodes {
+		return limitError(http.StatusBadRequest, &jsonapi.ErrorSource{Parameter: paramFilter},
+			"filter expression contains %d nodes, exceeding the maximum of %d", count, limits.MaxFilterNodes)
+	}
+	return nil
+}
+
+// sortLimitError returns an error if the sort criteria exceed the limits.
+func sortLimitError(sort []query.Sort, limits server.Limits) *jsonapi.Error {
+	if limits.MaxSortKeys > 0 && len(sort) > limits.MaxSortKeys {
+		return limitError(http.StatusBadRequest, &jsonapi.ErrorSource{Parameter: query.ParamSort},
+			"%d sort criteria exceed the maximum of %d", len(sort), limits.MaxSortKeys)
+	}
+	return nil
+}
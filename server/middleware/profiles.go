@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gonobo/jsonapi/v2"
+	"github.com/gonobo/jsonapi/v2/server"
+)
+
+// ProfilesFromContext returns the URIs of the supported profiles requested by the client,
+// as negotiated by [UseProfiles].
+func ProfilesFromContext(ctx context.Context) []string {
+	profiles, _ := ctx.Value(profilesContextKey).([]string)
+	return profiles
+}
+
+// UseProfiles is a middleware that negotiates the profiles in the registry with clients.
+// It must be applied after [UseRequestBodyParser], which stores the request document in
+// the context.
+//
+// Clients apply profiles to request documents, and request profiles for response documents,
+// with the "profile" media type parameter of the "Content-Type" and "Accept" headers.
+// Unsupported profiles are ignored. The hooks of the profiles applied to the request
+// document are invoked with it; documents that do not follow their profiles are rejected with
+// 400 Bad Request. The supported profiles requested by the client can be retrieved downstream
+// with [ProfilesFromContext].
+//
+// The requested profiles are applied to the response document: they are listed in its
+// "jsonapi" member, referenced by its "profile" link, and added to the "profile" parameter
+// of the "Content-Type" header.
+func UseProfiles(registry *jsonapi.ProfileRegistry) server.Options {
+	return server.WithMiddleware(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			applied := make([]string, 0)
+			for _, uris := range mediaTypeParams(r.Header.Get("Content-Type"), "profile") {
+				applied = append(applied, uris...)
+			}
+
+			ctx := jsonapi.FromContext(r.Context())
+			if ctx.Document != nil {
+				if err := registry.DecodeDocument(ctx.Document, applied); err != nil {
+					server.Error(w, err, http.StatusBadRequest)
+					return
+				}
+			}
+
+			requested := applied
+			for _, uris := range mediaTypeParams(r.Header.Get("Accept"), "profile") {
+				requested = append(requested, uris...)
+			}
+			requested = registry.Supported(requested)
+			r = r.WithContext(context.WithValue(r.Context(), profilesContextKey, requested))
+
+			mem := server.NewRecorder()
+			next.ServeHTTP(mem, r)
+
+			if mem.Document != nil {
+				uris, err := registry.ApplyProfiles(mem.Document, requested)
+				if err != nil {
+					server.Error(w, err, http.StatusInternalServerError)
+					return
+				}
+				if len(uris) > 0 {
+					addMediaTypeParam(mem.Header(), "profile", uris...)
+				}
+			}
+
+			mem.Flush(w)
+		})
+	})
+}
@@ -0,0 +1,302 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gonobo/jsonapi/v2"
+	"github.com/gonobo/jsonapi/v2/query"
+	"github.com/gonobo/jsonapi/v2/server"
+)
+
+// QueryFamilies is implemented by parsers whose query parameters belong to families other
+// than the one they are registered for, e.g. a filter parser that reads the "q" parameter.
+type QueryFamilies interface {
+	// QueryFamilies returns the parameter families read by the parser.
+	QueryFamilies() []string
+}
+
+// PageLimitParameter is implemented by page parsers that read the page limit from a query
+// parameter other than "page[limit]", e.g. "page[size]".
+type PageLimitParameter interface {
+	// LimitParameter returns the name of the page limit query parameter.
+	LimitParameter() string
+}
+
+// QueryConfig configures the query parameter middleware.
+type QueryConfig struct {
+	fields   FieldsetQueryParser
+	page     PageQueryParser
+	filter   FilterQueryParser
+	sort     SortQueryParser
+	include  bool
+	custom   *jsonapi.QueryFamilyRegistry
+	families map[string]string
+}
+
+// WithFieldsetParser parses the "fields" query parameter family with the parser.
+func WithFieldsetParser(parser FieldsetQueryParser) func(*QueryConfig) {
+	return func(c *QueryConfig) {
+		c.fields = parser
+		c.register(parser, "fields")
+	}
+}
+
+// WithPageParser parses the "page" query parameter family with the parser.
+func WithPageParser(parser PageQueryParser) func(*QueryConfig) {
+	return func(c *QueryConfig) {
+		c.page = parser
+		c.register(parser, "page")
+	}
+}
+
+// WithFilterParser parses the "filter" query parameter family with the parser.
+func WithFilterParser(parser FilterQueryParser) func(*QueryConfig) {
+	return func(c *QueryConfig) {
+		c.filter = parser
+		c.register(parser, paramFilter)
+	}
+}
+
+// WithSortParser parses the "sort" query parameter with the parser.
+func WithSortParser(parser SortQueryParser) func(*QueryConfig) {
+	return func(c *QueryConfig) {
+		c.sort = parser
+		c.register(parser, query.ParamSort)
+	}
+}
+
+// WithIncludeParser parses the "include" query parameter.
+func WithIncludeParser() func(*QueryConfig) {
+	return func(c *QueryConfig) {
+		c.include = true
+		c.register(nil, query.ParamInclude)
+	}
+}
+
+// WithQueryFamilyRegistry parses the families of the registry, storing their values in the
+// Query field of the JSON:API request context.
+func WithQueryFamilyRegistry(registry *jsonapi.QueryFamilyRegistry) func(*QueryConfig) {
+	return func(c *QueryConfig) {
+		c.custom = registry
+		for _, family := range registry.Families() {
+			c.register(nil, family.Name)
+		}
+	}
+}
+
+// WithQueryFamilies accepts the implementation-specific query parameter families, which are
+// left to downstream handlers. Family names must be legal member names that contain at least
+// one character other than a lowercase letter a-z; WithQueryFamilies panics otherwise.
+func WithQueryFamilies(families ...string) func(*QueryConfig) {
+	for _, family := range families {
+		if !jsonapi.IsMemberName(family) {
+			panic(fmt.Errorf("%w: invalid name %q", jsonapi.ErrQueryFamily, family))
+		} else if jsonapi.IsReservedQueryFamily(family) {
+			panic(fmt.Errorf("%w: %s is reserved by the specification", jsonapi.ErrQueryFamily, family))
+		}
+	}
+	return func(c *QueryConfig) {
+		for _, family := range families {
+			c.families[family] = family
+		}
+	}
+}
+
+// register maps the families read by the parser to the family it is registered for.
+func (c *QueryConfig) register(parser any, family string) {
+	c.families[family] = family
+	if p, ok := parser.(QueryFamilies); ok {
+		for _, f := range p.QueryFamilies() {
+			c.families[f] = family
+		}
+	}
+}
+
+// UseQueryParameters is a middleware that parses the query parameters of the request with the
+// configured parsers, and stores the results within the JSON:API request context. Parsers run
+// only if the request contains parameters of their families.
+//
+// Unlike the individual parser middleware, UseQueryParameters reports every failure -- parse
+// errors, exceeded limits (see [server.WithLimits]), and parameters of unknown or illegally
+// named families -- in a single error document, each error referencing the offending
+// parameter in its source. Parameter families that are all lowercase letters a-z are reserved
+// by the specification, and are rejected unless a parser is configured for them.
+func UseQueryParameters(options ...func(*QueryConfig)) server.Options {
+	cfg := QueryConfig{families: make(map[string]string)}
+	for _, option := range options {
+		option(&cfg)
+	}
+
+	filters := make(map[string]bool)
+	for family, registered := range cfg.families {
+		if registered == paramFilter {
+			filters[family] = true
+		}
+	}
+
+	return server.WithMiddleware(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			params := r.URL.Query()
+			present, errs := cfg.checkFamilies(params)
+			limits := server.LimitsFromContext(r.Context())
+			ctx := jsonapi.FromContext(r.Context()).Clone()
+
+			if cfg.fields != nil && present["fields"] {
+				fields, err := parseFamily(r, "fields", cfg.fields.ParseFieldsetQuery)
+				errs = appendQueryErrors(errs, "fields", err)
+				ctx.Fields = fields
+			}
+
+			if cfg.page != nil && present["page"] {
+				page, err := parseFamily(r, "page", cfg.page.ParsePageQuery)
+				errs = appendQueryErrors(errs, "page", err)
+				if err == nil {
+					errs = appendLimitError(errs, pageLimitError(page, limits, limitParameter(cfg.page)))
+				}
+				ctx.Pagination = page
+			}
+
+			if cfg.filter != nil && present[paramFilter] {
+				if err := filterBytesLimitError(params, filters, limits); err != nil {
+					errs = append(errs, err)
+				} else {
+					filter, err := parseFamily(r, paramFilter, cfg.filter.ParseFilterQuery)
+					errs = appendQueryErrors(errs, paramFilter, err)
+					if err == nil {
+						errs = appendLimitError(errs, filterLimitError(filter, limits))
+					}
+					ctx.Filter = filter
+				}
+			}
+
+			if cfg.sort != nil && present[query.ParamSort] {
+				criteria, err := parseFamily(r, query.ParamSort, cfg.sort.ParseSortQuery)
+				errs = appendQueryErrors(errs, query.ParamSort, err)
+				if err == nil {
+					errs = appendLimitError(errs, sortLimitError(criteria, limits))
+				}
+				ctx.Sort = criteria
+			}
+
+			if cfg.include && present[query.ParamInclude] {
+				include := strings.Split(params.Get(query.ParamInclude), ",")
+				errs = appendLimitError(errs, includeLimitError(include, limits))
+				ctx.Include = include
+			}
+
+			if cfg.custom != nil {
+				for _, family := range cfg.custom.Families() {
+					if !present[family.Name] {
+						continue
+					}
+					value, err := parseFamily(r, family.Name, func(r *http.Request) (any, error) {
+						return family.Parse(params)
+					})
+					errs = appendQueryErrors(errs, family.Name, err)
+					if ctx.Query == nil {
+						ctx.Query = make(jsonapi.QueryValues)
+					}
+					ctx.Query[family.Name] = value
+				}
+			}
+
+			if len(errs) > 0 {
+				writeValidationErrors(w, errs)
+				return
+			}
+
+			next.ServeHTTP(w, jsonapi.RequestWithContext(r, ctx))
+		})
+	})
+}
+
+// checkFamilies returns the configured families present in the query, and errors for the
+// parameters of unknown or illegally named families.
+func (c QueryConfig) checkFamilies(params url.Values) (map[string]bool, []*jsonapi.Error) {
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	present := make(map[string]bool)
+	var errs []*jsonapi.Error
+	for _, key := range keys {
+		family := queryFamily(key)
+		if registered, ok := c.families[family]; ok {
+			present[registered] = true
+			continue
+		}
+
+		switch {
+		case !jsonapi.IsMemberName(family):
+			errs = append(errs, queryError(key, "query parameter family %q is not a legal member name", family))
+		case jsonapi.IsReservedQueryFamily(family):
+			errs = append(errs, queryError(key, "query parameter family %q is not supported", family))
+		default:
+			errs = append(errs, queryError(key, "unknown query parameter family %q", family))
+		}
+	}
+	return present, errs
+}
+
+// parseFamily parses the query parameter family, instrumenting the parser with a span.
+func parseFamily[T any](r *http.Request, family string, parse func(*http.Request) (T, error)) (T, error) {
+	_, span := server.StartSpan(r.Context(), server.SpanParseQuery, slog.String("family", family))
+	value, err := parse(r)
+	server.EndSpan(span, err)
+	return value, err
+}
+
+// appendQueryErrors converts the parser error into JSON:API errors. JSON:API errors and
+// joined errors are preserved; other errors reference the parameter family.
+func appendQueryErrors(errs []*jsonapi.Error, family string, err error) []*jsonapi.Error {
+	if err == nil {
+		return errs
+	}
+
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, err := range joined.Unwrap() {
+			errs = appendQueryErrors(errs, family, err)
+		}
+		return errs
+	}
+
+	jsonapiErr := jsonapi.Error{}
+	if errors.As(err, &jsonapiErr) {
+		return append(errs, &jsonapiErr)
+	}
+	return append(errs, queryError(family, "%s", err))
+}
+
+func appendLimitError(errs []*jsonapi.Error, err *jsonapi.Error) []*jsonapi.Error {
+	if err == nil {
+		return errs
+	}
+	return append(errs, err)
+}
+
+// queryError returns a JSON:API error reporting an invalid query parameter.
+func queryError(param string, format string, args ...any) *jsonapi.Error {
+	return &jsonapi.Error{
+		Status: strconv.Itoa(http.StatusBadRequest),
+		Title:  "Invalid Query Parameter",
+		Detail: fmt.Sprintf(format, args...),
+		Source: &jsonapi.ErrorSource{Parameter: param},
+	}
+}
+
+// queryFamily returns the family of the query parameter, e.g. "page" for "page[limit]".
+func queryFamily(key string) string {
+	if i := strings.IndexByte(key, '['); i >= 0 {
+		return key[:i]
+	}
+	return key
+}
@@ -0,0 +1,145 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+
+	"github.com/gonobo/jsonapi/v2"
+	"github.com/gonobo/jsonapi/v2/server"
+)
+
+var errInternalServer = errors.New("the server encountered an unexpected condition")
+
+// Logger records panics recovered by [UseRecovery]. The standard library's
+// [log.Logger] implements this interface.
+//
+// Deprecated: register a [slog.Logger] with [server.WithLogger] and pass a nil
+// logger to [UseRecovery] instead. Existing loggers can be adapted to slog with
+// [NewPrintfLogger].
+type Logger interface {
+	// Printf formats and records a message.
+	Printf(format string, v ...any)
+}
+
+// LoggerFunc functions implement Logger.
+//
+// Deprecated: see [Logger].
+type LoggerFunc func(format string, v ...any)
+
+// Printf formats and records a message.
+func (fn LoggerFunc) Printf(format string, v ...any) {
+	fn(format, v...)
+}
+
+// NewPrintfLogger returns a structured logger that formats each record as a single
+// line of text and writes it to the provided Printf logger.
+func NewPrintfLogger(logger Logger) *slog.Logger {
+	return slog.New(slog.NewTextHandler(printfWriter{logger}, nil))
+}
+
+// printfWriter writes each line of text to a Printf logger.
+type printfWriter struct {
+	logger Logger
+}
+
+// Write records p with the Printf logger.
+func (w printfWriter) Write(p []byte) (int, error) {
+	w.logger.Printf("%s", bytes.TrimSuffix(p, []byte("\n")))
+	return len(p), nil
+}
+
+// UseRecovery is a middleware that recovers from panics raised anywhere downstream
+// in the middleware chain -- including sub-requests issued by the include and
+// related resource resolvers. The panic value and stack trace are recorded with
+// at the error level with the handler's structured logger (see [server.WithLogger]),
+// and the client receives a 500 Internal Server Error document whose error id matches
+// the logged entry.
+//
+// If logger is non-nil, the record is written to it instead, through [NewPrintfLogger].
+// UseRecovery should be declared before any other middleware so that it wraps the
+// entire chain.
+func UseRecovery(logger Logger) server.Options {
+	return server.WithMiddleware(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rw := &recoveryWriter{ResponseWriter: w}
+
+			defer func() {
+				value := recover()
+				if value == nil {
+					return
+				} else if value == http.ErrAbortHandler {
+					// the handler intends to abort the response; let the
+					// http server handle it.
+					panic(value)
+				}
+
+				id := newErrorID()
+				ctx := r.Context()
+				if logger != nil {
+					ctx = server.ContextWithLogger(ctx, NewPrintfLogger(logger))
+				}
+
+				server.LoggerFromContext(ctx).ErrorContext(ctx, "jsonapi: recovered from panic",
+					slog.String("error_id", id),
+					slog.String("method", r.Method),
+					slog.String("url", r.URL.String()),
+					slog.Any("panic", value),
+					slog.String("stack", string(debug.Stack())),
+				)
+
+				if rw.wroteHeader {
+					// the response is already underway; nothing more can be sent.
+					return
+				}
+
+				server.Error(rw, jsonapi.Error{
+					ID:     id,
+					Status: strconv.Itoa(http.StatusInternalServerError),
+					Title:  http.StatusText(http.StatusInternalServerError),
+					Detail: errInternalServer.Error(),
+				}, http.StatusInternalServerError)
+			}()
+
+			next.ServeHTTP(rw, r)
+		})
+	})
+}
+
+// recoveryWriter tracks whether a response has been started, so that
+// recovered panics do not attempt to write a second status code.
+type recoveryWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+// WriteHeader sends an HTTP response header with the provided status code.
+func (rw *recoveryWriter) WriteHeader(status int) {
+	rw.wroteHeader = true
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+// Write writes the data to the connection as part of an HTTP reply.
+func (rw *recoveryWriter) Write(p []byte) (int, error) {
+	rw.wroteHeader = true
+	return rw.ResponseWriter.Write(p)
+}
+
+// Unwrap returns the underlying response writer.
+func (rw *recoveryWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// newErrorID generates a random identifier for an error occurrence.
+func newErrorID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
@@ -1,19 +1,29 @@
 package middleware
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"net/url"
+	"runtime/debug"
+	"slices"
 	"strings"
+	"sync"
 
 	"github.com/gonobo/jsonapi/v2"
 	"github.com/gonobo/jsonapi/v2/server"
 )
 
 // UseRelatedResourceResolver is a middleware that handles incoming requests
-// for related resources.
-func UseRelatedResourceResolver() server.Options {
+// for related resources. If a [server.Loader] is registered with the handler,
+// the parent and related resources are loaded directly instead of through
+// downstream requests. If a [server.Policy] is registered with the handler,
+// requests are authorized before the parent resource is retrieved.
+func UseRelatedResourceResolver(options ...func(*ResolverConfig)) server.Options {
 	return server.WithMiddleware(func(next http.Handler) http.Handler {
-		resolver := relatedResourceResolver{next}
+		resolver := newRelatedResourceResolver(next, options...)
 		return http.HandlerFunc(resolver.retrieveRelated)
 	})
 }
@@ -22,27 +32,64 @@ func UseRelatedResourceResolver() server.Options {
 // server resources associated with the response document's primary data,
 // and adds it to the "included" array.
 //
-// UseIncludedResourceResolver currently supports inclusion requests only one level deep;
-// dot notation for multiple inclusions is not supported.
-func UseIncludedResourceResolver() server.Options {
+// Inclusion is supported for both single resource and resource collection requests.
+// Nested inclusions are requested with dot notation, e.g. "comments.author". Related
+// resources are fetched in batches: linkage is grouped by resource type across all
+// resources at the same depth, so each resource type at each depth is retrieved with a
+// single downstream request whose context specifies the resource type and FetchIDs.
+// If a [server.Loader] is registered with the handler, resources are loaded directly
+// instead; see [server.WithLoader].
+//
+// Batches at the same depth are independent of one another, and can be fetched
+// concurrently with [WithMaxConcurrency]. Results are merged into the "included"
+// array in a deterministic order regardless of completion order.
+func UseIncludedResourceResolver(options ...func(*ResolverConfig)) server.Options {
 	return server.WithMiddleware(
 		func(next http.Handler) http.Handler {
-			resolver := relatedResourceResolver{next}
+			resolver := newRelatedResourceResolver(next, options...)
 			return http.HandlerFunc(resolver.includeRelated)
 		},
 	)
 }
 
+var errResourceNotFound = errors.New("resource not found")
+
+// ResolverConfig configures the include and related resource resolvers.
+type ResolverConfig struct {
+	// MaxConcurrency is the maximum number of resource batches fetched concurrently.
+	// Values less than 2 fetch batches sequentially.
+	MaxConcurrency int
+}
+
+// WithMaxConcurrency sets the maximum number of resource batches fetched concurrently
+// by the include and related resource resolvers. Each batch is fetched with the
+// request's context; if a fetch fails, the context of the remaining fetches is canceled.
+func WithMaxConcurrency(limit int) func(*ResolverConfig) {
+	return func(c *ResolverConfig) {
+		c.MaxConcurrency = limit
+	}
+}
+
 type relatedResourceResolver struct {
 	handler http.Handler
+	config  ResolverConfig
+}
+
+func newRelatedResourceResolver(next http.Handler, options ...func(*ResolverConfig)) relatedResourceResolver {
+	rr := relatedResourceResolver{handler: next}
+	for _, apply := range options {
+		apply(&rr.config)
+	}
+	return rr
 }
 
 func (rr relatedResourceResolver) includeRelated(w http.ResponseWriter, r *http.Request) {
 	ctx := jsonapi.FromContext(r.Context())
+	tree := newIncludeTree(ctx.Include)
 
-	// skip if the request is not for a single resource.
+	// skip if the request is not for resources, or if there is nothing to include.
 
-	if !rr.isFetchResourceRequest(r, ctx) {
+	if !rr.isFetchResourceRequest(r, ctx) || len(tree.children) == 0 {
 		rr.handler.ServeHTTP(w, r)
 		return
 	}
@@ -57,33 +104,24 @@ func (rr relatedResourceResolver) includeRelated(w http.ResponseWriter, r *http.
 
 	if mem.Status != http.StatusOK {
 		mem.Flush(w)
+		return
 	} else if mem.Document == nil || mem.Document.Data == nil {
 		mem.Flush(w)
+		return
 	}
 
-	data := mem.Document.Data.First()
-	memo := make(map[string]*jsonapi.Resource)
+	// resolve each requested relationship path across all of the primary data,
+	// and append the results to the list of included resources.
 
-	// parse the data from downstream; resolve each requested relationship
-	// and store in a lookup table (to prevent multiple references to the
-	// same resource). once finished, dump the memo to the list of included
-	// resources and return.
-
-	for _, include := range ctx.Include {
-		// check and handle nested include requests represented with dot notation.
-		names := strings.Split(include, ".")
+	included, err := rr.resolveIncludes(r, tree, mem.Document.Data.Items())
+	if err != nil {
 		// if an error is generated during fetch, halt and return the error
 		// back to the client.
-		if err := rr.fetchResourceRelationships(r, names, data, memo); err != nil {
-			server.Error(w, fmt.Errorf("include resources: %w", err), http.StatusInternalServerError)
-			return
-		}
-	}
-
-	for _, included := range memo {
-		mem.Document.Included = append(mem.Document.Included, included)
+		writeFetchFailure(w, err, "include resources")
+		return
 	}
 
+	mem.Document.Included = appendIncluded(mem.Document.Included, included...)
 	mem.Flush(w)
 }
 
@@ -96,45 +134,190 @@ func (rr relatedResourceResolver) retrieveRelated(w http.ResponseWriter, r *http
 		return
 	}
 
-	// use a recorder to capture a downstream request to retrieve
-	// the parent resource.
+	// the request is answered here rather than by a resource mux, so it must be
+	// authorized before the parent is fetched: the parent's loader does not
+	// consult the policy, and the relationship itself may be concealed.
 
-	mem := server.NewRecorder()
-	ctx = ctx.Child()
-	ctx.Related = false
+	if !server.Authorize(w, r, ctx) {
+		return
+	}
 
-	rr.handler.ServeHTTP(mem, jsonapi.RequestWithContext(r, ctx))
+	// retrieve the parent resource, either from a registered loader or
+	// by capturing a downstream request with a recorder.
 
-	// if the request is not OK, or if there is no data to be parsed
-	// return early.
+	parent, mem := rr.fetchParent(r, ctx)
 
-	if mem.Status != http.StatusOK {
+	if mem != nil {
+		// the parent could not be retrieved; return the downstream response.
 		mem.Flush(w)
 		return
-	} else if mem.Document == nil || mem.Document.Data == nil {
-		mem.Flush(w)
+	} else if parent == nil {
+		server.Error(w, errResourceNotFound, http.StatusNotFound)
+		return
+	}
+
+	ref, ok := parent.Relationships[ctx.Relationship]
+	if !ok || ref == nil {
+		server.Error(w, fmt.Errorf("%w: unknown relationship %s", errResourceNotFound, ctx.Relationship),
+			http.StatusNotFound)
+		return
+	}
+
+	if ref.Data == nil || !ref.Data.IsMany() {
+		rr.retrieveRelatedOne(w, r, ref)
 		return
 	}
 
-	// capture the response data's relationships, and and send additional
-	// request to get the related resources. use a memo to avoid duplicate
-	// resources in the response.
+	rr.retrieveRelatedMany(w, r, ref)
+}
+
+// fetchParent retrieves the resource that owns the requested relationship. If the
+// resource cannot be retrieved from downstream handlers, the recorded downstream
+// response is returned instead.
+func (rr relatedResourceResolver) fetchParent(r *http.Request,
+	ctx *jsonapi.RequestContext) (*jsonapi.Resource, *server.ResponseRecorder) {
+	data, err := rr.load(r, ctx.ResourceType, []string{ctx.ResourceID})
 
-	memo := make(map[string]*jsonapi.Resource)
-	data := mem.Document.Data.First()
-	names := []string{ctx.Relationship}
+	if errors.Is(err, server.ErrNoLoader) {
+		parent := ctx.EmptyChild()
+		parent.ResourceType = ctx.ResourceType
+		parent.ResourceID = ctx.ResourceID
 
-	if err := rr.fetchResourceRelationships(r, names, data, memo); err != nil {
-		// if the request fails, return the error back to the client.
-		server.Error(w, fmt.Errorf("related resources: %w", err), http.StatusInternalServerError)
+		mem := server.NewRecorder()
+		rr.handler.ServeHTTP(mem, jsonapi.RequestWithContext(r, parent))
+
+		// if the request is not OK, or if there is no data to be parsed
+		// return early.
+
+		if mem.Status != http.StatusOK {
+			return nil, mem
+		} else if mem.Document == nil || mem.Document.Data == nil {
+			return nil, mem
+		}
+
+		data = mem.Document.Data.Items()
+	} else if err != nil {
+		mem := server.NewRecorder()
+		server.Error(mem, fmt.Errorf("related resources: %w", err), http.StatusInternalServerError)
+		return nil, mem
+	}
+
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	return data[0], nil
+}
+
+// retrieveRelatedOne writes the resource referenced by a "to-one" relationship
+// as primary data, or null if the relationship is empty.
+func (rr relatedResourceResolver) retrieveRelatedOne(w http.ResponseWriter, r *http.Request,
+	ref *jsonapi.Relationship) {
+	doc := &jsonapi.Document{Data: jsonapi.One{}}
+
+	if ref.Data != nil {
+		if linkage := ref.Data.First(); linkage != nil {
+			items, err := rr.fetchResources(r, linkage.Type, []string{linkage.ID})
+			if err != nil {
+				writeFetchFailure(w, err, "related resources")
+				return
+			}
+			for _, item := range items {
+				if keyOf(item) == keyOf(linkage) {
+					doc.Data = jsonapi.One{Value: item}
+				}
+			}
+		}
+	}
+
+	server.Write(w, doc, http.StatusOK, server.WriteSelfLink(r))
+}
+
+// retrieveRelatedMany writes the resources referenced by a "to-many" relationship
+// as primary data. The request is delegated to the List handler of the related
+// resource type, constrained to the ids within the relationship's linkage; the
+// request context's pagination, filter, and sort criteria are preserved, so the
+// handler can apply them. The pagination links emitted by the handler are rebased
+// onto the request URL.
+func (rr relatedResourceResolver) retrieveRelatedMany(w http.ResponseWriter, r *http.Request,
+	ref *jsonapi.Relationship) {
+	batch := newFetchBatch()
+	for _, item := range ref.Data.Items() {
+		batch.add(keyOf(item))
+	}
+
+	if len(batch.types) == 0 {
+		server.Write(w, jsonapi.NewMultiDocument(), http.StatusOK, server.WriteSelfLink(r))
+		return
+	} else if len(batch.types) > 1 {
+		// the relationship is polymorphic; the related resources cannot be
+		// listed by a single handler, so fetch them in batches instead.
+		rr.retrieveRelatedBatch(w, r, batch)
 		return
 	}
 
-	// dump the memo into a new multi document and return to the client.
-	items := make([]*jsonapi.Resource, len(memo))
+	resourceType := batch.types[0]
+
+	ctx := jsonapi.FromContext(r.Context()).Child()
+	ctx.ResourceType = resourceType
+	ctx.ResourceID = ""
+	ctx.Relationship = ""
+	ctx.Related = false
+	ctx.FetchIDs = batch.ids[resourceType]
+
+	mem := server.NewRecorder()
+	rr.handler.ServeHTTP(mem, jsonapi.RequestWithContext(r, ctx))
+
+	if mem.Status == http.StatusOK && mem.Document != nil && len(mem.Document.Errors) == 0 {
+		if mem.Document.Links == nil {
+			mem.Document.Links = jsonapi.Links{}
+		}
+		mem.Document.Links[server.LinkAttributeSelf] = &jsonapi.Link{Href: r.URL.String()}
+
+		// the list handler may derive its pagination links from the related resource
+		// type's collection URL; navigate the related resource URL instead.
+		for _, name := range []string{"first", "prev", "next", "last"} {
+			if link, ok := mem.Document.Links[name]; ok && link != nil {
+				rebased := *link
+				rebased.Href = rebaseLink(link.Href, r.URL)
+				mem.Document.Links[name] = &rebased
+			}
+		}
+	}
+
+	mem.Flush(w)
+}
+
+// rebaseLink returns the URL of the request with the query parameters of the link.
+func rebaseLink(href string, base *url.URL) string {
+	link, err := url.Parse(href)
+	if err != nil {
+		return href
+	}
+	rebased := *base
+	rebased.RawQuery = link.RawQuery
+	return rebased.String()
+}
+
+// retrieveRelatedBatch writes the resources in the batch as primary data, without
+// applying pagination, filter, or sort criteria.
+func (rr relatedResourceResolver) retrieveRelatedBatch(w http.ResponseWriter, r *http.Request,
+	batch *fetchBatch) {
+	results, err := rr.fetchBatch(r, batch)
 
-	for _, item := range memo {
-		items = append(items, item)
+	if err != nil {
+		// if the request fails, return the error back to the client.
+		writeFetchFailure(w, err, "related resources")
+		return
+	}
+
+	items := make([]*jsonapi.Resource, 0)
+	for _, result := range results {
+		for _, item := range result {
+			if batch.contains(keyOf(item)) {
+				items = append(items, item)
+			}
+		}
 	}
 
 	server.Write(w, jsonapi.NewMultiDocument(items...),
@@ -146,7 +329,6 @@ func (rr relatedResourceResolver) retrieveRelated(w http.ResponseWriter, r *http
 
 func (relatedResourceResolver) isFetchResourceRequest(r *http.Request, ctx *jsonapi.RequestContext) bool {
 	return r.Method == http.MethodGet &&
-		ctx.ResourceID != "" &&
 		ctx.Relationship == "" &&
 		ctx.ResourceType != "" &&
 		!ctx.Related
@@ -159,67 +341,408 @@ func (relatedResourceResolver) isRelatedResourceRequest(ctx *jsonapi.RequestCont
 		ctx.ResourceType != ""
 }
 
-func (rr relatedResourceResolver) fetchResourceRelationships(r *http.Request,
-	names []string, data *jsonapi.Resource, memo map[string]*jsonapi.Resource) error {
-	if len(names) > 0 {
-		name := names[0]
+// resolveIncludes walks the include tree breadth first, starting from the provided
+// resources. At each depth, the linkage of every requested relationship is grouped by
+// resource type and fetched with one downstream request per type. The returned
+// resources are ordered by discovery, and exclude the starting resources.
+func (rr relatedResourceResolver) resolveIncludes(r *http.Request, tree *includeTree,
+	data []*jsonapi.Resource) ([]*jsonapi.Resource, error) {
+	// memo contains every resource resolved so far, keyed by type and id. the
+	// starting resources are added first so they are neither fetched nor included.
+	memo := make(map[resourceKey]*jsonapi.Resource)
+	for _, item := range data {
+		if item != nil {
+			memo[keyOf(item)] = item
+		}
+	}
 
-		if data.Relationships == nil {
-			return nil
+	included := make([]*jsonapi.Resource, 0)
+	frontier := []includeStep{{tree, data}}
+
+	for len(frontier) > 0 {
+		// gather the linkage requested at this depth, grouped by resource type.
+		linkage := make([]includeLink, 0)
+		batch := newFetchBatch()
+
+		for _, step := range frontier {
+			for _, child := range step.node.sortedChildren() {
+				for _, item := range step.resources {
+					for _, ref := range relationshipItems(item, child.name) {
+						key := keyOf(ref)
+						linkage = append(linkage, includeLink{child, key})
+						if _, ok := memo[key]; !ok {
+							batch.add(key)
+						}
+					}
+				}
+			}
 		}
 
-		ref, ok := data.Relationships[name]
+		// fetch each resource type with a single request.
+		results, err := rr.fetchBatch(r, batch)
+		if err != nil {
+			return nil, err
+		}
 
-		if !ok || ref.Data == nil {
-			return nil
+		for _, items := range results {
+			for _, item := range items {
+				key := keyOf(item)
+				if _, ok := memo[key]; ok || !batch.contains(key) {
+					// ignore duplicates and resources that were not requested,
+					// so that full linkage is preserved.
+					continue
+				}
+				memo[key] = item
+				included = append(included, item)
+			}
 		}
 
-		items := ref.Data.Items()
+		// the resolved resources become the starting point of the next depth.
+		next := make([]includeStep, 0)
+		steps := make(map[*includeTree]int)
+		seen := make(map[includeLink]bool)
+		for _, link := range linkage {
+			item, ok := memo[link.key]
+			if !ok || seen[link] || len(link.node.children) == 0 {
+				continue
+			}
+			seen[link] = true
+			idx, ok := steps[link.node]
+			if !ok {
+				idx = len(next)
+				steps[link.node] = idx
+				next = append(next, includeStep{node: link.node})
+			}
+			next[idx].resources = append(next[idx].resources, item)
+		}
+
+		frontier = next
+	}
+
+	return included, nil
+}
+
+// fetchPanic is a panic raised by a fetch worker. Since the stack of a panic is lost when
+// it is raised again on another goroutine, the worker's stack is kept alongside its value.
+type fetchPanic struct {
+	value any
+	stack []byte
+}
+
+// workerPanic returns the value to raise on the request's goroutine for the recovered
+// panic of a fetch worker. http.ErrAbortHandler is raised as is.
+func workerPanic(value any) any {
+	if value == http.ErrAbortHandler {
+		return value
+	}
+	return fetchPanic{value: value, stack: debug.Stack()}
+}
+
+// Error returns the panic value, followed by the stack of the worker goroutine.
+func (p fetchPanic) Error() string {
+	return fmt.Sprintf("%v\n\nfetch worker stack:\n%s", p.value, p.stack)
+}
+
+// Unwrap returns the panic value, if it is an error.
+func (p fetchPanic) Unwrap() error {
+	err, _ := p.value.(error)
+	return err
+}
+
+// fetchBatch retrieves the resources in the batch, one fetch per resource type. The
+// results are ordered by the batch's resource types. Fetches run concurrently
+// up to the configured limit; the first failure cancels the remaining fetches.
+func (rr relatedResourceResolver) fetchBatch(r *http.Request, batch *fetchBatch) ([][]*jsonapi.Resource, error) {
+	results := make([][]*jsonapi.Resource, len(batch.types))
 
-		if len(items) == 0 {
-			return nil
+	if rr.config.MaxConcurrency < 2 || len(batch.types) < 2 {
+		for idx, resourceType := range batch.types {
+			if err := r.Context().Err(); err != nil {
+				return nil, err
+			}
+			items, err := rr.fetchResources(r, resourceType, batch.ids[resourceType])
+			if err != nil {
+				return nil, fmt.Errorf("fetch %s: %w", resourceType, err)
+			}
+			results[idx] = items
 		}
+		return results, nil
+	}
 
-		ctx := jsonapi.FromContext(r.Context())
-		ctx = ctx.EmptyChild()
-		ctx.FetchIDs = make([]string, 0, len(items))
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	r = r.WithContext(ctx)
+	errs := make([]error, len(batch.types))
+	panics := make([]any, len(batch.types))
+	limit := make(chan struct{}, rr.config.MaxConcurrency)
+	wg := sync.WaitGroup{}
+
+	for idx, resourceType := range batch.types {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			select {
+			case limit <- struct{}{}:
+				defer func() { <-limit }()
+			case <-ctx.Done():
+				errs[idx] = ctx.Err()
+				return
+			}
+
+			defer func() {
+				// panics cannot cross goroutines; capture them so they can be
+				// raised again on the request's goroutine.
+				if value := recover(); value != nil {
+					panics[idx] = workerPanic(value)
+					cancel()
+				}
+			}()
+
+			if err := ctx.Err(); err != nil {
+				errs[idx] = err
+				return
+			}
 
-		for idx, item := range items {
-			if idx == 0 {
-				ctx.ResourceType = item.Type
+			items, err := rr.fetchResources(r, resourceType, batch.ids[resourceType])
+			if err != nil {
+				errs[idx] = fmt.Errorf("fetch %s: %w", resourceType, err)
+				cancel()
+				return
 			}
-			ctx.FetchIDs = append(ctx.FetchIDs, item.ID)
+
+			results[idx] = items
+		}()
+	}
+
+	wg.Wait()
+
+	for _, value := range panics {
+		if value != nil {
+			panic(value)
+		}
+	}
+
+	// report the error that caused the cancellation, rather than the
+	// cancellations themselves.
+	for _, err := range errs {
+		if err != nil && !errors.Is(err, context.Canceled) {
+			return nil, err
+		}
+	}
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
 		}
+	}
 
-		mem := server.NewRecorder()
-		rr.handler.ServeHTTP(mem, jsonapi.RequestWithContext(r, ctx))
+	return results, nil
+}
 
-		if mem.Status != http.StatusOK {
-			return nil
-		} else if mem.Document == nil || mem.Document.Data == nil {
-			return nil
-		} else if len(mem.Document.Errors) > 0 {
-			return mem.Document.Error()
+// fetchResources retrieves the resources of the provided type with the provided ids.
+// If a loader is registered for the resource type, the resources are loaded directly;
+// otherwise, a request is sent to the downstream handler.
+func (rr relatedResourceResolver) fetchResources(r *http.Request,
+	resourceType string, ids []string) (items []*jsonapi.Resource, err error) {
+	spanctx, span := server.StartSpan(r.Context(), server.SpanInclude,
+		slog.String("type", resourceType), slog.Int("ids", len(ids)))
+	defer func() {
+		span.SetAttributes(slog.Int("resources", len(items)))
+		server.EndSpan(span, err)
+	}()
+	r = r.WithContext(spanctx)
+
+	source := "loader"
+	defer func() {
+		server.InstrumentationFromContext(r.Context()).AddCounter(r.Context(), server.MetricIncludeFetches, 1,
+			slog.String("type", resourceType), slog.String("source", source))
+	}()
+
+	if items, err := rr.load(r, resourceType, ids); !errors.Is(err, server.ErrNoLoader) {
+		return items, err
+	}
+	source = "request"
+
+	ctx := jsonapi.FromContext(r.Context())
+	ctx = ctx.EmptyChild()
+	ctx.ResourceType = resourceType
+	ctx.FetchIDs = ids
+
+	mem := server.NewRecorder()
+	rr.handler.ServeHTTP(mem, jsonapi.RequestWithContext(r, ctx))
+
+	// a missing or concealed resource type yields no resources; any other failure
+	// is reported, so the client is not answered with incomplete data.
+
+	if mem.Status == http.StatusNotFound {
+		return nil, nil
+	} else if mem.Status != http.StatusOK || (mem.Document != nil && len(mem.Document.Errors) > 0) {
+		return nil, fetchError{mem}
+	} else if mem.Document == nil || mem.Document.Data == nil {
+		return nil, nil
+	}
+
+	return mem.Document.Data.Items(), nil
+}
+
+// fetchError reports a downstream request for resources that failed.
+type fetchError struct {
+	mem *server.ResponseRecorder // The recorded downstream response.
+}
+
+func (e fetchError) Error() string {
+	if e.mem.Document != nil && len(e.mem.Document.Errors) > 0 {
+		return e.mem.Document.Error().Error()
+	}
+	return fmt.Sprintf("downstream request failed with status %d", e.mem.Status)
+}
+
+// writeFetchFailure writes the response of a failed downstream request to the client, or
+// an internal server error if the resources could not be retrieved otherwise.
+func writeFetchFailure(w http.ResponseWriter, err error, msg string) {
+	var failure fetchError
+	if errors.As(err, &failure) {
+		failure.mem.Flush(w)
+		return
+	}
+	server.Error(w, fmt.Errorf("%s: %w", msg, err), http.StatusInternalServerError)
+}
+
+// load retrieves resources with the loader stored in the request context. If no
+// loader is available for the resource type, an error wrapping [server.ErrNoLoader]
+// is returned.
+func (relatedResourceResolver) load(r *http.Request,
+	resourceType string, ids []string) ([]*jsonapi.Resource, error) {
+	loader, ok := server.LoaderFromContext(r.Context())
+	if !ok {
+		return nil, server.ErrNoLoader
+	}
+	return loader.LoadResources(r.Context(), resourceType, ids)
+}
+
+// relationshipItems returns the linkage of the named relationship of the resource.
+func relationshipItems(item *jsonapi.Resource, name string) []*jsonapi.Resource {
+	if item == nil || item.Relationships == nil {
+		return nil
+	}
+
+	ref, ok := item.Relationships[name]
+	if !ok || ref == nil || ref.Data == nil {
+		return nil
+	}
+
+	return ref.Data.Items()
+}
+
+// appendIncluded appends resources to the list of included resources. Resources that
+// are already present are replaced, as the resolved resources are authoritative.
+func appendIncluded(included []*jsonapi.Resource, items ...*jsonapi.Resource) []*jsonapi.Resource {
+	index := make(map[resourceKey]int, len(included))
+	for idx, item := range included {
+		index[keyOf(item)] = idx
+	}
+	for _, item := range items {
+		if idx, ok := index[keyOf(item)]; ok {
+			included[idx] = item
+			continue
 		}
+		index[keyOf(item)] = len(included)
+		included = append(included, item)
+	}
+	return included
+}
+
+// resourceKey uniquely identifies a resource within a document.
+type resourceKey struct {
+	resourceType string
+	id           string
+}
+
+func keyOf(r *jsonapi.Resource) resourceKey {
+	return resourceKey{r.Type, r.ID}
+}
+
+// includeTree is a tree of relationship names parsed from a list of include paths.
+type includeTree struct {
+	name     string
+	children map[string]*includeTree
+}
 
-		items = mem.Document.Data.Items()
+// newIncludeTree parses the provided include paths, which use dot notation
+// for nested relationships, into a tree. Empty paths are ignored.
+func newIncludeTree(paths []string) *includeTree {
+	root := &includeTree{children: make(map[string]*includeTree)}
 
-		if len(names) > 1 {
-			names = names[1:]
-		} else {
-			names = []string{}
+	for _, path := range paths {
+		if path == "" {
+			continue
 		}
 
-		for _, item := range items {
-			if err := rr.fetchResourceRelationships(r, names, item, memo); err != nil {
-				return fmt.Errorf("include resources: %v: %w", names, err)
+		node := root
+		for _, name := range strings.Split(path, ".") {
+			child, ok := node.children[name]
+			if !ok {
+				child = &includeTree{name: name, children: make(map[string]*includeTree)}
+				node.children[name] = child
 			}
+			node = child
 		}
-	} else {
-		// names is empty, add data to the memo
-		key := fmt.Sprintf("%s:%s", data.Type, data.ID)
-		memo[key] = data
 	}
 
-	return nil
+	return root
+}
+
+// sortedChildren returns the children of the node, ordered by name.
+func (t *includeTree) sortedChildren() []*includeTree {
+	children := make([]*includeTree, 0, len(t.children))
+	for _, child := range t.children {
+		children = append(children, child)
+	}
+	slices.SortFunc(children, func(a, b *includeTree) int {
+		return strings.Compare(a.name, b.name)
+	})
+	return children
+}
+
+// includeStep pairs a node in the include tree with the resources whose
+// relationships should be resolved against the node's children.
+type includeStep struct {
+	node      *includeTree
+	resources []*jsonapi.Resource
+}
+
+// includeLink records a single relationship reference discovered at a node.
+type includeLink struct {
+	node *includeTree
+	key  resourceKey
+}
+
+// fetchBatch groups resource ids by resource type, preserving discovery order.
+type fetchBatch struct {
+	types []string
+	ids   map[string][]string
+	keys  map[resourceKey]bool
+}
+
+func newFetchBatch() *fetchBatch {
+	return &fetchBatch{
+		ids:  make(map[string][]string),
+		keys: make(map[resourceKey]bool),
+	}
+}
+
+func (b *fetchBatch) add(key resourceKey) {
+	if b.keys[key] {
+		return
+	}
+	if _, ok := b.ids[key.resourceType]; !ok {
+		b.types = append(b.types, key.resourceType)
+	}
+	b.keys[key] = true
+	b.ids[key.resourceType] = append(b.ids[key.resourceType], key.id)
+}
+
+func (b *fetchBatch) contains(key resourceKey) bool {
+	return b.keys[key]
 }
@@ -0,0 +1,183 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gonobo/jsonapi/v2"
+	"github.com/gonobo/jsonapi/v2/server"
+)
+
+// UseRequestValidator is a middleware that enforces the specification's rules for request
+// documents against the JSON:API request context. It must be applied after
+// [UseRequestBodyParser], which stores the request document in the context.
+//
+// Requests to create or update resources must contain a single resource object as
+// primary data, whose type matches the endpoint; update requests must also identify the
+// resource addressed by the URL. Requests to update relationships must contain resource
+// linkage only, and requests to add or remove relationship members must contain an array
+// of resource identifiers.
+//
+// Mismatched types and ids are reported with 409 Conflict errors; all other violations are
+// reported with 400 Bad Request errors. Each error references the offending member in its
+// source pointer.
+func UseRequestValidator() server.Options {
+	return server.WithMiddleware(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := jsonapi.FromContext(r.Context())
+
+			var errs []*jsonapi.Error
+			switch {
+			case ctx.Related:
+				// related resource endpoints are read-only.
+			case ctx.Relationship != "":
+				errs = validateRelationshipRequest(r.Method, ctx)
+			case r.Method == http.MethodPost && ctx.ResourceID == "":
+				errs = validateResourceRequest(ctx, false)
+			case r.Method == http.MethodPatch && ctx.ResourceID != "":
+				errs = validateResourceRequest(ctx, true)
+			}
+
+			if len(errs) > 0 {
+				writeValidationErrors(w, errs)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	})
+}
+
+// validateResourceRequest validates the request document of resource create and update requests.
+func validateResourceRequest(ctx *jsonapi.RequestContext, update bool) []*jsonapi.Error {
+	if err := validatePrimaryData(ctx.Document); err != nil {
+		return []*jsonapi.Error{err}
+	}
+
+	if ctx.Document.Data.IsMany() {
+		return []*jsonapi.Error{
+			validationError(http.StatusBadRequest, "/data", "primary data must be a single resource object"),
+		}
+	}
+
+	resource := ctx.Document.Data.First()
+	if resource == nil {
+		return []*jsonapi.Error{
+			validationError(http.StatusBadRequest, "/data", "primary data must not be null"),
+		}
+	}
+
+	errs := make([]*jsonapi.Error, 0)
+
+	if resource.Type == "" {
+		errs = append(errs, validationError(http.StatusBadRequest, "/data/type", "resource type is required"))
+	} else if resource.Type != ctx.ResourceType {
+		errs = append(errs, validationError(http.StatusConflict, "/data/type",
+			fmt.Sprintf("resource type %q does not match endpoint type %q", resource.Type, ctx.ResourceType)))
+	}
+
+	if !update {
+		return errs
+	}
+
+	if resource.ID == "" {
+		errs = append(errs, validationError(http.StatusBadRequest, "/data/id", "resource id is required"))
+	} else if resource.ID != ctx.ResourceID {
+		errs = append(errs, validationError(http.StatusConflict, "/data/id",
+			fmt.Sprintf("resource id %q does not match endpoint id %q", resource.ID, ctx.ResourceID)))
+	}
+
+	return errs
+}
+
+// validateRelationshipRequest validates the request document of relationship update requests.
+func validateRelationshipRequest(method string, ctx *jsonapi.RequestContext) []*jsonapi.Error {
+	switch method {
+	case http.MethodPost, http.MethodPatch, http.MethodDelete:
+	default:
+		return nil
+	}
+
+	if err := validatePrimaryData(ctx.Document); err != nil {
+		return []*jsonapi.Error{err}
+	}
+
+	data := ctx.Document.Data
+	if method != http.MethodPatch && !data.IsMany() {
+		return []*jsonapi.Error{
+			validationError(http.StatusBadRequest, "/data", "primary data must be an array of resource identifiers"),
+		}
+	}
+
+	errs := make([]*jsonapi.Error, 0)
+	for i, item := range data.Items() {
+		pointer := "/data"
+		if data.IsMany() {
+			pointer = "/data/" + strconv.Itoa(i)
+		}
+		errs = append(errs, validateIdentifier(pointer, item)...)
+	}
+
+	return errs
+}
+
+// validateIdentifier validates that the resource is a resource identifier object.
+func validateIdentifier(pointer string, resource *jsonapi.Resource) []*jsonapi.Error {
+	if resource == nil {
+		return []*jsonapi.Error{
+			validationError(http.StatusBadRequest, pointer, "resource identifier must not be null"),
+		}
+	}
+
+	errs := make([]*jsonapi.Error, 0)
+
+	if resource.Type == "" {
+		errs = append(errs, validationError(http.StatusBadRequest, pointer+"/type", "resource type is required"))
+	}
+	if resource.ID == "" && resource.LocalID == "" {
+		errs = append(errs, validationError(http.StatusBadRequest, pointer+"/id", "resource id is required"))
+	}
+	if resource.Attributes != nil {
+		errs = append(errs, validationError(http.StatusBadRequest, pointer+"/attributes",
+			"resource linkage must not contain attributes"))
+	}
+	if resource.Relationships != nil {
+		errs = append(errs, validationError(http.StatusBadRequest, pointer+"/relationships",
+			"resource linkage must not contain relationships"))
+	}
+
+	return errs
+}
+
+// validatePrimaryData validates that the request document contains primary data.
+func validatePrimaryData(doc *jsonapi.Document) *jsonapi.Error {
+	if doc == nil || doc.Data == nil {
+		return validationError(http.StatusBadRequest, "/data", "request document must contain primary data")
+	}
+	return nil
+}
+
+func validationError(status int, pointer string, detail string) *jsonapi.Error {
+	return &jsonapi.Error{
+		Status: strconv.Itoa(status),
+		Title:  http.StatusText(status),
+		Detail: detail,
+		Source: &jsonapi.ErrorSource{Pointer: pointer},
+	}
+}
+
+// writeValidationErrors writes the errors to the response. If the errors do not share
+// a status code, the response status is 400 Bad Request.
+func writeValidationErrors(w http.ResponseWriter, errs []*jsonapi.Error) {
+	status := errs[0].Status
+	for _, err := range errs[1:] {
+		if err.Status != status {
+			status = strconv.Itoa(http.StatusBadRequest)
+			break
+		}
+	}
+
+	code, _ := strconv.Atoi(status)
+	server.Write(w, jsonapi.Document{Errors: errs}, code)
+}
@@ -0,0 +1,435 @@
+package pagination
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gonobo/jsonapi/v2"
+	"github.com/gonobo/jsonapi/v2/query"
+	"github.com/gonobo/jsonapi/v2/server"
+)
+
+const (
+	// CursorVersion is the version of the cursor format produced by [Keyset].
+	CursorVersion = 1
+
+	// ProfileCursorPagination is the URI of the cursor pagination profile.
+	ProfileCursorPagination = "https://jsonapi.org/profiles/ethanresnick/cursor-pagination/"
+
+	// ErrorTypeInvalidParameterValue is the cursor pagination profile's error type for invalid
+	// cursors and page sizes.
+	ErrorTypeInvalidParameterValue = ProfileCursorPagination + "invalid-parameter-value"
+
+	// ErrorTypeMaxSizeExceeded is the cursor pagination profile's error type for page sizes
+	// exceeding the server's maximum.
+	ErrorTypeMaxSizeExceeded = ProfileCursorPagination + "max-size-exceeded"
+
+	// ErrorTypeRangePaginationNotSupported is the cursor pagination profile's error type for
+	// requests providing both "page[after]" and "page[before]".
+	ErrorTypeRangePaginationNotSupported = ProfileCursorPagination + "range-pagination-not-supported"
+)
+
+var (
+	// ErrInvalidCursor is returned when a cursor is malformed, tampered with, or was
+	// issued for a different sort order.
+	ErrInvalidCursor = errors.New("invalid cursor")
+
+	// ErrMissingSortKey is returned when a cursor is encoded for a position whose value of a
+	// sort key is missing or null, which cannot be compared with other values.
+	ErrMissingSortKey = errors.New("missing sort key value")
+)
+
+// Keyset encodes the sort-key values of a boundary resource into opaque cursors, and decodes
+// cursors back into keyset predicates. Cursors are signed with HMAC-SHA256, so clients
+// cannot forge or alter them, and are bound to the sort order they were issued for.
+//
+// The sort keys of a cursor are the requested sort criteria, followed by a unique
+// tiebreaker property ("id" by default) if the criteria do not already contain it.
+type Keyset struct {
+	secret     []byte
+	tiebreaker string
+}
+
+// NewKeyset creates a new keyset cursor codec that signs cursors with the provided secret.
+func NewKeyset(secret []byte, options ...func(*Keyset)) Keyset {
+	keyset := Keyset{secret: secret, tiebreaker: "id"}
+	for _, option := range options {
+		option(&keyset)
+	}
+	return keyset
+}
+
+// WithTiebreaker sets the unique property used to order resources with equal sort-key values.
+func WithTiebreaker(property string) func(*Keyset) {
+	return func(k *Keyset) {
+		k.tiebreaker = property
+	}
+}
+
+// Keys returns the sort keys of cursors issued for the sort criteria.
+func (k Keyset) Keys(sort []query.Sort) []query.Sort {
+	keys := make([]query.Sort, 0, len(sort)+1)
+	for _, criterion := range sort {
+		if criterion.Property == "" {
+			continue
+		}
+		keys = append(keys, criterion)
+		if criterion.Property == k.tiebreaker {
+			return keys
+		}
+	}
+	return append(keys, query.Sort{Property: k.tiebreaker})
+}
+
+// Encode returns a cursor positioned at the provided sort-key values, keyed by property.
+// Supported values are strings, booleans, integers, floats and [time.Time]. If a value is
+// missing or nil, an error wrapping [ErrMissingSortKey] is returned.
+func (k Keyset) Encode(sort []query.Sort, values map[string]any) (string, error) {
+	payload := cursorPayload{Version: CursorVersion}
+
+	for _, key := range k.Keys(sort) {
+		value := values[key.Property]
+		if value == nil {
+			return "", fmt.Errorf("encode cursor: %w %q", ErrMissingSortKey, key.Property)
+		}
+		typ, text, err := encodeCursorValue(value)
+		if err != nil {
+			return "", fmt.Errorf("encode cursor: sort key %q: %w", key.Property, err)
+		}
+		payload.Keys = append(payload.Keys, cursorKey{
+			Property:   key.Property,
+			Descending: key.Descending,
+			Type:       typ,
+			Value:      text,
+		})
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("encode cursor: %w", err)
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(data)
+	signature := base64.RawURLEncoding.EncodeToString(k.sign(encoded))
+	return encoded + "." + signature, nil
+}
+
+// EncodeResource returns a cursor positioned at the resource. Sort-key values are read from
+// the resource's attributes; the "id" property refers to the resource id.
+func (k Keyset) EncodeResource(sort []query.Sort, resource *jsonapi.Resource) (string, error) {
+	values := make(map[string]any)
+	for _, key := range k.Keys(sort) {
+		if key.Property == "id" {
+			values["id"] = resource.ID
+		} else if value, ok := resource.Attributes[key.Property]; ok {
+			values[key.Property] = value
+		}
+	}
+	return k.Encode(sort, values)
+}
+
+// Decode verifies the cursor and returns its sort-key values, in the order of [Keyset.Keys].
+// Decode returns an error wrapping [ErrInvalidCursor] if the cursor's signature is invalid,
+// or if it was issued for different sort criteria.
+func (k Keyset) Decode(cursor string, sort []query.Sort) ([]any, error) {
+	encoded, signature, ok := strings.Cut(cursor, ".")
+	if !ok {
+		return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidCursor)
+	}
+
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, k.sign(encoded)) {
+		return nil, fmt.Errorf("%w: signature mismatch", ErrInvalidCursor)
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidCursor, err)
+	}
+
+	payload := cursorPayload{}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidCursor, err)
+	}
+
+	if payload.Version != CursorVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidCursor, payload.Version)
+	}
+
+	keys := k.Keys(sort)
+	if len(keys) != len(payload.Keys) {
+		return nil, fmt.Errorf("%w: cursor does not match sort criteria", ErrInvalidCursor)
+	}
+
+	values := make([]any, len(keys))
+	for i, key := range keys {
+		item := payload.Keys[i]
+		if item.Property != key.Property || item.Descending != key.Descending {
+			return nil, fmt.Errorf("%w: cursor does not match sort criteria", ErrInvalidCursor)
+		}
+		value, err := decodeCursorValue(item.Type, item.Value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: sort key %q: %s", ErrInvalidCursor, key.Property, err)
+		}
+		values[i] = value
+	}
+
+	return values, nil
+}
+
+// After returns a predicate selecting the resources that sort after the cursor.
+func (k Keyset) After(cursor string, sort []query.Sort) (*KeysetPredicate, error) {
+	return k.predicate(cursor, sort, false)
+}
+
+// Before returns a predicate selecting the resources that sort before the cursor.
+func (k Keyset) Before(cursor string, sort []query.Sort) (*KeysetPredicate, error) {
+	return k.predicate(cursor, sort, true)
+}
+
+// Filter returns the filter expression selecting the resources of the requested page:
+// those after "page[after]" and before "page[before]". If the page contains no cursors,
+// an [query.IdentityFilter] is returned. Invalid cursors are reported with [jsonapi.Error]
+// values referencing the parameter in their source, as described by the cursor
+// pagination profile.
+func (k Keyset) Filter(page query.Page, sort []query.Sort) (query.FilterExpression, error) {
+	var expr query.FilterExpression = query.IdentityFilter{}
+
+	if page.Cursor != "" {
+		after, err := k.After(page.Cursor, sort)
+		if err != nil {
+			return nil, invalidParameterError(query.ParamPageAfter, err)
+		}
+		expr = after
+	}
+
+	if page.Before != "" {
+		before, err := k.Before(page.Before, sort)
+		if err != nil {
+			return nil, invalidParameterError(query.ParamPageBefore, err)
+		}
+		if page.Cursor != "" {
+			expr = &query.AndFilter{Left: expr, Right: before}
+		} else {
+			expr = before
+		}
+	}
+
+	return expr, nil
+}
+
+// WriteResourceCursors adds the cursor of each primary data resource to its "page" meta
+// member, as described by the cursor pagination profile. Resources whose sort-key values
+// are missing or null cannot be positioned, and are written without a cursor.
+func (k Keyset) WriteResourceCursors(sort []query.Sort) server.WriteOptions {
+	return server.WithDocumentOptions(func(w http.ResponseWriter, d *jsonapi.Document) error {
+		if d.Data == nil {
+			return nil
+		}
+		for _, item := range d.Data.Items() {
+			cursor, err := k.EncodeResource(sort, item)
+			if errors.Is(err, ErrMissingSortKey) {
+				continue
+			} else if err != nil {
+				return err
+			}
+			if item.Meta == nil {
+				item.Meta = jsonapi.Meta{}
+			}
+			item.Meta[MetaKeyPage] = map[string]any{"cursor": cursor}
+		}
+		return nil
+	})
+}
+
+// CursorPaginationProfile returns the cursor pagination profile. Servers applying the profile
+// paginate with the [CursorStrategy] and a [Keyset]. When applied to collection documents,
+// the profile adds the "prev" and "next" links that are missing as null links, since the
+// profile requires both to be present.
+func CursorPaginationProfile() jsonapi.Profile {
+	return jsonapi.Profile{
+		URI: ProfileCursorPagination,
+		Encode: func(d *jsonapi.Document) error {
+			if d.Data == nil || !d.Data.IsMany() {
+				return nil
+			}
+			if d.Links == nil {
+				d.Links = jsonapi.Links{}
+			}
+			for _, name := range []string{LinkPrev, LinkNext} {
+				if _, ok := d.Links[name]; !ok {
+					d.Links[name] = nil
+				}
+			}
+			return nil
+		},
+	}
+}
+
+func (k Keyset) predicate(cursor string, sort []query.Sort, before bool) (*KeysetPredicate, error) {
+	values, err := k.Decode(cursor, sort)
+	if err != nil {
+		return nil, err
+	}
+	return &KeysetPredicate{Keys: k.Keys(sort), Values: values, Before: before}, nil
+}
+
+func (k Keyset) sign(encoded string) []byte {
+	mac := hmac.New(sha256.New, k.secret)
+	mac.Write([]byte(encoded))
+	return mac.Sum(nil)
+}
+
+// KeysetPredicate selects the resources that sort after (or before) a cursor position.
+// KeysetPredicate implements [query.FilterExpression]; it is evaluated as the expansion
+// returned by [KeysetPredicate.Expression], while evaluators that support keyset
+// predicates natively can read the typed sort-key values directly.
+type KeysetPredicate struct {
+	Keys   []query.Sort // The sort keys.
+	Values []any        // The sort-key values of the cursor position, in the order of Keys.
+	Before bool         // If true, resources before the position are selected; otherwise, after.
+}
+
+// Expression expands the predicate into a disjunction of filters. For sort keys (a, b)
+// in ascending order and values (x, y), resources after the position satisfy:
+//
+//	(a > x) || (a = x && b > y)
+func (p KeysetPredicate) Expression() query.FilterExpression {
+	var expr query.FilterExpression
+
+	for i := len(p.Keys) - 1; i >= 0; i-- {
+		var term query.FilterExpression = &query.Filter{
+			Name:      p.Keys[i].Property,
+			Condition: string(p.condition(p.Keys[i])),
+			Value:     formatCursorValue(p.Values[i]),
+		}
+		for j := i - 1; j >= 0; j-- {
+			term = &query.AndFilter{
+				Left: &query.Filter{
+					Name:      p.Keys[j].Property,
+					Condition: string(query.Equal),
+					Value:     formatCursorValue(p.Values[j]),
+				},
+				Right: term,
+			}
+		}
+		if expr == nil {
+			expr = term
+		} else {
+			expr = &query.OrFilter{Left: term, Right: expr}
+		}
+	}
+
+	if expr == nil {
+		return query.IdentityFilter{}
+	}
+
+	return expr
+}
+
+// String returns a string representation of the predicate's expansion.
+func (p KeysetPredicate) String() string {
+	return p.Expression().String()
+}
+
+// ApplyFilterEvaluator applies the evaluator to the predicate's expansion.
+func (p *KeysetPredicate) ApplyFilterEvaluator(e query.FilterEvaluator) error {
+	return p.Expression().ApplyFilterEvaluator(e)
+}
+
+func (p KeysetPredicate) condition(key query.Sort) query.FilterCondition {
+	if key.Descending != p.Before {
+		return query.LessThan
+	}
+	return query.GreaterThan
+}
+
+type cursorPayload struct {
+	Version int         `json:"v"`
+	Keys    []cursorKey `json:"k"`
+}
+
+type cursorKey struct {
+	Property   string `json:"p"`
+	Descending bool   `json:"d,omitempty"`
+	Type       string `json:"t"`
+	Value      string `json:"v"`
+}
+
+func encodeCursorValue(value any) (string, string, error) {
+	if t, ok := value.(time.Time); ok {
+		return "time", t.Format(time.RFC3339Nano), nil
+	}
+
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.String:
+		return "string", v.String(), nil
+	case reflect.Bool:
+		return "bool", strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "int", strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "uint", strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return "float", strconv.FormatFloat(v.Float(), 'g', -1, 64), nil
+	}
+
+	return "", "", fmt.Errorf("unsupported value type %T", value)
+}
+
+func decodeCursorValue(typ string, text string) (any, error) {
+	switch typ {
+	case "string":
+		return text, nil
+	case "bool":
+		return strconv.ParseBool(text)
+	case "int":
+		return strconv.ParseInt(text, 10, 64)
+	case "uint":
+		return strconv.ParseUint(text, 10, 64)
+	case "float":
+		return strconv.ParseFloat(text, 64)
+	case "time":
+		return time.Parse(time.RFC3339Nano, text)
+	}
+	return nil, fmt.Errorf("unsupported value type %q", typ)
+}
+
+func formatCursorValue(value any) string {
+	_, text, err := encodeCursorValue(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return text
+}
+
+func invalidParameterError(param string, cause error) error {
+	return cursorProfileError(ErrorTypeInvalidParameterValue, param, fmt.Sprintf("parse %s: %s", param, cause))
+}
+
+func maxSizeExceededError(size int, maximum int) error {
+	err := cursorProfileError(ErrorTypeMaxSizeExceeded, query.ParamPageSize,
+		fmt.Sprintf("page size %d exceeds the maximum of %d", size, maximum))
+	err.Meta = jsonapi.Meta{MetaKeyPage: map[string]any{"maxSize": maximum}}
+	return err
+}
+
+func cursorProfileError(typ string, param string, detail string) jsonapi.Error {
+	return jsonapi.Error{
+		Links:  jsonapi.Links{"type": &jsonapi.Link{Href: typ}},
+		Status: strconv.Itoa(http.StatusBadRequest),
+		Title:  "Invalid Query Parameter",
+		Detail: detail,
+		Source: &jsonapi.ErrorSource{Parameter: param},
+	}
+}
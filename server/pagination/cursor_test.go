@@ -0,0 +1,182 @@
+package pagination_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gonobo/jsonapi/v2"
+	"github.com/gonobo/jsonapi/v2/query"
+	"github.com/gonobo/jsonapi/v2/server"
+	"github.com/gonobo/jsonapi/v2/server/pagination"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyset(t *testing.T) {
+	keyset := pagination.NewKeyset([]byte("secret"))
+	sort := []query.Sort{{Property: "published", Descending: true}, {Property: "rank"}}
+	published := time.Date(2024, time.March, 1, 12, 30, 0, 0, time.UTC)
+
+	cursor, err := keyset.Encode(sort, map[string]any{"published": published, "rank": 3, "id": "42"})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	t.Run("round trips typed values", func(t *testing.T) {
+		values, err := keyset.Decode(cursor, sort)
+		assert.NoError(t, err)
+		assert.Equal(t, []any{published, int64(3), "42"}, values)
+	})
+
+	t.Run("appends tiebreaker", func(t *testing.T) {
+		assert.Equal(t, []query.Sort{{Property: "title"}, {Property: "id"}}, keyset.Keys([]query.Sort{{Property: "title"}}))
+		assert.Equal(t, []query.Sort{{Property: "id", Descending: true}}, keyset.Keys([]query.Sort{{Property: "id", Descending: true}}))
+		assert.Equal(t, []query.Sort{{Property: "id"}}, keyset.Keys([]query.Sort{{Property: ""}}))
+	})
+
+	for _, tc := range []struct {
+		name   string
+		cursor string
+		sort   []query.Sort
+	}{
+		{name: "tampered payload", cursor: "x" + cursor, sort: sort},
+		{name: "tampered signature", cursor: cursor + "x", sort: sort},
+		{name: "malformed", cursor: "abc", sort: sort},
+		{name: "different sort", cursor: cursor, sort: []query.Sort{{Property: "published"}, {Property: "rank"}}},
+		{name: "different secret", cursor: cursor, sort: sort},
+	} {
+		t.Run("rejects "+tc.name, func(t *testing.T) {
+			codec := keyset
+			if tc.name == "different secret" {
+				codec = pagination.NewKeyset([]byte("other"))
+			}
+			_, err := codec.Decode(tc.cursor, tc.sort)
+			assert.ErrorIs(t, err, pagination.ErrInvalidCursor)
+		})
+	}
+
+	t.Run("rejects unsupported values", func(t *testing.T) {
+		_, err := keyset.Encode(sort, map[string]any{"published": published, "rank": []int{1}, "id": "1"})
+		assert.Error(t, err)
+		_, err = keyset.Encode(sort, map[string]any{"published": published, "id": "1"})
+		assert.ErrorIs(t, err, pagination.ErrMissingSortKey)
+		_, err = keyset.Encode(sort, map[string]any{"published": published, "rank": nil, "id": "1"})
+		assert.ErrorIs(t, err, pagination.ErrMissingSortKey)
+	})
+
+	t.Run("expands predicates", func(t *testing.T) {
+		after, err := keyset.After(cursor, sort)
+		if assert.NoError(t, err) {
+			assert.Equal(t,
+				"([published lt '2024-03-01T12:30:00Z'] || "+
+					"(([published eq '2024-03-01T12:30:00Z'] && [rank gt '3']) || "+
+					"([published eq '2024-03-01T12:30:00Z'] && ([rank eq '3'] && [id gt '42']))))",
+				after.String())
+		}
+
+		before, err := keyset.Before(cursor, sort)
+		if assert.NoError(t, err) {
+			assert.True(t, strings.HasPrefix(before.String(), "([published gt '2024-03-01T12:30:00Z'] ||"))
+			assert.Contains(t, before.String(), "[id lt '42']")
+		}
+	})
+}
+
+func TestKeysetFilter(t *testing.T) {
+	keyset := pagination.NewKeyset([]byte("secret"))
+	sort := []query.Sort{{Property: "title"}}
+	cursor, err := keyset.EncodeResource(sort, &jsonapi.Resource{
+		ID: "1", Type: "articles", Attributes: map[string]any{"title": "a"},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	t.Run("no cursors", func(t *testing.T) {
+		expr, err := keyset.Filter(query.Page{}, sort)
+		assert.NoError(t, err)
+		assert.Equal(t, query.IdentityFilter{}, expr)
+	})
+
+	t.Run("range", func(t *testing.T) {
+		expr, err := keyset.Filter(query.Page{Cursor: cursor, Before: cursor}, sort)
+		if assert.NoError(t, err) {
+			assert.IsType(t, &query.AndFilter{}, expr)
+		}
+	})
+
+	t.Run("invalid cursor", func(t *testing.T) {
+		_, err := keyset.Filter(query.Page{Before: "bogus"}, sort)
+		var jsonapierr jsonapi.Error
+		if assert.True(t, errors.As(err, &jsonapierr)) {
+			assert.Equal(t, "400", jsonapierr.Status)
+			assert.Equal(t, "page[before]", jsonapierr.Source.Parameter)
+			assert.Equal(t, pagination.ErrorTypeInvalidParameterValue, jsonapierr.Links["type"].Href)
+		}
+	})
+}
+
+func TestWriteResourceCursors(t *testing.T) {
+	type article struct {
+		ID    string `jsonapi:"primary,articles"`
+		Title string `jsonapi:"attr,title"`
+	}
+
+	keyset := pagination.NewKeyset([]byte("secret"))
+	sort := []query.Sort{{Property: "title"}}
+	w := httptest.NewRecorder()
+	server.Write(w, []article{{ID: "1", Title: "a"}, {ID: "2", Title: "b"}}, http.StatusOK,
+		keyset.WriteResourceCursors(sort))
+
+	doc := jsonapi.Document{}
+	err := jsonapi.Decode(w.Result().Body, &doc)
+	if assert.NoError(t, err) && assert.Len(t, doc.Data.Items(), 2) {
+		for _, item := range doc.Data.Items() {
+			page := item.Meta["page"].(map[string]any)
+			values, err := keyset.Decode(page["cursor"].(string), sort)
+			assert.NoError(t, err)
+			assert.Equal(t, []any{item.Attributes["title"], item.ID}, values)
+		}
+	}
+
+	t.Run("skips resources without sort key values", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		server.Write(w, jsonapi.NewMultiDocument(
+			&jsonapi.Resource{Type: "articles", ID: "1", Attributes: map[string]any{"title": "a"}},
+			&jsonapi.Resource{Type: "articles", ID: "2", Attributes: map[string]any{"title": nil}},
+			&jsonapi.Resource{Type: "articles", ID: "3"},
+		), http.StatusOK, keyset.WriteResourceCursors(sort))
+
+		doc := jsonapi.Document{}
+		assert.Equal(t, http.StatusOK, w.Code)
+		if assert.NoError(t, jsonapi.Decode(w.Result().Body, &doc)) && assert.Len(t, doc.Data.Items(), 3) {
+			items := doc.Data.Items()
+			assert.Contains(t, items[0].Meta, "page")
+			assert.NotContains(t, items[1].Meta, "page")
+			assert.NotContains(t, items[2].Meta, "page")
+		}
+	})
+}
+
+func TestCursorPaginationProfile(t *testing.T) {
+	profile := pagination.CursorPaginationProfile()
+	assert.Equal(t, pagination.ProfileCursorPagination, profile.URI)
+
+	next := &jsonapi.Link{Href: "https://example.com/articles?page[after]=abc"}
+	doc := jsonapi.NewMultiDocument(&jsonapi.Resource{Type: "articles", ID: "1"})
+	doc.Links = jsonapi.Links{pagination.LinkNext: next}
+	assert.NoError(t, profile.Encode(doc))
+	assert.Equal(t, jsonapi.Links{pagination.LinkPrev: nil, pagination.LinkNext: next}, doc.Links)
+
+	data, err := json.Marshal(doc)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"prev":null`)
+
+	doc = jsonapi.NewSingleDocument(&jsonapi.Resource{Type: "articles", ID: "1"})
+	assert.NoError(t, profile.Encode(doc))
+	assert.Nil(t, doc.Links)
+}
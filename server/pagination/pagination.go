@@ -0,0 +1,230 @@
+// Package pagination parses JSON:API pagination criteria and generates the navigation
+// links and metadata of paginated collection responses.
+//
+// A [Paginator] combines a [Strategy] -- page number, offset, or keyset cursor -- with
+// default and maximum page limits:
+//
+//	paginator := pagination.New(pagination.NumberStrategy{}, pagination.WithMaxLimit(100))
+//	handler := server.Handle(mux, middleware.UsePageQueryParser(paginator))
+//
+// Handlers describe the page they retrieved with a [Result], and the paginator writes
+// the "first", "prev", "next" and "last" links and the "page" meta member:
+//
+//	ctx := jsonapi.FromContext(r.Context())
+//	result := pagination.Result{Total: total}
+//	server.Write(w, items, http.StatusOK, paginator.WriteNavigation(r, ctx.Pagination, result))
+//
+// With the [CursorStrategy], a [Keyset] issues opaque, signed cursors for the boundary
+// resources of a page, and converts the cursors of a request into filter expressions. Both
+// follow the cursor pagination profile (see [CursorPaginationProfile]).
+package pagination
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gonobo/jsonapi/v2/query"
+	"github.com/gonobo/jsonapi/v2/query/page"
+	"github.com/gonobo/jsonapi/v2/server"
+)
+
+const (
+	LinkFirst = "first"
+	LinkPrev  = "prev"
+	LinkNext  = "next"
+	LinkLast  = "last"
+
+	// MetaKeyPage is the top-level meta member containing pagination metadata.
+	MetaKeyPage = "page"
+
+	// TotalUnknown indicates that the total number of resources in a collection is unknown.
+	TotalUnknown = -1
+)
+
+// Result describes a page of resources retrieved by a handler.
+type Result struct {
+	Total       int    // The total number of resources in the collection, or [TotalUnknown].
+	HasMore     bool   // If true, more resources follow the page in the direction of travel.
+	StartCursor string // The cursor of the first resource in the page.
+	EndCursor   string // The cursor of the last resource in the page.
+}
+
+// Strategy defines how pages of a collection are addressed.
+type Strategy interface {
+	// Parse reads the strategy's pagination parameters from the query into the criteria.
+	// The limit has already been parsed.
+	Parse(params page.Params, criteria *query.Page) error
+	// Navigate returns the criteria of the navigation pages, keyed by link name.
+	// Links that do not apply to the current page are omitted.
+	Navigate(current query.Page, result Result) map[string]query.Page
+	// Encode replaces the strategy's pagination parameters in the query with the criteria.
+	Encode(params url.Values, criteria query.Page)
+	// Meta returns the strategy's pagination metadata of the current page.
+	Meta(current query.Page, result Result) map[string]any
+}
+
+// LimitStrategy is implemented by strategies that read the page limit from their own query
+// parameter instead of "page[limit]", and validate it themselves.
+type LimitStrategy interface {
+	Strategy
+	// LimitParameter returns the name of the page limit query parameter.
+	LimitParameter() string
+	// ParseLimit reads the page limit from the query; zero means the client did not provide
+	// one. The maximum is the paginator's maximum limit, or zero if there is none.
+	ParseLimit(params page.Params, maximum int) (int, error)
+}
+
+// Paginator parses pagination criteria and writes navigation links using a [Strategy].
+// Paginator implements the middleware.PageQueryParser and middleware.PageLimitParameter interfaces.
+type Paginator struct {
+	Strategy     Strategy // The pagination strategy.
+	DefaultLimit int      // The page limit used when the client does not provide one.
+	MaxLimit     int      // The maximum page limit; larger client limits are reduced. Zero means no maximum.
+}
+
+// New creates a new paginator with the provided strategy.
+func New(strategy Strategy, options ...func(*Paginator)) Paginator {
+	paginator := Paginator{Strategy: strategy}
+	for _, option := range options {
+		option(&paginator)
+	}
+	return paginator
+}
+
+// WithDefaultLimit sets the page limit used when the client does not provide one.
+func WithDefaultLimit(limit int) func(*Paginator) {
+	return func(p *Paginator) {
+		p.DefaultLimit = limit
+	}
+}
+
+// WithMaxLimit sets the maximum page limit.
+func WithMaxLimit(limit int) func(*Paginator) {
+	return func(p *Paginator) {
+		p.MaxLimit = limit
+	}
+}
+
+// ParsePageQuery parses the pagination criteria from the request query. Invalid parameters
+// are reported with [jsonapi.Error] values referencing the parameter in their source.
+func (p Paginator) ParsePageQuery(r *http.Request) (query.Page, error) {
+	criteria := query.Page{}
+	params := make(page.Params)
+	for k, v := range r.URL.Query() {
+		params[k] = v[0]
+	}
+
+	limit, err := p.parseLimit(params)
+	if err != nil {
+		return criteria, err
+	}
+
+	criteria.Limit = p.limit(limit)
+
+	if err := p.Strategy.Parse(params, &criteria); err != nil {
+		return criteria, err
+	}
+
+	return criteria, nil
+}
+
+// Links returns the navigation link URLs of the current page, keyed by link name. The links
+// are derived from the request URL, preserving all non-pagination query parameters.
+func (p Paginator) Links(r *http.Request, current query.Page, result Result) map[string]string {
+	current.Limit = p.limit(current.Limit)
+	pages := p.Strategy.Navigate(current, result)
+	links := make(map[string]string, len(pages))
+
+	for name, criteria := range pages {
+		criteria.Limit = current.Limit
+		links[name] = p.href(r.URL, criteria)
+	}
+
+	return links
+}
+
+// Meta returns the pagination metadata of the current page.
+func (p Paginator) Meta(current query.Page, result Result) map[string]any {
+	current.Limit = p.limit(current.Limit)
+	meta := p.Strategy.Meta(current, result)
+	if current.Limit > 0 {
+		meta["limit"] = current.Limit
+	}
+	if result.Total >= 0 {
+		meta["total"] = result.Total
+	}
+	return meta
+}
+
+// WriteNavigation adds the navigation links of the current page to the response document's
+// links attribute, and its pagination metadata to the document's "page" meta member.
+func (p Paginator) WriteNavigation(r *http.Request, current query.Page, result Result) server.WriteOptions {
+	links := p.Links(r, current, result)
+	options := make([]server.WriteOptions, 0, len(links)+1)
+	for name, href := range links {
+		options = append(options, server.WriteLink(name, href))
+	}
+	options = append(options, server.WriteMeta(MetaKeyPage, p.Meta(current, result)))
+
+	return func(c *server.Config) {
+		c.ApplyWriteOptions(options...)
+	}
+}
+
+func (p Paginator) parseLimit(params page.Params) (int, error) {
+	if s, ok := p.Strategy.(LimitStrategy); ok {
+		return s.ParseLimit(params, p.MaxLimit)
+	}
+
+	limit, err := params.Limit()
+	if err == nil && limit < 0 {
+		err = errors.New("must not be negative")
+	}
+	if err != nil {
+		return 0, page.NewParameterError(query.ParamPageLimit, err)
+	}
+	return limit, nil
+}
+
+// LimitParameter returns the name of the query parameter the page limit is read from:
+// "page[limit]", unless the strategy reads the limit from its own parameter.
+func (p Paginator) LimitParameter() string {
+	if s, ok := p.Strategy.(LimitStrategy); ok {
+		return s.LimitParameter()
+	}
+	return query.ParamPageLimit
+}
+
+func (p Paginator) limit(limit int) int {
+	if limit == 0 {
+		limit = p.DefaultLimit
+	}
+	if p.MaxLimit > 0 && limit > p.MaxLimit {
+		limit = p.MaxLimit
+	}
+	return limit
+}
+
+func (p Paginator) href(base *url.URL, criteria query.Page) string {
+	requestURL := *base
+	params := requestURL.Query()
+
+	// remove the pagination parameters of the current page.
+	for key := range params {
+		if strings.HasPrefix(key, "page[") {
+			params.Del(key)
+		}
+	}
+
+	p.Strategy.Encode(params, criteria)
+
+	if criteria.Limit > 0 {
+		params.Set(p.LimitParameter(), strconv.Itoa(criteria.Limit))
+	}
+
+	requestURL.RawQuery = params.Encode()
+	return requestURL.String()
+}
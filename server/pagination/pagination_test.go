@@ -0,0 +1,288 @@
+package pagination_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gonobo/jsonapi/v2"
+	"github.com/gonobo/jsonapi/v2/query"
+	"github.com/gonobo/jsonapi/v2/server"
+	"github.com/gonobo/jsonapi/v2/server/middleware"
+	"github.com/gonobo/jsonapi/v2/server/pagination"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePageQuery(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		paginator pagination.Paginator
+		query     string
+		want      query.Page
+		wantParam string
+		wantType  string
+		wantMeta  jsonapi.Meta
+	}{
+		{
+			name:      "page number",
+			paginator: pagination.New(pagination.NumberStrategy{}),
+			query:     "page[number]=3&page[limit]=10",
+			want:      query.Page{PageNumber: 3, Limit: 10},
+		},
+		{
+			name:      "page number defaults to first page",
+			paginator: pagination.New(pagination.NumberStrategy{}, pagination.WithDefaultLimit(20)),
+			want:      query.Page{PageNumber: 1, Limit: 20},
+		},
+		{
+			name:      "limit is reduced to maximum",
+			paginator: pagination.New(pagination.NumberStrategy{}, pagination.WithMaxLimit(50)),
+			query:     "page[limit]=500",
+			want:      query.Page{PageNumber: 1, Limit: 50},
+		},
+		{
+			name:      "invalid page number",
+			paginator: pagination.New(pagination.NumberStrategy{}),
+			query:     "page[number]=abc",
+			wantParam: "page[number]",
+		},
+		{
+			name:      "negative limit",
+			paginator: pagination.New(pagination.NumberStrategy{}),
+			query:     "page[limit]=-1",
+			wantParam: "page[limit]",
+		},
+		{
+			name:      "offset",
+			paginator: pagination.New(pagination.OffsetStrategy{}),
+			query:     "page[offset]=40&page[limit]=20",
+			want:      query.Page{Offset: 40, Limit: 20},
+		},
+		{
+			name:      "negative offset",
+			paginator: pagination.New(pagination.OffsetStrategy{}),
+			query:     "page[offset]=-20",
+			wantParam: "page[offset]",
+		},
+		{
+			name:      "cursor",
+			paginator: pagination.New(pagination.CursorStrategy{}, pagination.WithDefaultLimit(10)),
+			query:     "page[after]=abc",
+			want:      query.Page{Cursor: "abc", Limit: 10},
+		},
+		{
+			name:      "before cursor",
+			paginator: pagination.New(pagination.CursorStrategy{}),
+			query:     "page[before]=abc&page[size]=5",
+			want:      query.Page{Before: "abc", Limit: 5},
+		},
+		{
+			name:      "conflicting cursors",
+			paginator: pagination.New(pagination.CursorStrategy{}),
+			query:     "page[after]=abc&page[before]=def",
+			wantParam: "page[before]",
+			wantType:  pagination.ErrorTypeRangePaginationNotSupported,
+		},
+		{
+			name:      "invalid page size",
+			paginator: pagination.New(pagination.CursorStrategy{}),
+			query:     "page[size]=abc",
+			wantParam: "page[size]",
+			wantType:  pagination.ErrorTypeInvalidParameterValue,
+		},
+		{
+			name:      "zero page size",
+			paginator: pagination.New(pagination.CursorStrategy{}),
+			query:     "page[size]=0",
+			wantParam: "page[size]",
+			wantType:  pagination.ErrorTypeInvalidParameterValue,
+		},
+		{
+			name:      "page size exceeds maximum",
+			paginator: pagination.New(pagination.CursorStrategy{}, pagination.WithMaxLimit(50)),
+			query:     "page[size]=500",
+			wantParam: "page[size]",
+			wantType:  pagination.ErrorTypeMaxSizeExceeded,
+			wantMeta:  jsonapi.Meta{"page": map[string]any{"maxSize": 50}},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "https://example.com/things?"+tc.query, nil)
+			got, err := tc.paginator.ParsePageQuery(r)
+
+			if tc.wantParam == "" {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.want, got)
+				return
+			}
+
+			var jsonapierr jsonapi.Error
+			if assert.True(t, errors.As(err, &jsonapierr)) {
+				assert.Equal(t, "400", jsonapierr.Status)
+				assert.Equal(t, tc.wantParam, jsonapierr.Source.Parameter)
+				if tc.wantType != "" {
+					assert.Equal(t, tc.wantType, jsonapierr.Links["type"].Href)
+				}
+				assert.Equal(t, tc.wantMeta, jsonapierr.Meta)
+			}
+		})
+	}
+}
+
+func TestLinks(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		paginator pagination.Paginator
+		query     string
+		result    pagination.Result
+		want      map[string]string
+		wantMeta  map[string]any
+	}{
+		{
+			name:      "page number with total",
+			paginator: pagination.New(pagination.NumberStrategy{}),
+			query:     "sort=title&page[number]=2&page[limit]=10",
+			result:    pagination.Result{Total: 35},
+			want: map[string]string{
+				"first": "page[limit]=10&page[number]=1&sort=title",
+				"prev":  "page[limit]=10&page[number]=1&sort=title",
+				"next":  "page[limit]=10&page[number]=3&sort=title",
+				"last":  "page[limit]=10&page[number]=4&sort=title",
+			},
+			wantMeta: map[string]any{"number": 2, "pages": 4, "limit": 10, "total": 35},
+		},
+		{
+			name:      "last page number",
+			paginator: pagination.New(pagination.NumberStrategy{}),
+			query:     "page[number]=4&page[limit]=10",
+			result:    pagination.Result{Total: 35},
+			want: map[string]string{
+				"first": "page[limit]=10&page[number]=1",
+				"prev":  "page[limit]=10&page[number]=3",
+				"last":  "page[limit]=10&page[number]=4",
+			},
+			wantMeta: map[string]any{"number": 4, "pages": 4, "limit": 10, "total": 35},
+		},
+		{
+			name:      "page number without total",
+			paginator: pagination.New(pagination.NumberStrategy{}, pagination.WithDefaultLimit(5)),
+			result:    pagination.Result{Total: pagination.TotalUnknown, HasMore: true},
+			want: map[string]string{
+				"first": "page[limit]=5&page[number]=1",
+				"next":  "page[limit]=5&page[number]=2",
+			},
+			wantMeta: map[string]any{"number": 1, "limit": 5},
+		},
+		{
+			name:      "empty collection",
+			paginator: pagination.New(pagination.NumberStrategy{}, pagination.WithDefaultLimit(5)),
+			result:    pagination.Result{Total: 0},
+			want: map[string]string{
+				"first": "page[limit]=5&page[number]=1",
+				"last":  "page[limit]=5&page[number]=1",
+			},
+			wantMeta: map[string]any{"number": 1, "pages": 1, "limit": 5, "total": 0},
+		},
+		{
+			name:      "offset with total",
+			paginator: pagination.New(pagination.OffsetStrategy{}),
+			query:     "page[offset]=10&page[limit]=20",
+			result:    pagination.Result{Total: 45},
+			want: map[string]string{
+				"first": "page[limit]=20&page[offset]=0",
+				"prev":  "page[limit]=20&page[offset]=0",
+				"next":  "page[limit]=20&page[offset]=30",
+				"last":  "page[limit]=20&page[offset]=40",
+			},
+			wantMeta: map[string]any{"offset": 10, "limit": 20, "total": 45},
+		},
+		{
+			name:      "cursor forward",
+			paginator: pagination.New(pagination.CursorStrategy{}),
+			query:     "page[after]=b&page[size]=2",
+			result:    pagination.Result{Total: pagination.TotalUnknown, HasMore: true, StartCursor: "c", EndCursor: "d"},
+			want: map[string]string{
+				"first": "page[size]=2",
+				"prev":  "page[before]=c&page[size]=2",
+				"next":  "page[after]=d&page[size]=2",
+			},
+			wantMeta: map[string]any{"hasMore": true, "limit": 2},
+		},
+		{
+			name:      "cursor backward at start",
+			paginator: pagination.New(pagination.CursorStrategy{}),
+			query:     "page[before]=c&page[size]=2",
+			result:    pagination.Result{Total: 4, StartCursor: "a", EndCursor: "b"},
+			want: map[string]string{
+				"first": "page[size]=2",
+				"next":  "page[after]=b&page[size]=2",
+			},
+			wantMeta: map[string]any{"hasMore": false, "limit": 2, "total": 4},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "https://example.com/things?"+tc.query, nil)
+			current, err := tc.paginator.ParsePageQuery(r)
+			assert.NoError(t, err)
+
+			links := tc.paginator.Links(r, current, tc.result)
+			got := make(map[string]string, len(links))
+			for name, href := range links {
+				u, err := url.Parse(href)
+				if assert.NoError(t, err) {
+					assert.Equal(t, "/things", u.Path)
+					got[name], _ = url.QueryUnescape(u.RawQuery)
+				}
+			}
+
+			assert.Equal(t, tc.want, got)
+			assert.Equal(t, tc.wantMeta, tc.paginator.Meta(current, tc.result))
+		})
+	}
+}
+
+func TestWriteNavigation(t *testing.T) {
+	paginator := pagination.New(pagination.NumberStrategy{}, pagination.WithDefaultLimit(1))
+	mux := server.ResourceMux{"things": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := jsonapi.FromContext(r.Context())
+		result := pagination.Result{Total: 3}
+		server.Write(w, jsonapi.NewMultiDocument(), http.StatusOK, paginator.WriteNavigation(r, ctx.Pagination, result))
+	})}
+	handler := server.Handle(mux, middleware.UsePageQueryParser(paginator))
+
+	t.Run("writes links and meta", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "https://example.com/things?page[number]=2", nil))
+		assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+
+		doc := jsonapi.Document{}
+		err := jsonapi.Decode(w.Result().Body, &doc)
+		if assert.NoError(t, err) {
+			assert.ElementsMatch(t, []string{"first", "prev", "next", "last"}, keys(doc.Links))
+			assert.Equal(t, "https://example.com/things?page%5Blimit%5D=1&page%5Bnumber%5D=3", doc.Links["next"].Href)
+			assert.Equal(t, map[string]any{"number": 2.0, "pages": 3.0, "limit": 1.0, "total": 3.0}, doc.Meta["page"])
+		}
+	})
+
+	t.Run("reports invalid parameters", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "https://example.com/things?page[number]=x", nil))
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+
+		doc := jsonapi.Document{}
+		err := jsonapi.Decode(w.Result().Body, &doc)
+		if assert.NoError(t, err) && assert.Len(t, doc.Errors, 1) {
+			assert.Equal(t, "page[number]", doc.Errors[0].Source.Parameter)
+		}
+	})
+}
+
+func keys(links jsonapi.Links) []string {
+	names := make([]string, 0, len(links))
+	for name := range links {
+		names = append(names, name)
+	}
+	return names
+}
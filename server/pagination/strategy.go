@@ -0,0 +1,206 @@
+package pagination
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+
+	"github.com/gonobo/jsonapi/v2/query"
+	"github.com/gonobo/jsonapi/v2/query/page"
+)
+
+// NumberStrategy addresses pages by their one-based page number, using the
+// "page[number]" and "page[limit]" query parameters.
+type NumberStrategy struct{}
+
+// Parse reads the page number from the query. A missing page number refers to the first page.
+func (NumberStrategy) Parse(params page.Params, criteria *query.Page) error {
+	number, err := params.PageNumber()
+	if err == nil && number < 0 {
+		err = errors.New("must not be negative")
+	}
+	if err != nil {
+		return page.NewParameterError(query.ParamPageNumber, err)
+	}
+	criteria.PageNumber = max(number, 1)
+	return nil
+}
+
+// Navigate returns the pages adjacent to the current page. The "last" page is only
+// available if the total is known.
+func (NumberStrategy) Navigate(current query.Page, result Result) map[string]query.Page {
+	number := max(current.PageNumber, 1)
+	pages := map[string]query.Page{
+		LinkFirst: {PageNumber: 1},
+	}
+
+	if number > 1 {
+		pages[LinkPrev] = query.Page{PageNumber: number - 1}
+	}
+
+	if result.Total >= 0 && current.Limit > 0 {
+		last := lastPageNumber(result.Total, current.Limit)
+		pages[LinkLast] = query.Page{PageNumber: last}
+		if number < last {
+			pages[LinkNext] = query.Page{PageNumber: number + 1}
+		}
+	} else if result.HasMore {
+		pages[LinkNext] = query.Page{PageNumber: number + 1}
+	}
+
+	return pages
+}
+
+// Encode sets the "page[number]" query parameter.
+func (NumberStrategy) Encode(params url.Values, criteria query.Page) {
+	params.Set(query.ParamPageNumber, strconv.Itoa(criteria.PageNumber))
+}
+
+// Meta returns the current page number and, if the total is known, the total number of pages.
+func (NumberStrategy) Meta(current query.Page, result Result) map[string]any {
+	meta := map[string]any{"number": max(current.PageNumber, 1)}
+	if result.Total >= 0 && current.Limit > 0 {
+		meta["pages"] = lastPageNumber(result.Total, current.Limit)
+	}
+	return meta
+}
+
+func lastPageNumber(total int, limit int) int {
+	return max((total+limit-1)/limit, 1)
+}
+
+// OffsetStrategy addresses pages by the number of resources that precede them, using the
+// "page[offset]" and "page[limit]" query parameters.
+type OffsetStrategy struct{}
+
+// Parse reads the page offset from the query.
+func (OffsetStrategy) Parse(params page.Params, criteria *query.Page) error {
+	offset, err := params.Offset()
+	if err == nil && offset < 0 {
+		err = errors.New("must not be negative")
+	}
+	if err != nil {
+		return page.NewParameterError(query.ParamPageOffset, err)
+	}
+	criteria.Offset = offset
+	return nil
+}
+
+// Navigate returns the pages adjacent to the current page. Navigation requires a page limit;
+// the "last" page is only available if the total is known.
+func (OffsetStrategy) Navigate(current query.Page, result Result) map[string]query.Page {
+	if current.Limit <= 0 {
+		return map[string]query.Page{}
+	}
+
+	pages := map[string]query.Page{
+		LinkFirst: {Offset: 0},
+	}
+
+	if current.Offset > 0 {
+		pages[LinkPrev] = query.Page{Offset: max(current.Offset-current.Limit, 0)}
+	}
+
+	next := current.Offset + current.Limit
+	if result.Total >= 0 {
+		pages[LinkLast] = query.Page{Offset: max((result.Total-1)/current.Limit, 0) * current.Limit}
+		if next < result.Total {
+			pages[LinkNext] = query.Page{Offset: next}
+		}
+	} else if result.HasMore {
+		pages[LinkNext] = query.Page{Offset: next}
+	}
+
+	return pages
+}
+
+// Encode sets the "page[offset]" query parameter.
+func (OffsetStrategy) Encode(params url.Values, criteria query.Page) {
+	params.Set(query.ParamPageOffset, strconv.Itoa(criteria.Offset))
+}
+
+// Meta returns the current page offset.
+func (OffsetStrategy) Meta(current query.Page, result Result) map[string]any {
+	return map[string]any{"offset": current.Offset}
+}
+
+// CursorStrategy addresses pages relative to the boundary resources of adjacent pages, using
+// the "page[after]", "page[before]" and "page[size]" query parameters of the cursor pagination
+// profile. Handlers return the resources after "page[after]", or before "page[before]", and
+// report the cursors of the first and last resources of the page in the [Result].
+//
+// CursorStrategy implements [LimitStrategy]: invalid page sizes, and sizes exceeding the
+// paginator's maximum, are rejected with the profile's error types instead of being reduced.
+type CursorStrategy struct{}
+
+// Parse reads the page cursors from the query. At most one cursor may be provided; range
+// pagination is not supported.
+func (CursorStrategy) Parse(params page.Params, criteria *query.Page) error {
+	criteria.Cursor = params.After()
+	criteria.Before = params.Before()
+	if criteria.Cursor != "" && criteria.Before != "" {
+		return cursorProfileError(ErrorTypeRangePaginationNotSupported, query.ParamPageBefore,
+			"range pagination is not supported: cannot be combined with "+query.ParamPageAfter)
+	}
+	return nil
+}
+
+// LimitParameter returns "page[size]".
+func (CursorStrategy) LimitParameter() string {
+	return query.ParamPageSize
+}
+
+// ParseLimit reads the page size from the query. The size must be a positive integer that
+// does not exceed the maximum.
+func (CursorStrategy) ParseLimit(params page.Params, maximum int) (int, error) {
+	size, err := params.Size()
+	if err == nil && params[query.ParamPageSize] != "" && size <= 0 {
+		err = errors.New("must be a positive integer")
+	}
+	if err != nil {
+		return 0, invalidParameterError(query.ParamPageSize, err)
+	}
+	if maximum > 0 && size > maximum {
+		return 0, maxSizeExceededError(size, maximum)
+	}
+	return size, nil
+}
+
+// Navigate returns the pages adjacent to the current page. The "last" page is never
+// available, since it cannot be addressed by cursor.
+func (CursorStrategy) Navigate(current query.Page, result Result) map[string]query.Page {
+	pages := map[string]query.Page{
+		LinkFirst: {},
+	}
+
+	hasNext := result.HasMore
+	hasPrev := current.Cursor != ""
+	if current.Before != "" {
+		// paging backwards: more resources precede the page.
+		hasNext, hasPrev = true, result.HasMore
+	}
+
+	if hasNext && result.EndCursor != "" {
+		pages[LinkNext] = query.Page{Cursor: result.EndCursor}
+	}
+	if hasPrev && result.StartCursor != "" {
+		pages[LinkPrev] = query.Page{Before: result.StartCursor}
+	}
+
+	return pages
+}
+
+// Encode sets the "page[after]" or "page[before]" query parameter.
+func (CursorStrategy) Encode(params url.Values, criteria query.Page) {
+	if criteria.Cursor != "" {
+		params.Set(query.ParamPageAfter, criteria.Cursor)
+	}
+	if criteria.Before != "" {
+		params.Set(query.ParamPageBefore, criteria.Before)
+	}
+}
+
+// Meta reports whether more resources follow the page.
+func (CursorStrategy) Meta(current query.Page, result Result) map[string]any {
+	return map[string]any{"hasMore": result.HasMore}
+}
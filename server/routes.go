@@ -0,0 +1,35 @@
+package server
+
+import (
+	"context"
+
+	"github.com/gonobo/jsonapi/v2"
+)
+
+const urlResolverContextKey contextkey = "jsonapi_url_resolver"
+
+// WithRouter resolves request contexts with the router, and stores the router in the request's
+// context as the URL resolver, where it can be retrieved downstream via [URLResolverFromContext].
+// Links written with the URL resolver -- see [WriteResourceLinks] and [WriteLocationHeader] --
+// therefore always match the routes that requests are resolved with.
+func WithRouter(router *jsonapi.Router) Options {
+	return func(c *Config) {
+		c.contextResolver = router
+		c.urlResolver = router
+	}
+}
+
+// ContextWithURLResolver stores the URL resolver in the parent context.
+func ContextWithURLResolver(parent context.Context, resolver jsonapi.URLResolver) context.Context {
+	return context.WithValue(parent, urlResolverContextKey, resolver)
+}
+
+// URLResolverFromContext returns the URL resolver stored in the context. If there is none,
+// [jsonapi.DefaultURLResolver] is returned.
+func URLResolverFromContext(ctx context.Context) jsonapi.URLResolver {
+	resolver, ok := ctx.Value(urlResolverContextKey).(jsonapi.URLResolver)
+	if !ok || resolver == nil {
+		return jsonapi.DefaultURLResolver()
+	}
+	return resolver
+}
@@ -0,0 +1,61 @@
+package server_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gonobo/jsonapi/v2"
+	"github.com/gonobo/jsonapi/v2/server"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithRouter(t *testing.T) {
+	router := jsonapi.MustCompileRoutes(jsonapi.Routes{
+		Collection:   "/api/v2/{type}",
+		Resource:     "/api/v2/{type}/{id}",
+		Relationship: "/api/v2/{type}/{id}/rels/{relationship}",
+		Related:      "/api/v2/{type}/{id}/{relationship}",
+	})
+
+	type item struct {
+		ID    string `jsonapi:"primary,items"`
+		Owner *item  `jsonapi:"relation,owner"`
+	}
+
+	var got *jsonapi.RequestContext
+	handler := server.Handle(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = jsonapi.FromContext(r.Context())
+		resolver := server.URLResolverFromContext(r.Context())
+		server.Write(w, item{ID: "1", Owner: &item{ID: "2"}}, http.StatusOK,
+			server.WriteResourceLinks("https://example.com", resolver))
+	}), server.WithRouter(router))
+
+	serve := func(target string) jsonapi.Document {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+		assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+		doc := jsonapi.Document{}
+		assert.NoError(t, jsonapi.Decode(w.Result().Body, &doc))
+		return doc
+	}
+
+	doc := serve("https://example.com/api/v2/items/1")
+	assert.Equal(t, &jsonapi.RequestContext{ResourceType: "items", ResourceID: "1"}, got)
+
+	resource := doc.Data.First()
+	assert.Equal(t, "https://example.com/api/v2/items/1", resource.Links["self"].Href)
+
+	owner := resource.Relationships["owner"]
+	serve(owner.Links["self"].Href)
+	assert.Equal(t, &jsonapi.RequestContext{ResourceType: "items", ResourceID: "1", Relationship: "owner"}, got)
+
+	serve(owner.Links["related"].Href)
+	assert.Equal(t, &jsonapi.RequestContext{ResourceType: "items", ResourceID: "1", Relationship: "owner", Related: true}, got)
+}
+
+func TestURLResolverFromContext(t *testing.T) {
+	resolver := server.URLResolverFromContext(httptest.NewRequest("GET", "/", nil).Context())
+	assert.Equal(t, "https://example.com/items/1",
+		resolver.ResolveURL(jsonapi.RequestContext{ResourceType: "items", ResourceID: "1"}, "https://example.com"))
+}
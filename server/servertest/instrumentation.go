@@ -0,0 +1,131 @@
+package servertest
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+
+	"github.com/gonobo/jsonapi/v2/server"
+)
+
+// RecordedSpan is a span recorded by [Instrumentation].
+type RecordedSpan struct {
+	Name   string         // The span name.
+	Parent *RecordedSpan  // The parent span, if any.
+	Attrs  map[string]any // The span attributes.
+	Errors []error        // The errors recorded with the span.
+	Ended  bool           // If true, the span has ended.
+	mu     *sync.Mutex
+}
+
+// SetAttributes adds attributes to the span.
+func (s *RecordedSpan) SetAttributes(attrs ...slog.Attr) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, attr := range attrs {
+		s.Attrs[attr.Key] = attr.Value.Any()
+	}
+}
+
+// RecordError records that the stage failed with the error.
+func (s *RecordedSpan) RecordError(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Errors = append(s.Errors, err)
+}
+
+// End completes the span.
+func (s *RecordedSpan) End() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Ended = true
+}
+
+// Measurement is a counter increment or histogram value recorded by [Instrumentation].
+type Measurement struct {
+	Name  string         // The metric name.
+	Value float64        // The counter increment or histogram value.
+	Attrs map[string]any // The measurement attributes.
+}
+
+// Instrumentation implements [server.Instrumentation], recording spans and measurements
+// in memory. It is safe for concurrent use. The zero value is ready to use.
+type Instrumentation struct {
+	mu           sync.Mutex
+	spans        []*RecordedSpan
+	measurements []Measurement
+}
+
+type spanContextKey struct{}
+
+// StartSpan starts a span, returning a context containing it.
+func (i *Instrumentation) StartSpan(ctx context.Context, name string, attrs ...slog.Attr) (context.Context, server.Span) {
+	parent, _ := ctx.Value(spanContextKey{}).(*RecordedSpan)
+	span := &RecordedSpan{Name: name, Parent: parent, Attrs: map[string]any{}, mu: &i.mu}
+	span.SetAttributes(attrs...)
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.spans = append(i.spans, span)
+
+	return context.WithValue(ctx, spanContextKey{}, span), span
+}
+
+// AddCounter records the counter increment.
+func (i *Instrumentation) AddCounter(ctx context.Context, name string, value int64, attrs ...slog.Attr) {
+	i.record(name, float64(value), attrs)
+}
+
+// RecordHistogram records the histogram value.
+func (i *Instrumentation) RecordHistogram(ctx context.Context, name string, value float64, attrs ...slog.Attr) {
+	i.record(name, value, attrs)
+}
+
+func (i *Instrumentation) record(name string, value float64, attrs []slog.Attr) {
+	measurement := Measurement{Name: name, Value: value, Attrs: map[string]any{}}
+	for _, attr := range attrs {
+		measurement.Attrs[attr.Key] = attr.Value.Any()
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.measurements = append(i.measurements, measurement)
+}
+
+// Spans returns the recorded spans with the provided name, in the order they were started.
+// If name is empty, all spans are returned.
+func (i *Instrumentation) Spans(name string) []*RecordedSpan {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	spans := make([]*RecordedSpan, 0, len(i.spans))
+	for _, span := range i.spans {
+		if name == "" || span.Name == name {
+			spans = append(spans, span)
+		}
+	}
+	return spans
+}
+
+// Measurements returns the recorded measurements of the named metric, in the order they
+// were recorded.
+func (i *Instrumentation) Measurements(name string) []Measurement {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	measurements := make([]Measurement, 0, len(i.measurements))
+	for _, measurement := range i.measurements {
+		if measurement.Name == name {
+			measurements = append(measurements, measurement)
+		}
+	}
+	return measurements
+}
+
+// Reset discards all recorded spans and measurements.
+func (i *Instrumentation) Reset() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.spans = nil
+	i.measurements = nil
+}
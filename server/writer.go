@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -61,18 +62,26 @@ func (ww *ResponseRecorder) WriteHeader(status int) {
 }
 
 // Flush writes the status code, headers, and document to w.
+//
+// Flush panics if the document cannot be marshaled. The document is marshaled
+// before anything is written to w, so a recovering middleware can still
+// respond with an error.
 func (ww ResponseRecorder) Flush(w http.ResponseWriter) {
+	var body []byte
+	if ww.Document != nil {
+		data, err := ww.JSONMarshal(ww.Document)
+		if err != nil {
+			panic(fmt.Errorf("memory writer: failed to marshal body: %w", err))
+		}
+		body = data
+	}
 	for k, v := range ww.Header() {
 		w.Header()[k] = v
 	}
 	if ww.Status != 0 {
 		w.WriteHeader(ww.Status)
 	}
-	if ww.Document != nil {
-		body, err := ww.JSONMarshal(ww.Document)
-		if err != nil {
-			panic(fmt.Errorf("memory writer: failed to marshal body: %w", err))
-		}
+	if body != nil {
 		swallowWriteResult(w.Write(body))
 	}
 }
@@ -86,6 +95,7 @@ func (ww ResponseRecorder) Flush(w http.ResponseWriter) {
 // made to w after Write() is called.
 func Write(w http.ResponseWriter, data any, status int, options ...WriteOptions) {
 	cfg := DefaultConfig()
+	cfg.ctx = writerContext(w)
 	cfg.ApplyWriteOptions(options...)
 
 	if data == nil {
@@ -94,11 +104,16 @@ func Write(w http.ResponseWriter, data any, status int, options ...WriteOptions)
 		return
 	}
 
+	span := Span(noopSpan{})
+	if cfg.ctx != nil {
+		_, span = StartSpan(cfg.ctx, SpanMarshal, slog.Int("status", status))
+	}
+	defer span.End()
+
 	doc, err := cfg.jsonapiMarshal(data)
 
 	if err != nil {
-		errmsg := fmt.Sprintf("jsonapi: failed to marshal response: %s", err)
-		http.Error(w, errmsg, http.StatusInternalServerError)
+		writeFailure(w, cfg, span, "jsonapi: failed to marshal response", err)
 		return
 	}
 
@@ -106,20 +121,38 @@ func Write(w http.ResponseWriter, data any, status int, options ...WriteOptions)
 	err = cfg.applyDocumentOptions(w, &doc)
 
 	if err != nil {
-		errmsg := fmt.Sprintf("jsonapi: failed to apply document options: %s", err)
-		http.Error(w, errmsg, http.StatusInternalServerError)
+		writeFailure(w, cfg, span, "jsonapi: failed to apply document options", err)
 		return
 	}
 
+	// convert member names last, so document options see the canonical names.
+	if cfg.memberNames != nil {
+		doc, err = jsonapi.Marshal(doc, jsonapi.WithMemberNames(*cfg.memberNames))
+		if err != nil {
+			writeFailure(w, cfg, span, "jsonapi: failed to convert member names", err)
+			return
+		}
+	}
+
+	if cfg.etag {
+		tag, err := entityTag(data, &doc)
+		if err != nil {
+			writeFailure(w, cfg, span, "jsonapi: failed to compute entity tag", err)
+			return
+		}
+		w.Header().Set(HeaderKeyETag, tag)
+	}
+
 	// marshal document
 	payload, err := cfg.jsonMarshal(doc)
 
 	if err != nil {
-		errmsg := fmt.Sprintf("jsonapi: failed to marshal response: %s", err)
-		http.Error(w, errmsg, http.StatusInternalServerError)
+		writeFailure(w, cfg, span, "jsonapi: failed to marshal response", err)
 		return
 	}
 
+	span.SetAttributes(slog.Int("bytes", len(payload)))
+
 	// add jsonapi header
 	w.Header().Add("Content-Type", jsonapi.MediaType)
 
@@ -152,6 +185,15 @@ func Error(w http.ResponseWriter, err error, status int, options ...WriteOptions
 
 func swallowWriteResult(int, error) {}
 
+func swallowFlushResult(error) {}
+
+// writeFailure logs and reports a failure to write the response document.
+func writeFailure(w http.ResponseWriter, cfg Config, span Span, msg string, err error) {
+	span.RecordError(err)
+	cfg.log().Error(msg, slog.Any("error", err))
+	http.Error(w, fmt.Sprintf("%s: %s", msg, err), http.StatusInternalServerError)
+}
+
 // WriteLink adds a URL to the response document's links attribute with the provided key.
 func WriteLink(key string, href string) WriteOptions {
 	return WithDocumentOptions(
@@ -318,6 +360,15 @@ func WriteMeta(key string, value any) WriteOptions {
 		})
 }
 
+// WriteMemberNames converts the member names of the response document from their canonical
+// naming convention into the wire convention. Names are converted after all other document
+// options have been applied.
+func WriteMemberNames(names jsonapi.MemberNames) WriteOptions {
+	return func(c *Config) {
+		c.memberNames = &names
+	}
+}
+
 // WriteLocationHeader adds the "Location" http header to the response. The resulting
 // URL is based on the primary data resource's type and id.
 func WriteLocationHeader(baseURL string, resolver jsonapi.URLResolver) WriteOptions {
@@ -184,6 +184,37 @@ func TestWriteMeta(t *testing.T) {
 	}
 }
 
+func TestWriteMemberNames(t *testing.T) {
+	names := jsonapi.MemberNames{Canonical: jsonapi.SnakeCase, Wire: jsonapi.KebabCase}
+	for _, tc := range []writeOptionTestCase{
+		{
+			name: "converts member names after document options",
+			doc: *jsonapi.NewSingleDocument(&jsonapi.Resource{
+				Type:       "articles",
+				ID:         "1",
+				Attributes: map[string]any{"first_line": "a"},
+			}),
+			options: []server.WriteOptions{
+				server.WriteMemberNames(names),
+				server.WithDocumentOptions(func(w http.ResponseWriter, d *jsonapi.Document) error {
+					d.Data.First().Attributes["word_count"] = 1
+					return nil
+				}),
+			},
+			wantJSON: `{
+				"jsonapi": {"version": "1.1"},
+				"data": {
+					"type": "articles",
+					"id": "1",
+					"attributes": {"first-line": "a", "word-count": 1}
+				}
+			}`,
+		},
+	} {
+		tc.run(t)
+	}
+}
+
 func TestWriteLink(t *testing.T) {
 	for _, tc := range []writeOptionTestCase{
 		{